@@ -1,11 +1,15 @@
 package atclient
 
 import (
+	"context"
+	"crypto/aes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"reflect"
 	"strconv"
-	"strings"
+	"sync"
+	"time"
 
 	"github.com/atsign-foundation/at_go/at_client/common"
 	"github.com/atsign-foundation/at_go/at_client/connections"
@@ -18,50 +22,312 @@ import (
 
 type AtClient struct {
 	AtSign              common.AtSign
+	RootAddress         connections.Address
 	SecondaryAddress    connections.Address
-	SecondaryConnection connections.AtSecondaryConnection
+	SecondaryConnection *connections.AtSecondaryConnection
 	Keys                map[string]string
 	Verbose             bool
 	Authenticated       bool
+
+	reconnectListener func(event connections.ReconnectEvent)
+	connectionOptions []connections.ConnectionOption
+	keyStore          key_utils.KeyStore
+	encryptionMode    string
+	maxValueSize      int
+	requireSignatures bool
+	poolOptions       *poolOptions
+	pool              *connections.AtConnectionPool
+	pipelineWindow    int
+	secondaryFinder   connections.SecondaryAddressFinder
+	resolvedSecondary bool
+
+	// sharedKeysMu guards sharedKeys, the keys other atSigns share values with us under, by
+	// shared shared key name. sharedByMeMu guards sharedByMe, the keys we share values with
+	// other atSigns under, by atSign, and is held while looking up, and if need be creating,
+	// one of them, so that only one is created.
+	sharedKeysMu sync.Mutex
+	sharedKeys   map[string]cachedSharedKey
+	sharedByMeMu sync.Mutex
+	sharedByMe   map[string]sharedByMeKey
 }
 
-func NewAtClient(atsign common.AtSign, address connections.Address, verbose bool) (*AtClient, error) {
-	ku := &key_utils.KeysUtil{}
-	keysMap, err := ku.LoadKeys(atsign.AtSignStr)
-	if err != nil {
-		return nil, err
+// LookupResponse is the JSON returned by the "all" variants of llookup, lookup and plookup.
+type LookupResponse struct {
+	Key         string           `json:"key"`
+	Data        string           `json:"data"`
+	RawMetadata json.RawMessage  `json:"metaData"`
+	Metadata    *common.Metadata `json:"-"`
+}
+
+// cachedSharedKey is a shared key and the encrypted shared_key it was decrypted from.
+type cachedSharedKey struct {
+	key        string
+	ciphertext string
+}
+
+// sharedByMeKey is a key we share values with another atSign under and, once looked up, the
+// copy of it encrypted for them and the checksum of their public key, as sent with each value.
+type sharedByMeKey struct {
+	key          string
+	sharedKeyEnc string
+	pubKeyCS     string
+}
+
+// AtClientOption configures optional behaviour of an AtClient created with NewAtClient.
+type AtClientOption func(*AtClient)
+
+// WithSecondaryAddress connects directly to the atServer at address instead of asking the
+// root server where the atSign's secondary is.
+func WithSecondaryAddress(address connections.Address) AtClientOption {
+	return func(c *AtClient) {
+		c.SecondaryAddress = address
+	}
+}
+
+// WithReconnectListener registers listener to be told about every attempt to reconnect to,
+// and re-authenticate with, the atServer after the connection was lost.
+func WithReconnectListener(listener func(event connections.ReconnectEvent)) AtClientOption {
+	return func(c *AtClient) {
+		c.reconnectListener = listener
+	}
+}
+
+// WithKeys uses keys instead of loading the atSign's keys from its atKeys file.
+func WithKeys(keys map[string]string) AtClientOption {
+	return func(c *AtClient) {
+		c.Keys = keys
+	}
+}
+
+// WithKeyStore loads the atSign's keys from store instead of ~/.atsign/keys. Onboard and
+// PendingEnrollment.Complete save the keys they create there.
+func WithKeyStore(store key_utils.KeyStore) AtClientOption {
+	return func(c *AtClient) {
+		c.keyStore = store
+	}
+}
+
+// WithEncryptionMode sets how Put and Notify encrypt values whose metadata names no mode:
+// encryption_util.EncryptionModeAesGcm or encryption_util.EncryptionModeAesCtr (the default,
+// which other atSign SDKs can read). Get decrypts values in either mode.
+func WithEncryptionMode(mode string) AtClientOption {
+	return func(c *AtClient) {
+		c.encryptionMode = mode
+	}
+}
+
+// WithMaxValueSize sets the largest value, in bytes as sent to the atServer, that Put and
+// PutBytes will send. The default is DefaultMaxValueSize.
+func WithMaxValueSize(size int) AtClientOption {
+	return func(c *AtClient) {
+		c.maxValueSize = size
+	}
+}
+
+// WithRequireSignatures makes Get fail for public values that have no dataSignature. Values
+// whose signature does not verify are always rejected.
+func WithRequireSignatures() AtClientOption {
+	return func(c *AtClient) {
+		c.requireSignatures = true
+	}
+}
+
+// WithConnectionPool executes commands on a pool of between minSize and maxSize connections
+// to the atServer, each authenticated with PKAM, instead of on SecondaryConnection alone, so
+// that commands from several goroutines run in parallel. Connections idle for idleTimeout are
+// closed; zero means DefaultPoolIdleTimeout. SecondaryConnection is one of the pool's
+// connections, so the pool may close it while it is idle.
+// Onboard and PendingEnrollment.Complete start the pool for the client they return. Enroll
+// only sends a few commands, so it uses a single connection.
+func WithConnectionPool(minSize, maxSize int, idleTimeout time.Duration) AtClientOption {
+	return func(c *AtClient) {
+		c.poolOptions = &poolOptions{minSize: minSize, maxSize: maxSize, idleTimeout: idleTimeout}
+	}
+}
+
+// WithPipelineWindow sets how many commands GetAtKeys and BulkGet may send ahead of the
+// responses they have read. The default is connections.DefaultPipelineWindow.
+func WithPipelineWindow(window int) AtClientOption {
+	return func(c *AtClient) {
+		c.pipelineWindow = window
+	}
+}
+
+// WithSecondaryAddressFinder finds the atSign's secondary with finder instead of the
+// process-wide RootResolver for the root server, or, for clients given connection options, a
+// RootResolver of their own.
+func WithSecondaryAddressFinder(finder connections.SecondaryAddressFinder) AtClientOption {
+	return func(c *AtClient) {
+		c.secondaryFinder = finder
 	}
+}
 
-	secondaryAddress := connections.NewAddress("", 0)
+type poolOptions struct {
+	minSize     int
+	maxSize     int
+	idleTimeout time.Duration
+}
+
+// WithConnectionOptions applies options to every connection the client opens, to the root
+// server as well as to the atServer, e.g. connections.WithRootCAs to trust a private CA,
+// connections.WithClientCertificates for mutual TLS or connections.WithDialer to use a proxy.
+func WithConnectionOptions(options ...connections.ConnectionOption) AtClientOption {
+	return func(c *AtClient) {
+		c.connectionOptions = append(c.connectionOptions, options...)
+	}
+}
+
+// NewAtClient creates a client for atsign, looking up its secondary on the root server at
+// rootAddress. An empty rootAddress (":0") means the public root server.
+func NewAtClient(atsign common.AtSign, rootAddress connections.Address, verbose bool, options ...AtClientOption) (*AtClient, error) {
+	if rootAddress.String() == ":0" {
+		rootAddress = *connections.DefaultRootAddress()
+	}
 
 	client := &AtClient{
-		AtSign:  atsign,
-		Keys:    keysMap,
-		Verbose: verbose,
+		AtSign:         atsign,
+		RootAddress:    rootAddress,
+		Verbose:        verbose,
+		maxValueSize:   DefaultMaxValueSize,
+		pipelineWindow: connections.DefaultPipelineWindow,
+	}
+	for _, option := range options {
+		option(client)
 	}
 
-	if secondaryAddress.String() == ":0" {
-		rootConnection := connections.GetAtRootConnectionInstance()
-		address, exception := rootConnection.FindSecondary(atsign)
-		if exception != nil {
-			return nil, exception
+	if client.Keys == nil {
+		keysMap, err := client.getKeyStore().Load(atsign.AtSignStr)
+		if err != nil {
+			return nil, err
 		}
-		secondaryAddress = address
+		client.Keys = keysMap
+	}
+
+	if err := client.findSecondary(); err != nil {
+		return nil, err
+	}
+	if err := client.connectSecondary(); err != nil {
+		return nil, err
+	}
+	client.SecondaryConnection.Authenticator = client.authenticate
+	if err := client.authenticate(client.SecondaryConnection.AtConnection); err != nil {
+		if !client.SecondaryConnection.AtConnection.IsConnected() {
+			client.invalidateSecondary()
+		}
+		client.SecondaryConnection.AtConnection.Disconnect()
+		return nil, err
 	}
-	client.SecondaryAddress = *secondaryAddress
-	client.SecondaryConnection = *connections.NewAtSecondaryConnection(*secondaryAddress, verbose)
-	var authErr = auth_util.AuthenticateWithPkam(*client.SecondaryConnection.AtConnection, client.AtSign, keysMap)
-	if authErr != nil {
-		fmt.Println(authErr)
+
+	if client.poolOptions != nil {
+		if err := client.startPool(); err != nil {
+			client.invalidateSecondary()
+			client.SecondaryConnection.AtConnection.Disconnect()
+			return nil, err
+		}
 	}
 
 	client.Authenticated = true
 	return client, nil
 }
 
+func (c *AtClient) startPool() error {
+	pool := connections.NewAtConnectionPool(c.SecondaryAddress, c.authenticate, c.Verbose, c.connectionOptions...)
+	pool.MinSize = c.poolOptions.minSize
+	pool.MaxSize = c.poolOptions.maxSize
+	if c.poolOptions.idleTimeout > 0 {
+		pool.IdleTimeout = c.poolOptions.idleTimeout
+	}
+	pool.OnReconnect = c.onReconnect
+
+	// SecondaryConnection is already authenticated, so it becomes the pool's first connection
+	pool.Add(c.SecondaryConnection)
+	if err := pool.Start(context.Background()); err != nil {
+		pool.Close()
+		return err
+	}
+	c.pool = pool
+	return nil
+}
+
+// Pool returns the connection pool commands are executed on, or nil if the client was not
+// created WithConnectionPool.
+func (c *AtClient) Pool() *connections.AtConnectionPool {
+	return c.pool
+}
+
+// Close disconnects from the atServer and closes the connection pool, if any.
+func (c *AtClient) Close() {
+	if c.pool != nil {
+		c.pool.Close()
+	}
+	if c.SecondaryConnection != nil {
+		c.SecondaryConnection.AtConnection.Disconnect()
+	}
+}
+
+func (c *AtClient) getKeyStore() key_utils.KeyStore {
+	if c.keyStore == nil {
+		return key_utils.DefaultFileKeyStore()
+	}
+	return c.keyStore
+}
+
+// connectSecondary connects to the secondary, forgetting its cached address if that fails.
+func (c *AtClient) connectSecondary() error {
+	c.SecondaryConnection = connections.NewAtSecondaryConnection(c.SecondaryAddress, c.Verbose, c.connectionOptions...)
+	c.SecondaryConnection.OnReconnect = c.onReconnect
+	if err := c.SecondaryConnection.AtConnection.Connect(); err != nil {
+		c.invalidateSecondary()
+		return exceptions.NewAtSecondaryConnectException("Failed to connect to " + c.SecondaryAddress.String() + " - " + err.Error())
+	}
+	return nil
+}
+
+// findSecondary asks the client's SecondaryAddressFinder for the address of the atSign's
+// secondary, unless one was given with WithSecondaryAddress.
+func (c *AtClient) findSecondary() error {
+	if c.SecondaryAddress.String() != ":0" {
+		return nil
+	}
+	if c.secondaryFinder == nil && len(c.connectionOptions) > 0 {
+		// The shared resolver connects with the default options, so it cannot be used
+		c.secondaryFinder = connections.NewRootResolver(c.RootAddress, c.Verbose, c.connectionOptions...)
+	} else if c.secondaryFinder == nil {
+		c.secondaryFinder = connections.SharedRootResolver(c.RootAddress, c.Verbose)
+	}
+	address, err := c.secondaryFinder.FindSecondary(context.Background(), c.AtSign)
+	if err != nil {
+		return err
+	}
+	c.SecondaryAddress = *address
+	c.resolvedSecondary = true
+	return nil
+}
+
+// invalidateSecondary forgets the cached address of the secondary after connecting to it
+// failed, in case the atServer has moved.
+func (c *AtClient) invalidateSecondary() {
+	if invalidator, ok := c.secondaryFinder.(connections.SecondaryAddressInvalidator); ok && c.resolvedSecondary {
+		invalidator.Invalidate(c.AtSign)
+	}
+}
+
+func (c *AtClient) onReconnect(event connections.ReconnectEvent) {
+	if event.Err != nil {
+		c.invalidateSecondary()
+	}
+	if c.reconnectListener != nil {
+		c.reconnectListener(event)
+	}
+}
+
+func (c *AtClient) authenticate(conn *connections.AtConnection) error {
+	return auth_util.AuthenticateWithPkam(conn, c.AtSign, c.Keys)
+}
+
 func (c *AtClient) GetAtKeys(regex string, fetchMetadata bool) ([]common.AtKey, error) {
 	scanCommand := verb_builder.NewScanVerbBuilder().SetRegex(regex).SetShowHidden(false).Build()
-	scanRawResponse, err := c.SecondaryConnection.AtConnection.ExecuteCommand(scanCommand, true)
+	scanRawResponse, err := c.executeRawCommand(scanCommand)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to execute : %s : %s", scanCommand, err)
 	}
@@ -72,7 +338,7 @@ func (c *AtClient) GetAtKeys(regex string, fetchMetadata bool) ([]common.AtKey,
 
 	keysList := []string{}
 	if len(scanRawResponse.GetRawDataResponse()) > 0 {
-		jsonData := strings.Replace(scanRawResponse.GetRawDataResponse(), "data:", "", 1)
+		jsonData := scanRawResponse.GetRawDataResponse()
 		err := json.Unmarshal([]byte(jsonData), &keysList)
 		if err != nil {
 			return nil, fmt.Errorf("Failed to parse JSON : %s : %s", jsonData, err)
@@ -85,25 +351,34 @@ func (c *AtClient) GetAtKeys(regex string, fetchMetadata bool) ([]common.AtKey,
 		if err != nil {
 			return atKeys, err
 		}
-		if fetchMetadata {
-			llookupCommand := "llookup:meta:" + atKeyRaw
-			llookupMetaResponse, err := c.SecondaryConnection.AtConnection.ExecuteCommand(llookupCommand, true)
-			if err != nil {
-				return nil, fmt.Errorf("Failed to execute : %s : %s", llookupCommand, err)
-			}
-			formattedLlookupMetaResponse, err := connections.ParseRawResponse(llookupMetaResponse.GetRawDataResponse())
-			if err != nil {
-				return nil, err
-			}
-			metadata, err := common.FromJSON(formattedLlookupMetaResponse.GetRawDataResponse())
-			if err != nil {
-				return nil, err
-			}
-			atKey.SetMetadata(*metadata)
-		}
-
 		atKeys = append(atKeys, atKey)
 	}
+	if !fetchMetadata {
+		return atKeys, nil
+	}
+
+	llookupCommands := make([]string, len(keysList))
+	for i, atKeyRaw := range keysList {
+		llookupCommands[i] = "llookup:meta:" + atKeyRaw
+	}
+	llookupMetaResponses, err := c.executeRawCommands(llookupCommands)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to execute : %s : %s", llookupCommands[len(llookupMetaResponses)], err)
+	}
+	for i, llookupMetaResponse := range llookupMetaResponses {
+		formattedLlookupMetaResponse, err := connections.ParseRawResponse(llookupMetaResponse.GetRawDataResponse())
+		if err != nil {
+			return nil, err
+		}
+		if formattedLlookupMetaResponse.IsError() {
+			return nil, formattedLlookupMetaResponse.GetException()
+		}
+		metadata, err := common.FromJSON(formattedLlookupMetaResponse.GetRawDataResponse())
+		if err != nil {
+			return nil, err
+		}
+		atKeys[i].SetMetadata(*metadata)
+	}
 
 	return atKeys, nil
 }
@@ -114,74 +389,89 @@ func (c *AtClient) IsAuthenticated() bool {
 
 func (c *AtClient) GetPublicEncryptionKey(sharedWith common.AtSign) (string, error) {
 	command := "plookup:publickey" + sharedWith.AtSignStr
-	response, err := c.SecondaryConnection.AtConnection.ExecuteCommand(command, true)
+	response, err := c.executeCommand(command)
 	if err != nil {
 		return "", err
 	}
-	if response.IsError() {
-		return response.GetErrorText(), response.GetException()
-	} else {
-		return response.GetRawDataResponse(), nil
-	}
+	return response.GetRawDataResponse(), nil
 }
 
 func (c *AtClient) CreateSharedEncryptionKey(sharedKey common.SharedKey) (string, error) {
-	theirPubEncKey, err := c.GetPublicEncryptionKey(*sharedKey.SharedWith)
+	aesKey, err := encryption_util.NewEncryptionUtil().GenerateAESKeyBase64()
 	if err != nil {
 		return "", err
 	}
 
-	var aesKey = ""
-	var encUtil = encryption_util.NewEncryptionUtil()
+	if err := c.saveSharedEncryptionKey(*sharedKey.SharedWith, aesKey); err != nil {
+		return "", err
+	}
+	return aesKey, nil
+}
 
-	aesKey, err = encUtil.GenerateAESKeyBase64()
+// saveSharedEncryptionKey stores aesKey as the key this atSign shares values with sharedWith
+// under: encrypted with our public key for us, and with theirs for them.
+func (c *AtClient) saveSharedEncryptionKey(sharedWith common.AtSign, aesKey string) error {
+	theirPubEncKey, err := c.GetPublicEncryptionKey(sharedWith)
 	if err != nil {
-		return "", err
+		return err
 	}
 
+	var encUtil = encryption_util.NewEncryptionUtil()
 	var step = ""
 
 	step = "encrypt new shared key with their public key"
 	encryptedForOther, err := encUtil.RsaEncryptToBase64(aesKey, []byte(theirPubEncKey))
 	if err != nil {
-		return "", exceptions.NewAtEncryptionException("Failed to " + step + " - " + err.Error())
+		return exceptions.NewAtEncryptionException("Failed to " + step + " - " + err.Error())
 	}
 
 	step = "encrypt new shared key with our public key"
 	encryptedForUs, err := encUtil.RsaEncryptToBase64(aesKey, []byte(c.Keys[key_utils.EncryptionPublicKeyName]))
 	if err != nil {
-		return "", exceptions.NewAtEncryptionException("Failed to " + step + " - " + err.Error())
+		return exceptions.NewAtEncryptionException("Failed to " + step + " - " + err.Error())
 	}
 
 	step = "save encrypted shared key for us"
-	command1 := "update:" + "shared_key." + sharedKey.SharedWith.WithoutPrefix + sharedKey.SharedBy.AtSignStr +
+	command1 := "update:" + "shared_key." + sharedWith.WithoutPrefix + c.AtSign.AtSignStr +
 		" " + encryptedForUs
-	c.SecondaryConnection.AtConnection.ExecuteCommand(command1, true)
+	if _, err := c.executeCommand(command1); err != nil {
+		return exceptions.NewAtEncryptionException("Failed to " + step + " - " + err.Error())
+	}
 
 	step = "save encrypted shared key for them"
 	ttr := 24 * 60 * 60 * 1000
-	command2 := "update:ttr:" + strconv.Itoa(ttr) + ":" + sharedKey.SharedWith.AtSignStr + ":shared_key" + sharedKey.SharedBy.AtSignStr +
+	pubKeyCS := encUtil.PublicKeyChecksum(theirPubEncKey)
+	command2 := "update:ttr:" + strconv.Itoa(ttr) + ":pubKeyCS:" + pubKeyCS + ":" + sharedWith.AtSignStr + ":shared_key" + c.AtSign.AtSignStr +
 		" " + encryptedForOther
-	c.SecondaryConnection.AtConnection.ExecuteCommand(command2, true)
+	if _, err := c.executeCommand(command2); err != nil {
+		return exceptions.NewAtEncryptionException("Failed to " + step + " - " + err.Error())
+	}
 
-	return aesKey, nil
+	return nil
 }
 
 func (c *AtClient) GetEncryptionKeySharedByMe(key common.SharedKey) (string, error) {
+	c.sharedByMeMu.Lock()
+	defer c.sharedByMeMu.Unlock()
+
+	cached := c.sharedByMe[key.SharedWith.AtSignStr]
+	if cached.key != "" {
+		return cached.key, nil
+	}
+
 	toLookup := "shared_key." + key.SharedWith.WithoutPrefix + c.AtSign.AtSignStr
 	command := "llookup:" + toLookup
 
-	response, err := c.SecondaryConnection.AtConnection.ExecuteCommand(command, true)
+	response, err := c.executeCommand(command)
 	if err != nil {
-		return "", err
-	}
-
-	if response.IsError() {
-		if _, ok := response.GetException().(exceptions.AtKeyNotFoundException); ok {
-			return c.CreateSharedEncryptionKey(key)
-		} else {
-			panic(response.GetException())
+		if _, ok := err.(*exceptions.AtKeyNotFoundException); ok {
+			result, err := c.CreateSharedEncryptionKey(key)
+			if err == nil {
+				c.cacheSharedByMe(*key.SharedWith, sharedByMeKey{key: result})
+			}
+			return result, err
 		}
+		return "", err
 	}
 
 	result, err := encryption_util.NewEncryptionUtil().RsaDecryptFromBase64(
@@ -191,39 +481,147 @@ func (c *AtClient) GetEncryptionKeySharedByMe(key common.SharedKey) (string, err
 	if err != nil {
 		return "", exceptions.NewAtDecryptionException(err.Error())
 	} else {
+		cached.key = result
+		c.cacheSharedByMe(*key.SharedWith, cached)
 		return result, nil
 	}
 }
 
+// cacheSharedByMe remembers the key we share values with sharedWith under. The caller must
+// hold sharedByMeMu.
+func (c *AtClient) cacheSharedByMe(sharedWith common.AtSign, cached sharedByMeKey) {
+	if c.sharedByMe == nil {
+		c.sharedByMe = map[string]sharedByMeKey{}
+	}
+	c.sharedByMe[sharedWith.AtSignStr] = cached
+}
+
+// setSharedKeyMetadata records in metadata the copy of the shared key that sharedWith reads our
+// values with, and the checksum of the public key it is encrypted with, so that sharedWith can
+// decrypt values without looking the shared key up. Both are looked up once and cached with
+// the shared key.
+func (c *AtClient) setSharedKeyMetadata(sharedWith common.AtSign, metadata *common.Metadata) error {
+	c.sharedByMeMu.Lock()
+	defer c.sharedByMeMu.Unlock()
+
+	cached := c.sharedByMe[sharedWith.AtSignStr]
+	if cached.sharedKeyEnc != "" {
+		metadata.SharedKeyEnc = cached.sharedKeyEnc
+		metadata.PubKeyCS = cached.pubKeyCS
+		return nil
+	}
+
+	command := verb_builder.NewLlookupVerbBuilder().
+		SetKeyName("shared_key").
+		SetSharedBy(c.AtSign.AtSignStr).
+		SetSharedWith(sharedWith.AtSignStr).
+		SetLookupType(verb_builder.LookupTypeAll).
+		Build()
+	lookupResponse, err := c.GetLookupResponse(command)
+	if err != nil {
+		return err
+	}
+
+	pubKeyCS := lookupResponse.Metadata.PubKeyCS
+	if pubKeyCS == "" {
+		// Shared keys saved before pubKeyCS was recorded were encrypted with sharedWith's current key
+		theirPubEncKey, err := c.GetPublicEncryptionKey(sharedWith)
+		if err != nil {
+			return err
+		}
+		pubKeyCS = encryption_util.NewEncryptionUtil().PublicKeyChecksum(theirPubEncKey)
+	}
+
+	cached.sharedKeyEnc = lookupResponse.Data
+	cached.pubKeyCS = pubKeyCS
+	c.cacheSharedByMe(sharedWith, cached)
+
+	metadata.SharedKeyEnc = cached.sharedKeyEnc
+	metadata.PubKeyCS = cached.pubKeyCS
+	return nil
+}
+
+// encryptionKeySharedByOtherFor returns the key that key.SharedBy encrypted a value with: the
+// copy in the value's metadata when it is encrypted with our current public key, otherwise the
+// shared key looked up from key.SharedBy.
+func (c *AtClient) encryptionKeySharedByOtherFor(key common.SharedKey, metadata *common.Metadata) (string, error) {
+	encryptionUtil := encryption_util.NewEncryptionUtil()
+	sharedSharedKeyName := key.GetSharedSharedKeyName()
+
+	if metadata.SharedKeyEnc != "" && metadata.PubKeyCS == encryptionUtil.PublicKeyChecksum(c.Keys[key_utils.EncryptionPublicKeyName]) {
+		if cached, ok := c.cachedSharedKey(sharedSharedKeyName); ok && cached.ciphertext == metadata.SharedKeyEnc {
+			return cached.key, nil
+		}
+		sharedKey, err := encryptionUtil.RsaDecryptFromBase64(metadata.SharedKeyEnc, []byte(c.Keys[key_utils.EncryptionPrivateKeyName]))
+		if err == nil {
+			c.cacheSharedKey(sharedSharedKeyName, cachedSharedKey{key: sharedKey, ciphertext: metadata.SharedKeyEnc})
+			return sharedKey, nil
+		}
+		if c.Verbose {
+			fmt.Printf("\tFailed to decrypt sharedKeyEnc of %s, looking up the shared key - %s\n", key.String(), err)
+		}
+	}
+
+	c.evictStaleSharedKey(key, metadata.SharedKeyEnc)
+	return c.GetEncryptionKeySharedByOther(key)
+}
+
 func (c *AtClient) GetEncryptionKeySharedByOther(key common.SharedKey) (string, error) {
 	sharedSharedKeyName := key.GetSharedSharedKeyName()
 
-	sharedKeyValue := c.Keys[sharedSharedKeyName]
-	if sharedKeyValue != "" {
-		return sharedKeyValue, nil
+	if cached, ok := c.cachedSharedKey(sharedSharedKeyName); ok {
+		return cached.key, nil
 	}
 
 	lookupCommand := "lookup:" + "shared_key" + key.SharedBy.AtSignStr
-	rawResponse, err := c.SecondaryConnection.AtConnection.ExecuteCommand(lookupCommand, true)
+	response, err := c.executeCommand(lookupCommand)
 	if err != nil {
-		if err == exceptions.NewAtKeyNotFoundException(err.Error()) {
-			return "", exceptions.NewAtKeyNotFoundException(err.Error())
-		} else {
-			return "", exceptions.NewAtSecondaryConnectException("Failed to execute " + lookupCommand + " - " + err.Error())
-		}
+		return "", err
 	}
 
 	sharedSharedKeyDecryptedValue, err := encryption_util.NewEncryptionUtil().RsaDecryptFromBase64(
-		rawResponse.GetRawDataResponse(),
+		response.GetRawDataResponse(),
 		[]byte(c.Keys[key_utils.EncryptionPrivateKeyName]))
 	if err != nil {
 		return "", exceptions.NewAtDecryptionException("Failed to decrypt the shared_key with our encryption private key - " + err.Error())
 	}
 
-	c.Keys[sharedSharedKeyName] = sharedSharedKeyDecryptedValue
+	c.cacheSharedKey(sharedSharedKeyName, cachedSharedKey{key: sharedSharedKeyDecryptedValue, ciphertext: response.GetRawDataResponse()})
 	return sharedSharedKeyDecryptedValue, nil
 }
 
+func (c *AtClient) cachedSharedKey(sharedSharedKeyName string) (cachedSharedKey, bool) {
+	c.sharedKeysMu.Lock()
+	defer c.sharedKeysMu.Unlock()
+	cached, ok := c.sharedKeys[sharedSharedKeyName]
+	return cached, ok
+}
+
+func (c *AtClient) cacheSharedKey(sharedSharedKeyName string, cached cachedSharedKey) {
+	c.sharedKeysMu.Lock()
+	defer c.sharedKeysMu.Unlock()
+	if c.sharedKeys == nil {
+		c.sharedKeys = map[string]cachedSharedKey{}
+	}
+	c.sharedKeys[sharedSharedKeyName] = cached
+}
+
+// evictStaleSharedKey forgets the cached key that key.SharedBy shares values with us under
+// when sharedKeyEnc, the encrypted shared_key sent with a value, is not the one the cached key
+// was decrypted from, i.e. when the sender has rotated the key since it was cached.
+func (c *AtClient) evictStaleSharedKey(key common.SharedKey, sharedKeyEnc string) {
+	if sharedKeyEnc == "" {
+		return
+	}
+	sharedSharedKeyName := key.GetSharedSharedKeyName()
+
+	c.sharedKeysMu.Lock()
+	defer c.sharedKeysMu.Unlock()
+	if cached, ok := c.sharedKeys[sharedSharedKeyName]; ok && cached.ciphertext != sharedKeyEnc {
+		delete(c.sharedKeys, sharedSharedKeyName)
+	}
+}
+
 func (c *AtClient) Put(key common.AtKey, value string) (*connections.Response, error) {
 	switch k := key.(type) {
 	case *common.SelfKey:
@@ -243,15 +641,19 @@ func (c *AtClient) putSelfKey(key common.SelfKey, value string) (*connections.Re
 	}
 
 	key.Metadata.DataSignature = signature
+	key.Metadata.IsEncrypted = true
 
-	ciphertext, err := encryption_util.NewEncryptionUtil().AesEncryptFromBase64(value, c.Keys[key_utils.SelfEncryptionKeyName], []byte(key.Metadata.IVNonce))
+	ciphertext, err := c.encryptValue(value, c.Keys[key_utils.SelfEncryptionKeyName], &key.Metadata)
 	if err != nil {
 		return nil, exceptions.NewAtEncryptionException("Failed to encrypt value with self encryption key - " + err.Error())
 	}
+	if err := c.checkValueSize(&key, ciphertext); err != nil {
+		return nil, err
+	}
 
 	command := verb_builder.NewUpdateVerbBuilder().WithAtKey(&key.AtKeyBase, ciphertext).Build()
 
-	response, err := c.SecondaryConnection.AtConnection.ExecuteCommand(command, true)
+	response, err := c.executeRawCommand(command)
 	if err != nil {
 		return nil, exceptions.NewAtSecondaryConnectException("Failed to execute {command} - " + command)
 	}
@@ -266,9 +668,13 @@ func (c *AtClient) putPublicKey(key common.PublicKey, value string) (*connection
 	}
 
 	key.Metadata.DataSignature = signature
+	if err := c.checkValueSize(&key, value); err != nil {
+		return nil, err
+	}
+
 	command := verb_builder.NewUpdateVerbBuilder().WithAtKey(&key.AtKeyBase, value).Build()
 
-	response, err := c.SecondaryConnection.AtConnection.ExecuteCommand(command, true)
+	response, err := c.executeRawCommand(command)
 	if err != nil {
 		return nil, exceptions.NewAtSecondaryConnectException("Failed to execute {command} - " + command)
 	}
@@ -287,14 +693,23 @@ func (c *AtClient) putSharedKey(key common.SharedKey, value string) (*connection
 		return nil, exceptions.NewAtEncryptionException("Failed to " + what + " - " + err.Error())
 	}
 
+	what = "fetch shared key metadata"
+	if err := c.setSharedKeyMetadata(*key.SharedWith, &key.Metadata); err != nil {
+		return nil, exceptions.NewAtEncryptionException("Failed to " + what + " - " + err.Error())
+	}
+
 	what = "encrypt value with shared encryption key"
-	ciphertext, err := encryption_util.NewEncryptionUtil().AesEncryptFromBase64(value, sharedToEncryptionKey, []byte(key.Metadata.IVNonce))
+	ciphertext, err := c.encryptValue(value, sharedToEncryptionKey, &key.Metadata)
 	if err != nil {
 		return nil, exceptions.NewAtEncryptionException("Failed to " + what + " - " + err.Error())
 	}
-	metadataStr := key.Metadata.String()
-	command := fmt.Sprintf("update%s:%s %s", metadataStr, key.String(), ciphertext)
-	response, err := c.SecondaryConnection.AtConnection.ExecuteCommand(command, true)
+	key.Metadata.IsEncrypted = true
+	if err := c.checkValueSize(&key, ciphertext); err != nil {
+		return nil, err
+	}
+
+	command := verb_builder.NewUpdateVerbBuilder().WithAtKey(&key.AtKeyBase, ciphertext).Build()
+	response, err := c.executeRawCommand(command)
 	if err != nil {
 		return nil, exceptions.NewAtSecondaryConnectException("Failed to execute " + command + " - " + err.Error())
 	}
@@ -302,13 +717,283 @@ func (c *AtClient) putSharedKey(key common.SharedKey, value string) (*connection
 	return response, nil
 }
 
-// func (c *AtClient) Get(key common.AtKey, command string) (string, error) {}
-// func (c *AtClient) GetLookupResponse(command string) (string, error) {}
-// func (c *AtClient) getSelfKey(key common.SelfKey) (string, error) {}
-// func (c *AtClient) getPublicKey(key common.PublicKey) (string, error) {}
-// func (c *AtClient) getSharedKey(key common.SharedKey) (string, error) {}
+// Get fetches the value of key and decrypts it where necessary. The key's Metadata is
+// updated with the metadata returned by the atServer.
+func (c *AtClient) Get(key common.AtKey) (string, error) {
+	command, err := c.lookupCommand(key)
+	if err != nil {
+		return "", err
+	}
+
+	lookupResponse, err := c.GetLookupResponse(command)
+	if err != nil {
+		return "", err
+	}
+	return c.valueFromLookup(key, lookupResponse, nil)
+}
+
+// BulkGetResult is the value of one of the keys passed to BulkGet, or why it could not be got.
+type BulkGetResult struct {
+	Key   common.AtKey
+	Value string
+	Err   error
+}
+
+// BulkGet gets the values of keys like Get, but pipelines the lookups so that their round
+// trips overlap. There is one result per key, in the same order. The error is only returned
+// if the lookups could not be sent or their responses read.
+func (c *AtClient) BulkGet(keys []common.AtKey) ([]BulkGetResult, error) {
+	results := make([]BulkGetResult, len(keys))
+	commands := []string{}
+	indexes := []int{}
+	for i, key := range keys {
+		results[i].Key = key
+		command, err := c.lookupCommand(key)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		commands = append(commands, command)
+		indexes = append(indexes, i)
+	}
+
+	rawResponses, err := c.executeRawCommands(commands)
+	if err != nil {
+		return nil, exceptions.NewAtSecondaryConnectException("Failed to execute " + commands[len(rawResponses)] + " - " + err.Error())
+	}
+
+	sharedByMeKeys := map[string]string{}
+	for j, rawResponse := range rawResponses {
+		result := &results[indexes[j]]
+		response, err := connections.ParseRawResponse(rawResponse.GetRawDataResponse())
+		if err != nil {
+			result.Err = exceptions.NewAtResponseHandlingException(err.Error())
+			continue
+		}
+		if response.IsError() {
+			result.Err = response.GetException()
+			continue
+		}
+		lookupResponse, err := parseLookupResponse(response)
+		if err != nil {
+			result.Err = err
+			continue
+		}
+		result.Value, result.Err = c.valueFromLookup(result.Key, lookupResponse, sharedByMeKeys)
+	}
+	return results, nil
+}
+
+// GetLookupResponse executes an llookup:all, lookup:all or plookup:all command and parses
+// the JSON it returns.
+func (c *AtClient) GetLookupResponse(command string) (*LookupResponse, error) {
+	response, err := c.executeCommand(command)
+	if err != nil {
+		return nil, err
+	}
+	return parseLookupResponse(response)
+}
+
+func parseLookupResponse(response *connections.Response) (*LookupResponse, error) {
+	lookupResponse := &LookupResponse{}
+	if err := json.Unmarshal([]byte(response.GetRawDataResponse()), lookupResponse); err != nil {
+		return nil, exceptions.NewAtResponseHandlingException("Failed to parse JSON : " + response.GetRawDataResponse() + " : " + err.Error())
+	}
 
-// func (c *AtClient) getSharedByMeWithOther(key common.SelfKey) (string, error) {}
-// func (c *AtClient) getSharedByOtherWithMe(key common.SelfKey) (string, error) {}
+	lookupResponse.Metadata = &common.Metadata{}
+	if len(lookupResponse.RawMetadata) > 0 && string(lookupResponse.RawMetadata) != "null" {
+		metadata, err := common.FromJSON(string(lookupResponse.RawMetadata))
+		if err != nil {
+			return nil, exceptions.NewAtResponseHandlingException("Failed to parse metadata : " + err.Error())
+		}
+		lookupResponse.Metadata = metadata
+	}
 
-// func (c *AtClient) delete(key common.Atkey) (connections.Response, error) {}
+	return lookupResponse, nil
+}
+
+// lookupCommand returns the llookup, lookup or plookup command that fetches the value and
+// metadata of key.
+func (c *AtClient) lookupCommand(key common.AtKey) (string, error) {
+	switch k := key.(type) {
+	case *common.SelfKey:
+		return verb_builder.NewLlookupVerbBuilder().WithAtKey(k, verb_builder.LookupTypeAll).Build(), nil
+	case *common.PublicKey:
+		if *k.SharedBy == c.AtSign {
+			return verb_builder.NewLlookupVerbBuilder().WithAtKey(k, verb_builder.LookupTypeAll).Build(), nil
+		}
+		return verb_builder.NewPlookupVerbBuilder().WithAtKey(k, verb_builder.LookupTypeAll).Build(), nil
+	case *common.SharedKey:
+		if *k.SharedBy == c.AtSign {
+			return verb_builder.NewLlookupVerbBuilder().WithAtKey(k, verb_builder.LookupTypeAll).Build(), nil
+		}
+		return verb_builder.NewLookupVerbBuilder().WithAtKey(k, verb_builder.LookupTypeAll).Build(), nil
+	}
+	return "", exceptions.NewAtException("No implementation found for key type: " + reflect.TypeOf(key).String())
+}
+
+// valueFromLookup finishes getting key from the response to its lookupCommand: it updates the
+// key's metadata, then decrypts the value or checks its signature. sharedByMeKeys, if not nil,
+// caches the keys we share values with other atSigns under, by atSign.
+func (c *AtClient) valueFromLookup(key common.AtKey, lookupResponse *LookupResponse, sharedByMeKeys map[string]string) (string, error) {
+	key.SetMetadata(*common.Squash(lookupResponse.Metadata, key.GetMetadata()))
+
+	switch k := key.(type) {
+	case *common.SelfKey:
+		value, err := decryptValue(lookupResponse.Data, c.Keys[key_utils.SelfEncryptionKeyName], lookupResponse.Metadata)
+		if err != nil {
+			return "", exceptions.NewAtDecryptionException("Failed to decrypt value with self encryption key - " + err.Error())
+		}
+		return value, nil
+
+	case *common.PublicKey:
+		if err := c.verifyPublicValue(k, lookupResponse); err != nil {
+			return "", err
+		}
+		return lookupResponse.Data, nil
+
+	case *common.SharedKey:
+		var what = "fetch shared encryption key"
+		var sharedEncryptionKey string
+		var err error
+		if *k.SharedBy != c.AtSign {
+			sharedEncryptionKey, err = c.encryptionKeySharedByOtherFor(*k, lookupResponse.Metadata)
+		} else if cached, ok := sharedByMeKeys[k.SharedWith.AtSignStr]; ok {
+			sharedEncryptionKey = cached
+		} else if sharedEncryptionKey, err = c.GetEncryptionKeySharedByMe(*k); err == nil && sharedByMeKeys != nil {
+			sharedByMeKeys[k.SharedWith.AtSignStr] = sharedEncryptionKey
+		}
+		if err != nil {
+			return "", exceptions.NewAtDecryptionException("Failed to " + what + " - " + err.Error())
+		}
+		return decryptSharedValue(lookupResponse, sharedEncryptionKey)
+	}
+	return "", exceptions.NewAtException("No implementation found for key type: " + reflect.TypeOf(key).String())
+}
+
+// verifyPublicValue checks the dataSignature of a public value against its owner's public
+// encryption key.
+func (c *AtClient) verifyPublicValue(key *common.PublicKey, lookupResponse *LookupResponse) error {
+	signature := lookupResponse.Metadata.DataSignature
+	if signature == "" {
+		if c.requireSignatures {
+			return exceptions.NewAtSignatureVerificationException(key.String() + " has no dataSignature")
+		}
+		return nil
+	}
+
+	ownerPublicKey := c.Keys[key_utils.EncryptionPublicKeyName]
+	if *key.SharedBy != c.AtSign {
+		var err error
+		if ownerPublicKey, err = c.GetPublicEncryptionKey(*key.SharedBy); err != nil {
+			return exceptions.NewAtSignatureVerificationException("Failed to fetch public encryption key of " + key.SharedBy.AtSignStr + " - " + err.Error())
+		}
+	}
+
+	if err := encryption_util.NewEncryptionUtil().VerifySHA256RSA(lookupResponse.Data, signature, []byte(ownerPublicKey)); err != nil {
+		return exceptions.NewAtSignatureVerificationException("Failed to verify dataSignature of " + key.String() + " - " + err.Error())
+	}
+	return nil
+}
+
+func decryptSharedValue(lookupResponse *LookupResponse, sharedEncryptionKey string) (string, error) {
+	value, err := decryptValue(lookupResponse.Data, sharedEncryptionKey, lookupResponse.Metadata)
+	if err != nil {
+		return "", exceptions.NewAtDecryptionException("Failed to decrypt value with shared encryption key - " + err.Error())
+	}
+	return value, nil
+}
+
+// Delete removes key from the atServer. Cached keys are deleted from this atSign's cache.
+func (c *AtClient) Delete(key common.AtKey) (*connections.Response, error) {
+	switch key.(type) {
+	case *common.SelfKey, *common.PublicKey, *common.SharedKey, *common.PrivateHiddenKey:
+		command := verb_builder.NewDeleteVerbBuilder().WithAtKey(key).Build()
+		return c.executeCommand(command)
+	}
+	return nil, exceptions.NewAtException("No implementation found for key type: " + reflect.TypeOf(key).String())
+}
+
+// executeRawCommand sends command to the secondary, on a pooled connection if the client has
+// a pool, and returns the unparsed response.
+func (c *AtClient) executeRawCommand(command string) (*connections.Response, error) {
+	if c.pool != nil {
+		return c.pool.ExecuteCommand(command, true)
+	}
+	return c.SecondaryConnection.ExecuteCommand(command, true)
+}
+
+// executeRawCommands pipelines commands to the secondary, at most pipelineWindow at a time,
+// and returns their unparsed responses in the same order. If it fails, the responses read so
+// far are returned with the error.
+func (c *AtClient) executeRawCommands(commands []string) ([]*connections.Response, error) {
+	if len(commands) == 0 {
+		return nil, nil
+	}
+	if c.pool != nil {
+		return c.pool.ExecuteCommandsContext(context.Background(), commands, c.pipelineWindow)
+	}
+	return c.SecondaryConnection.ExecuteCommandsContext(context.Background(), commands, c.pipelineWindow)
+}
+
+// executeCommand sends command to the secondary and returns the parsed response, or the
+// typed exception for the error code returned by the atServer.
+func (c *AtClient) executeCommand(command string) (*connections.Response, error) {
+	rawResponse, err := c.executeRawCommand(command)
+	if err != nil {
+		return nil, exceptions.NewAtSecondaryConnectException("Failed to execute " + command + " - " + err.Error())
+	}
+
+	response, err := connections.ParseRawResponse(rawResponse.GetRawDataResponse())
+	if err != nil {
+		return nil, exceptions.NewAtResponseHandlingException(err.Error())
+	}
+	if response.IsError() {
+		return response, response.GetException()
+	}
+	return response, nil
+}
+
+// encryptValue encrypts value with the AES key keyBase64 in the mode named by metadata.EncAlgo,
+// or the client's mode if it names none, and records the mode and the new IV or nonce in metadata.
+func (c *AtClient) encryptValue(value, keyBase64 string, metadata *common.Metadata) (string, error) {
+	if metadata.EncAlgo == "" {
+		metadata.EncAlgo = c.encryptionMode
+	}
+
+	switch metadata.EncAlgo {
+	case encryption_util.EncryptionModeAesGcm:
+		ciphertext, nonce, err := encryption_util.NewEncryptionUtil().AesGcmEncryptFromBase64(value, keyBase64)
+		if err != nil {
+			return "", err
+		}
+		metadata.IVNonce = nonce
+		return ciphertext, nil
+	case "", encryption_util.EncryptionModeAesCtr:
+		ivBase64, err := encryption_util.NewEncryptionUtil().GenerateIVBase64()
+		if err != nil {
+			return "", err
+		}
+		metadata.IVNonce = ivBase64
+		iv, _ := base64.StdEncoding.DecodeString(ivBase64)
+		return encryption_util.NewEncryptionUtil().AesEncryptFromBase64(value, keyBase64, iv)
+	}
+	return "", fmt.Errorf("unsupported encAlgo %s", metadata.EncAlgo)
+}
+
+// decryptValue decrypts ciphertext with the AES key keyBase64 in the mode recorded in metadata.
+func decryptValue(ciphertext, keyBase64 string, metadata *common.Metadata) (string, error) {
+	if metadata.EncAlgo == encryption_util.EncryptionModeAesGcm {
+		return encryption_util.NewEncryptionUtil().AesGcmDecryptFromBase64(ciphertext, keyBase64, metadata.IVNonce)
+	}
+
+	// Values written before IVs were recorded were encrypted with an all-zero IV
+	iv := make([]byte, aes.BlockSize)
+	if metadata.IVNonce != "" {
+		var err error
+		if iv, err = base64.StdEncoding.DecodeString(metadata.IVNonce); err != nil {
+			return "", fmt.Errorf("invalid ivNonce - %v", err)
+		}
+	}
+	return encryption_util.NewEncryptionUtil().AesDecryptFromBase64(ciphertext, keyBase64, iv)
+}
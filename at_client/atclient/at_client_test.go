@@ -0,0 +1,431 @@
+package atclient_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atsign-foundation/at_go/at_client/atclient"
+	"github.com/atsign-foundation/at_go/at_client/attest"
+	"github.com/atsign-foundation/at_go/at_client/common"
+	"github.com/atsign-foundation/at_go/at_client/connections"
+	"github.com/atsign-foundation/at_go/at_client/exceptions"
+	"github.com/atsign-foundation/at_go/at_client/utils/encryption_util"
+	"github.com/atsign-foundation/at_go/at_client/utils/key_utils"
+)
+
+// newEnvironment starts an attest environment with atSigns onboarded, closed when the test ends.
+func newEnvironment(t *testing.T, atSigns ...string) *attest.Environment {
+	t.Helper()
+	env, err := attest.NewEnvironment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { env.Close() })
+	for _, atSign := range atSigns {
+		if _, err := env.AddAtSign(*common.NewAtSign(atSign)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return env
+}
+
+// newClient returns a client of atSign in env, closed when the test ends.
+func newClient(t *testing.T, env *attest.Environment, atSign string, options ...atclient.AtClientOption) *atclient.AtClient {
+	t.Helper()
+	client, err := env.NewAtClient(*common.NewAtSign(atSign), options...)
+	if err != nil {
+		t.Fatalf("NewAtClient(%s): %v", atSign, err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+// unusedAddress returns a local address nothing is listening on.
+func unusedAddress(t *testing.T) connections.Address {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener.Close()
+	address, err := connections.AddressFromString(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *address
+}
+
+func TestNewAtClientAuthenticatesWithPkam(t *testing.T) {
+	env := newEnvironment(t, "@alice")
+	client := newClient(t, env, "@alice")
+
+	if !client.IsAuthenticated() {
+		t.Error("client is not authenticated")
+	}
+	want := env.AtServer("@alice").Address()
+	if client.SecondaryAddress.String() != want.String() {
+		t.Errorf("SecondaryAddress = %s, want %s", client.SecondaryAddress.String(), want.String())
+	}
+}
+
+func TestNewAtClientReturnsPkamError(t *testing.T) {
+	env := newEnvironment(t, "@alice", "@bob")
+	alice := common.NewAtSign("@alice")
+
+	client, err := env.NewAtClient(*alice, atclient.WithKeys(env.Keys(*common.NewAtSign("@bob"))))
+	if err == nil {
+		client.Close()
+		t.Fatal("NewAtClient succeeded with another atSign's keys")
+	}
+	if client != nil {
+		t.Error("NewAtClient returned a client with its error")
+	}
+}
+
+func TestNewAtClientUnknownAtSign(t *testing.T) {
+	env := newEnvironment(t, "@alice")
+
+	_, err := env.NewAtClient(*common.NewAtSign("@nobody"), atclient.WithKeys(env.Keys(*common.NewAtSign("@alice"))))
+	var notFound *exceptions.AtSecondaryNotFoundException
+	if !errors.As(err, &notFound) {
+		t.Fatalf("NewAtClient(@nobody) error = %v, want AtSecondaryNotFoundException", err)
+	}
+}
+
+func TestNewAtClientReturnsConnectError(t *testing.T) {
+	env := newEnvironment(t, "@alice")
+	dead := unusedAddress(t)
+
+	_, err := env.NewAtClient(*common.NewAtSign("@alice"), atclient.WithSecondaryAddress(dead))
+	var connectErr *exceptions.AtSecondaryConnectException
+	if !errors.As(err, &connectErr) || !strings.Contains(err.Error(), dead.String()) {
+		t.Errorf("NewAtClient error = %v, want AtSecondaryConnectException naming %s", err, dead.String())
+	}
+}
+
+func TestNewAtClientInvalidatesUnreachableSecondary(t *testing.T) {
+	env := newEnvironment(t, "@alice")
+	alice := common.NewAtSign("@alice")
+	resolver := connections.NewRootResolver(env.RootAddress(), false, connections.WithTLSConfig(env.ClientTLSConfig()))
+
+	// The resolver caches an address nothing listens on, and then the atServer "moves" back
+	env.Root.Register(*alice, unusedAddress(t))
+	if _, err := resolver.FindSecondary(context.Background(), *alice); err != nil {
+		t.Fatal(err)
+	}
+	env.Root.Register(*alice, env.AtServer("@alice").Address())
+
+	if client, err := env.NewAtClient(*alice, atclient.WithSecondaryAddressFinder(resolver)); err == nil {
+		client.Close()
+		t.Fatal("NewAtClient connected to an address nothing listens on")
+	}
+	client := newClient(t, env, "@alice", atclient.WithSecondaryAddressFinder(resolver))
+	want := env.AtServer("@alice").Address()
+	if client.SecondaryAddress.String() != want.String() {
+		t.Errorf("SecondaryAddress = %s, want the address looked up again", client.SecondaryAddress.String())
+	}
+	if lookups := env.Root.Lookups(*alice); lookups != 2 {
+		t.Errorf("root server was asked %d times, want twice", lookups)
+	}
+}
+
+func TestOnboardWithCram(t *testing.T) {
+	env := newEnvironment(t)
+	bob := common.NewAtSign("@bob")
+	server, err := env.AddUnonboardedAtSign(*bob, "cram secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := key_utils.NewMemoryKeyStore()
+	options := append(env.ClientOptions(*bob), atclient.WithKeyStore(store))
+
+	if _, err := atclient.Onboard(*bob, env.RootAddress(), "wrong secret", false, options...); err == nil {
+		t.Fatal("Onboard succeeded with the wrong CRAM secret")
+	}
+
+	client, err := atclient.Onboard(*bob, env.RootAddress(), "cram secret", false, options...)
+	if err != nil {
+		t.Fatalf("Onboard: %v", err)
+	}
+	client.Close()
+
+	if _, ok := server.Get("privatekey:at_secret"); ok {
+		t.Error("CRAM secret was not deleted")
+	}
+	if _, ok := server.Get("public:publickey@bob"); !ok {
+		t.Error("encryption public key was not published")
+	}
+
+	keys, err := store.Load(bob.AtSignStr)
+	if err != nil {
+		t.Fatalf("Load saved keys: %v", err)
+	}
+	newClient(t, env, "@bob", atclient.WithKeys(keys))
+}
+
+func TestOnboardWithConnectionPool(t *testing.T) {
+	env := newEnvironment(t)
+	bob := common.NewAtSign("@bob")
+	if _, err := env.AddUnonboardedAtSign(*bob, "cram secret"); err != nil {
+		t.Fatal(err)
+	}
+	options := append(env.ClientOptions(*bob), atclient.WithKeyStore(key_utils.NewMemoryKeyStore()), atclient.WithConnectionPool(2, 3, 0))
+
+	client, err := atclient.Onboard(*bob, env.RootAddress(), "cram secret", false, options...)
+	if err != nil {
+		t.Fatalf("Onboard: %v", err)
+	}
+	t.Cleanup(client.Close)
+	if client.Pool() == nil || client.Pool().Size() != 2 {
+		t.Fatalf("Onboard returned a client without a pool of 2 connections")
+	}
+	if _, err := client.Put(common.NewSelfKey("phone", bob, nil), "value"); err != nil {
+		t.Errorf("Put on the pool: %v", err)
+	}
+}
+
+func TestPutGet(t *testing.T) {
+	env := newEnvironment(t, "@alice", "@bob")
+	alice, bob := common.NewAtSign("@alice"), common.NewAtSign("@bob")
+	aliceClient := newClient(t, env, "@alice")
+	bobClient := newClient(t, env, "@bob")
+
+	tests := []struct {
+		name   string
+		key    common.AtKey
+		reader *atclient.AtClient
+		lookup common.AtKey
+	}{
+		{"self", common.NewSelfKey("self", alice, nil), aliceClient, common.NewSelfKey("self", alice, nil)},
+		{"public", common.NewPublicKey("public", alice), bobClient, common.NewPublicKey("public", alice)},
+		{"public by me", common.NewPublicKey("mine", alice), aliceClient, common.NewPublicKey("mine", alice)},
+		{"shared by me", common.NewSharedKey("shared", alice, bob), aliceClient, common.NewSharedKey("shared", alice, bob)},
+		{"shared with me", common.NewSharedKey("shared", alice, bob), bobClient, common.NewSharedKey("shared", alice, bob)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := "value of " + tt.name
+			if _, err := aliceClient.Put(tt.key, value); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			got, err := tt.reader.Get(tt.lookup)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if got != value {
+				t.Errorf("Get = %q, want %q", got, value)
+			}
+		})
+	}
+}
+
+func TestGetValueWithoutIVNonce(t *testing.T) {
+	env := newEnvironment(t, "@alice")
+	alice := common.NewAtSign("@alice")
+	client := newClient(t, env, "@alice")
+
+	// Values written before ivNonce was recorded were encrypted under an all-zero IV
+	ciphertext, err := encryption_util.NewEncryptionUtil().AesEncryptFromBase64("legacy value",
+		env.Keys(*alice)[key_utils.SelfEncryptionKeyName], make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.AtServer("@alice").Put("legacy@alice", ciphertext, common.Metadata{IsEncrypted: true})
+
+	got, err := client.Get(common.NewSelfKey("legacy", alice, nil))
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got != "legacy value" {
+		t.Errorf("Get = %q, want %q", got, "legacy value")
+	}
+}
+
+func TestGetMissingKey(t *testing.T) {
+	env := newEnvironment(t, "@alice")
+	client := newClient(t, env, "@alice")
+
+	_, err := client.Get(common.NewSelfKey("missing", common.NewAtSign("@alice"), nil))
+	var notFound *exceptions.AtKeyNotFoundException
+	if !errors.As(err, &notFound) {
+		t.Fatalf("Get(missing) error = %v, want AtKeyNotFoundException", err)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	env := newEnvironment(t, "@alice", "@bob")
+	alice, bob := common.NewAtSign("@alice"), common.NewAtSign("@bob")
+	aliceClient := newClient(t, env, "@alice")
+	bobClient := newClient(t, env, "@bob")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	notifications, err := bobClient.Monitor(ctx, "message")
+	if err != nil {
+		t.Fatalf("Monitor: %v", err)
+	}
+
+	if _, err := aliceClient.Notify(common.NewSharedKey("message", alice, bob), "hello bob", nil); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	select {
+	case notification, ok := <-notifications:
+		if !ok {
+			t.Fatal("notifications closed before the notification arrived")
+		}
+		if notification.From != "@alice" {
+			t.Errorf("From = %q, want @alice", notification.From)
+		}
+		if notification.DecryptionError != nil {
+			t.Fatalf("DecryptionError: %v", notification.DecryptionError)
+		}
+		if notification.DecryptedValue != "hello bob" {
+			t.Errorf("DecryptedValue = %q, want %q", notification.DecryptedValue, "hello bob")
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the notification")
+	}
+}
+
+func TestPutRecordsFreshIVNonce(t *testing.T) {
+	env := newEnvironment(t, "@alice")
+	alice := common.NewAtSign("@alice")
+	client := newClient(t, env, "@alice")
+
+	var ivNonces, ciphertexts []string
+	for i := 0; i < 2; i++ {
+		if _, err := client.Put(common.NewSelfKey("self", alice, nil), "same value"); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		record, ok := env.AtServer("@alice").Get("self@alice")
+		if !ok {
+			t.Fatal("self@alice was not stored")
+		}
+		if !record.Metadata.IsEncrypted || record.Metadata.IVNonce == "" {
+			t.Fatalf("metadata = %+v, want isEncrypted and an ivNonce", record.Metadata)
+		}
+		ivNonces = append(ivNonces, record.Metadata.IVNonce)
+		ciphertexts = append(ciphertexts, record.Value)
+	}
+	if ivNonces[0] == ivNonces[1] || ciphertexts[0] == ciphertexts[1] {
+		t.Error("two Puts of the same value reused the IV")
+	}
+}
+
+func TestGetVerifiesPublicValueSignature(t *testing.T) {
+	env := newEnvironment(t, "@alice", "@bob")
+	alice := common.NewAtSign("@alice")
+	aliceClient := newClient(t, env, "@alice")
+	bobClient := newClient(t, env, "@bob")
+	strictClient := newClient(t, env, "@bob", atclient.WithRequireSignatures())
+
+	if _, err := aliceClient.Put(common.NewPublicKey("signed", alice), "signed value"); err != nil {
+		t.Fatal(err)
+	}
+	signed, ok := env.AtServer("@alice").Get("public:signed@alice")
+	if !ok || signed.Metadata.DataSignature == "" {
+		t.Fatalf("Put stored %+v, want a dataSignature", signed)
+	}
+	env.AtServer("@alice").Put("public:tampered@alice", "tampered value", signed.Metadata)
+	env.AtServer("@alice").Put("public:unsigned@alice", "unsigned value", common.Metadata{})
+
+	tests := []struct {
+		name    string
+		reader  *atclient.AtClient
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{"valid signature", bobClient, "signed", "signed value", false},
+		{"valid signature, signatures required", strictClient, "signed", "signed value", false},
+		{"valid signature, read by owner", aliceClient, "signed", "signed value", false},
+		{"tampered value", bobClient, "tampered", "", true},
+		{"tampered value, read by owner", aliceClient, "tampered", "", true},
+		{"missing signature", bobClient, "unsigned", "unsigned value", false},
+		{"missing signature, signatures required", strictClient, "unsigned", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.reader.Get(common.NewPublicKey(tt.key, alice))
+			if tt.wantErr {
+				var verificationErr *exceptions.AtSignatureVerificationException
+				if !errors.As(err, &verificationErr) {
+					t.Fatalf("Get error = %v, want AtSignatureVerificationException", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Get = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	env := newEnvironment(t, "@alice", "@bob")
+	alice, bob := common.NewAtSign("@alice"), common.NewAtSign("@bob")
+	aliceClient := newClient(t, env, "@alice")
+	bobClient := newClient(t, env, "@bob")
+
+	tests := []struct {
+		name   string
+		server string
+		stored string
+		client *atclient.AtClient
+		key    common.AtKey
+	}{
+		{"self", "@alice", "phone@alice", aliceClient, common.NewSelfKey("phone", alice, nil)},
+		{"public", "@alice", "public:location@alice", aliceClient, common.NewPublicKey("location", alice)},
+		{"shared", "@alice", "@bob:message@alice", aliceClient, common.NewSharedKey("message", alice, bob)},
+		{"cached public", "@bob", "cached:public:location@alice", bobClient, common.NewPublicKey("location", alice).Cache(60, false)},
+		{"cached shared", "@bob", "cached:@bob:message@alice", bobClient, common.NewSharedKey("message", alice, bob).Cache(60, false)},
+		{"private hidden", "@alice", "_secret@alice", aliceClient, common.NewPrivateHiddenKey("_secret", alice)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := env.AtServer(tt.server)
+			server.Put(tt.stored, "value", common.Metadata{})
+
+			if _, err := tt.client.Delete(tt.key); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if _, ok := server.Get(tt.stored); ok {
+				t.Errorf("%s is still stored", tt.stored)
+			}
+
+			// Deleting it again fails with AT0015
+			_, err := tt.client.Delete(tt.key)
+			var notFound *exceptions.AtKeyNotFoundException
+			if !errors.As(err, &notFound) {
+				t.Errorf("second Delete error = %v, want AtKeyNotFoundException", err)
+			}
+		})
+	}
+}
+
+func TestDeleteLeavesOtherKeys(t *testing.T) {
+	env := newEnvironment(t, "@alice", "@bob")
+	alice, bob := common.NewAtSign("@alice"), common.NewAtSign("@bob")
+	client := newClient(t, env, "@alice")
+	server := env.AtServer("@alice")
+	for _, stored := range []string{"location@alice", "public:location@alice", "@bob:location@alice"} {
+		server.Put(stored, "value", common.Metadata{})
+	}
+
+	if _, err := client.Delete(common.NewSharedKey("location", alice, bob)); err != nil {
+		t.Fatal(err)
+	}
+	for _, stored := range []string{"location@alice", "public:location@alice"} {
+		if _, ok := server.Get(stored); !ok {
+			t.Errorf("deleting @bob:location@alice also deleted %s", stored)
+		}
+	}
+}
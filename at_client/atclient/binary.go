@@ -0,0 +1,64 @@
+package atclient
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+
+	"github.com/atsign-foundation/at_go/at_client/common"
+	"github.com/atsign-foundation/at_go/at_client/connections"
+	"github.com/atsign-foundation/at_go/at_client/exceptions"
+)
+
+// DefaultMaxValueSize is the default limit, in bytes as sent to the atServer, on values put by
+// the client.
+const DefaultMaxValueSize = 512 * 1024
+
+// EncodingBase64 in Metadata.Encoding marks a value that is base64 encoded, before any
+// encryption. The cipher, if any, is recorded separately in Metadata.EncAlgo.
+const EncodingBase64 = "base64"
+
+// PutBytes stores value under key. Binary values are base64 encoded before they are encrypted
+// (self and shared keys) or sent as is (public keys), and are marked isBinary and base64
+// encoded so that other clients decode them.
+func (c *AtClient) PutBytes(key common.AtKey, value []byte) (*connections.Response, error) {
+	metadata := key.GetMetadata()
+	metadata.IsBinary = true
+	metadata.Encoding = EncodingBase64
+
+	switch key.(type) {
+	case *common.SelfKey, *common.SharedKey, *common.PublicKey:
+	default:
+		return nil, exceptions.NewAtException("No implementation found for key type: " + reflect.TypeOf(key).String())
+	}
+
+	return c.Put(key, base64.StdEncoding.EncodeToString(value))
+}
+
+// GetBytes fetches and decrypts the value of key like Get. Values marked isBinary are base64
+// decoded; any other value is returned as its bytes.
+func (c *AtClient) GetBytes(key common.AtKey) ([]byte, error) {
+	value, err := c.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if !key.GetMetadata().IsBinary {
+		return []byte(value), nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, exceptions.NewAtDecryptionException("Failed to base64 decode binary value of " + key.String() + " - " + err.Error())
+	}
+	return decoded, nil
+}
+
+// checkValueSize fails with an AtBufferOverFlowException when value, as it will be sent to the
+// atServer, is larger than the client's limit.
+func (c *AtClient) checkValueSize(key common.AtKey, value string) error {
+	if c.maxValueSize > 0 && len(value) > c.maxValueSize {
+		return exceptions.NewAtBufferOverFlowException(fmt.Sprintf(
+			"Value of %s is %d bytes, more than the limit of %d bytes", key.String(), len(value), c.maxValueSize))
+	}
+	return nil
+}
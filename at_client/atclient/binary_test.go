@@ -0,0 +1,141 @@
+package atclient_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/atsign-foundation/at_go/at_client/atclient"
+	"github.com/atsign-foundation/at_go/at_client/common"
+	"github.com/atsign-foundation/at_go/at_client/exceptions"
+)
+
+// allBytes returns every byte value, which is not valid UTF-8.
+func allBytes() []byte {
+	value := make([]byte, 256)
+	for i := range value {
+		value[i] = byte(i)
+	}
+	return value
+}
+
+func TestPutBytesGetBytes(t *testing.T) {
+	env := newEnvironment(t, "@alice", "@bob")
+	alice, bob := common.NewAtSign("@alice"), common.NewAtSign("@bob")
+	aliceClient := newClient(t, env, "@alice")
+	bobClient := newClient(t, env, "@bob")
+	value := allBytes()
+
+	tests := []struct {
+		name   string
+		key    common.AtKey
+		reader *atclient.AtClient
+		lookup common.AtKey
+	}{
+		{"self", common.NewSelfKey("self", alice, nil), aliceClient, common.NewSelfKey("self", alice, nil)},
+		{"public", common.NewPublicKey("public", alice), bobClient, common.NewPublicKey("public", alice)},
+		{"shared", common.NewSharedKey("shared", alice, bob), bobClient, common.NewSharedKey("shared", alice, bob)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := aliceClient.PutBytes(tt.key, value); err != nil {
+				t.Fatalf("PutBytes: %v", err)
+			}
+			record, _ := env.AtServer("@alice").Get(tt.key.String())
+			if !record.Metadata.IsBinary || record.Metadata.Encoding != atclient.EncodingBase64 {
+				t.Errorf("stored metadata has isBinary %v and encoding %q, want true and %q",
+					record.Metadata.IsBinary, record.Metadata.Encoding, atclient.EncodingBase64)
+			}
+
+			got, err := tt.reader.GetBytes(tt.lookup)
+			if err != nil {
+				t.Fatalf("GetBytes: %v", err)
+			}
+			if !bytes.Equal(got, value) {
+				t.Errorf("GetBytes = %v, want %v", got, value)
+			}
+		})
+	}
+}
+
+func TestPublicBytesAreSentBase64Encoded(t *testing.T) {
+	env := newEnvironment(t, "@alice")
+	client := newClient(t, env, "@alice")
+	key := common.NewPublicKey("public", common.NewAtSign("@alice"))
+
+	if _, err := client.PutBytes(key, allBytes()); err != nil {
+		t.Fatal(err)
+	}
+	record, _ := env.AtServer("@alice").Get(key.String())
+	if record.Value != base64.StdEncoding.EncodeToString(allBytes()) {
+		t.Errorf("stored value %q is not the base64 encoding of the bytes", record.Value)
+	}
+}
+
+func TestGetBytesOfTextValue(t *testing.T) {
+	env := newEnvironment(t, "@alice")
+	client := newClient(t, env, "@alice")
+	key := common.NewSelfKey("text", common.NewAtSign("@alice"), nil)
+	if _, err := client.Put(key, "aGVsbG8="); err != nil {
+		t.Fatal(err)
+	}
+
+	// A value that is not marked isBinary is not decoded, even if it looks like base64
+	got, err := client.GetBytes(common.NewSelfKey("text", common.NewAtSign("@alice"), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "aGVsbG8=" {
+		t.Errorf("GetBytes = %q, want the value as put", got)
+	}
+}
+
+// checkValueSizeLimit checks that client puts a public value that is limit bytes once base64
+// encoded, and rejects one three bytes longer with an AtBufferOverFlowException.
+func checkValueSizeLimit(t *testing.T, client *atclient.AtClient, limit int) {
+	t.Helper()
+	alice := common.NewAtSign("@alice")
+
+	// Every 3 bytes are encoded as 4
+	atLimit := make([]byte, limit/4*3)
+	if _, err := client.PutBytes(common.NewPublicKey("at_limit", alice), atLimit); err != nil {
+		t.Errorf("PutBytes of a value of %d bytes encoded: %v", limit, err)
+	}
+
+	overLimit := make([]byte, len(atLimit)+3)
+	_, err := client.PutBytes(common.NewPublicKey("over_limit", alice), overLimit)
+	var overflow *exceptions.AtBufferOverFlowException
+	if !errors.As(err, &overflow) {
+		t.Errorf("PutBytes of a value of %d bytes encoded error = %v, want AtBufferOverFlowException", limit+4, err)
+	}
+}
+
+func TestPutBytesDefaultMaxValueSize(t *testing.T) {
+	env := newEnvironment(t, "@alice")
+	client := newClient(t, env, "@alice")
+
+	checkValueSizeLimit(t, client, atclient.DefaultMaxValueSize)
+	if _, ok := env.AtServer("@alice").Get("public:over_limit@alice"); ok {
+		t.Error("the value over the limit was sent to the atServer")
+	}
+}
+
+func TestPutBytesWithMaxValueSize(t *testing.T) {
+	env := newEnvironment(t, "@alice", "@bob")
+	alice, bob := common.NewAtSign("@alice"), common.NewAtSign("@bob")
+	client := newClient(t, env, "@alice", atclient.WithMaxValueSize(100))
+
+	checkValueSizeLimit(t, client, 100)
+
+	// The limit applies to encrypted values once encrypted
+	var overflow *exceptions.AtBufferOverFlowException
+	for _, key := range []common.AtKey{common.NewSelfKey("self", alice, nil), common.NewSharedKey("shared", alice, bob)} {
+		if _, err := client.PutBytes(key, make([]byte, 60)); !errors.As(err, &overflow) {
+			t.Errorf("PutBytes(%s) error = %v, want AtBufferOverFlowException", key.String(), err)
+		}
+		if _, err := client.Put(key, "small"); err != nil {
+			t.Errorf("Put(%s) of a small value: %v", key.String(), err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package atclient_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/atsign-foundation/at_go/at_client/common"
+)
+
+// TestConcurrentPutGetNotify shares one client between goroutines while its atSign is
+// monitoring, so that it fails under go test -race if any state is unguarded.
+func TestConcurrentPutGetNotify(t *testing.T) {
+	env := newEnvironment(t, "@alice", "@bob")
+	alice, bob := common.NewAtSign("@alice"), common.NewAtSign("@bob")
+	aliceClient := newClient(t, env, "@alice")
+	bobClient := newClient(t, env, "@bob")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	aliceNotifications, err := aliceClient.Monitor(ctx, "reply")
+	if err != nil {
+		t.Fatalf("Monitor: %v", err)
+	}
+	bobNotifications, err := bobClient.Monitor(ctx, "message")
+	if err != nil {
+		t.Fatalf("Monitor: %v", err)
+	}
+
+	const goroutines, iterations = 8, 5
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines*iterations*4)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				name := fmt.Sprintf("key%d-%d", g, i)
+				value := fmt.Sprintf("value %d %d", g, i)
+
+				for _, key := range []common.AtKey{
+					common.NewSelfKey(name, alice, nil),
+					common.NewSharedKey(name, alice, bob),
+				} {
+					if _, err := aliceClient.Put(key, value); err != nil {
+						errs <- fmt.Errorf("Put(%s): %w", key.String(), err)
+						continue
+					}
+					got, err := aliceClient.Get(key)
+					if err != nil {
+						errs <- fmt.Errorf("Get(%s): %w", key.String(), err)
+					} else if got != value {
+						errs <- fmt.Errorf("Get(%s) = %q, want %q", key.String(), got, value)
+					}
+				}
+
+				if _, err := aliceClient.Notify(common.NewSharedKey("message"+name, alice, bob), value, nil); err != nil {
+					errs <- fmt.Errorf("Notify: %w", err)
+				}
+			}
+		}(g)
+	}
+
+	// bob replies to each message while alice is still busy
+	received := 0
+	for received < goroutines*iterations {
+		select {
+		case notification := <-bobNotifications:
+			if notification.DecryptionError != nil {
+				t.Fatalf("DecryptionError: %v", notification.DecryptionError)
+			}
+			received++
+			if _, err := bobClient.Notify(common.NewSharedKey("reply", bob, alice), notification.DecryptedValue, nil); err != nil {
+				t.Fatalf("Notify reply: %v", err)
+			}
+		case <-aliceNotifications:
+		case <-ctx.Done():
+			t.Fatalf("timed out after %d of %d notifications", received, goroutines*iterations)
+		}
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,349 @@
+package atclient
+
+import (
+	"context"
+	"crypto/aes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/atsign-foundation/at_go/at_client/common"
+	"github.com/atsign-foundation/at_go/at_client/connections"
+	"github.com/atsign-foundation/at_go/at_client/exceptions"
+	"github.com/atsign-foundation/at_go/at_client/utils/encryption_util"
+	"github.com/atsign-foundation/at_go/at_client/utils/key_utils"
+	"github.com/atsign-foundation/at_go/at_client/utils/verb_builder"
+)
+
+const (
+	defaultEncryptionPrivateKeyName = "default_enc_private_key"
+	defaultSelfEncryptionKeyName    = "default_self_enc_key"
+	enrollmentKeySuffix             = ".new.enrollments.__manage"
+)
+
+// EnrollmentRequest describes the access a device asks for when it enrolls with Enroll.
+type EnrollmentRequest struct {
+	AppName    string
+	DeviceName string
+	// Namespaces maps each namespace to the access requested, "r" or "rw".
+	Namespaces map[string]string
+	// OTP is a one-time password generated by an approving client with GenerateOTP.
+	OTP string
+}
+
+// Enrollment is an enrollment as returned by ListEnrollments.
+type Enrollment struct {
+	Id                         string            `json:"-"`
+	AppName                    string            `json:"appName"`
+	DeviceName                 string            `json:"deviceName"`
+	Namespaces                 map[string]string `json:"namespace"`
+	Status                     string            `json:"status"`
+	EncryptedAPKAMSymmetricKey string            `json:"encryptedAPKAMSymmetricKey"`
+}
+
+// PendingEnrollment is an enrollment submitted by Enroll that is waiting to be approved.
+type PendingEnrollment struct {
+	AtSign       common.AtSign
+	EnrollmentId string
+	Status       string
+
+	// Keys holds the enrollment's APKAM key pair, APKAM symmetric key and the atSign's
+	// encryption public key. Complete adds the encryption private key and self encryption key.
+	Keys map[string]string
+
+	rootAddress       connections.Address
+	secondaryAddress  connections.Address
+	verbose           bool
+	options           []AtClientOption
+	connectionOptions []connections.ConnectionOption
+	keyStore          key_utils.KeyStore
+}
+
+// Enroll asks the atServer of atsign to let this device authenticate with its own APKAM key
+// pair. The request must be approved by a client of the atSign, with ApproveEnrollment,
+// before PendingEnrollment.Complete can authenticate.
+func Enroll(atsign common.AtSign, rootAddress connections.Address, request EnrollmentRequest, verbose bool, options ...AtClientOption) (*PendingEnrollment, error) {
+	if rootAddress.String() == ":0" {
+		rootAddress = *connections.DefaultRootAddress()
+	}
+
+	client := &AtClient{
+		AtSign:      atsign,
+		RootAddress: rootAddress,
+		Verbose:     verbose,
+	}
+	for _, option := range options {
+		option(client)
+	}
+	if client.getKeyStore().Exists(atsign.AtSignStr) {
+		return nil, exceptions.NewAtIllegalArgumentException("Keys for " + atsign.AtSignStr + " already exist")
+	}
+	if err := client.findSecondary(); err != nil {
+		return nil, err
+	}
+	if err := client.connectSecondary(); err != nil {
+		return nil, err
+	}
+	defer client.SecondaryConnection.AtConnection.Disconnect()
+
+	var what = "look up encryption public key"
+	command := verb_builder.NewLookupVerbBuilder().WithAtKey(common.NewPublicKey("publickey", &atsign), verb_builder.LookupTypeNone).Build()
+	response, err := client.executeCommand(command)
+	if err != nil {
+		return nil, exceptions.NewAtException("Failed to " + what + " - " + err.Error())
+	}
+	encryptionPublicKey := response.GetRawDataResponse()
+
+	what = "generate APKAM keys"
+	encryptionUtil := encryption_util.NewEncryptionUtil()
+	apkamPrivateKey, apkamPublicKey, err := encryptionUtil.GenerateRSAKeyPair()
+	if err != nil {
+		return nil, exceptions.NewAtException("Failed to " + what + " - " + err.Error())
+	}
+	apkamSymmetricKey, err := encryptionUtil.GenerateAESKeyBase64()
+	if err != nil {
+		return nil, exceptions.NewAtException("Failed to " + what + " - " + err.Error())
+	}
+
+	what = "encrypt APKAM symmetric key with encryption public key"
+	encryptedAPKAMSymmetricKey, err := encryptionUtil.RsaEncryptToBase64(apkamSymmetricKey, []byte(encryptionPublicKey))
+	if err != nil {
+		return nil, exceptions.NewAtEncryptionException("Failed to " + what + " - " + err.Error())
+	}
+
+	what = "submit enrollment request"
+	apkamPublicKeyBase64 := base64.StdEncoding.EncodeToString(apkamPublicKey)
+	command = verb_builder.NewEnrollVerbBuilder().
+		SetOperation(verb_builder.EnrollOperationRequest).
+		SetAppName(request.AppName).
+		SetDeviceName(request.DeviceName).
+		SetNamespaces(request.Namespaces).
+		SetOTP(request.OTP).
+		SetApkamPublicKey(apkamPublicKeyBase64).
+		SetEncryptedAPKAMSymmetricKey(encryptedAPKAMSymmetricKey).
+		Build()
+	response, err = client.executeCommand(command)
+	if err != nil {
+		return nil, exceptions.NewAtException("Failed to " + what + " - " + err.Error())
+	}
+
+	var result struct {
+		EnrollmentId string `json:"enrollmentId"`
+		Status       string `json:"status"`
+	}
+	if err := json.Unmarshal([]byte(response.GetRawDataResponse()), &result); err != nil {
+		return nil, exceptions.NewAtResponseHandlingException("Failed to parse enrollment response - " + err.Error())
+	}
+
+	return &PendingEnrollment{
+		AtSign:       atsign,
+		EnrollmentId: result.EnrollmentId,
+		Status:       result.Status,
+		Keys: map[string]string{
+			key_utils.PkamPublicKeyName:       apkamPublicKeyBase64,
+			key_utils.PkamPrivateKeyName:      base64.StdEncoding.EncodeToString(apkamPrivateKey),
+			key_utils.EncryptionPublicKeyName: encryptionPublicKey,
+			key_utils.ApkamSymmetricKeyName:   apkamSymmetricKey,
+			key_utils.EnrollmentIdName:        result.EnrollmentId,
+		},
+		rootAddress:       rootAddress,
+		secondaryAddress:  client.SecondaryAddress,
+		verbose:           verbose,
+		options:           options,
+		connectionOptions: client.connectionOptions,
+		keyStore:          client.getKeyStore(),
+	}, nil
+}
+
+// Complete waits, trying every pollInterval, until the enrollment has been approved and this
+// device can authenticate with its APKAM key. It then fetches the atSign's encryption private
+// key and self encryption key, saves all the keys to ~/.atsign/keys/@alice_key.atKeys (or the
+// store given with WithKeyStore) and returns a client authenticated with them. Complete gives
+// up when ctx is done or the enrollment is denied or revoked.
+func (e *PendingEnrollment) Complete(ctx context.Context, pollInterval time.Duration) (*AtClient, error) {
+	client := &AtClient{
+		AtSign:            e.AtSign,
+		RootAddress:       e.rootAddress,
+		SecondaryAddress:  e.secondaryAddress,
+		Keys:              e.Keys,
+		Verbose:           e.verbose,
+		connectionOptions: e.connectionOptions,
+	}
+
+	for {
+		conn := connections.NewAtConnection(e.secondaryAddress.Host(), e.secondaryAddress.Port(), ctx, e.verbose, e.connectionOptions...)
+		err := conn.Connect()
+		if err == nil {
+			client.SecondaryConnection = &connections.AtSecondaryConnection{AtConnection: conn, Address: e.secondaryAddress}
+			err = client.authenticate(conn)
+			if err == nil {
+				break
+			}
+			conn.Disconnect()
+
+			var apkamErr *exceptions.AtApkamAuthenticationException
+			if errors.As(err, &apkamErr) {
+				switch apkamErr.EnrollmentStatus {
+				case verb_builder.EnrollmentStatusDenied, verb_builder.EnrollmentStatusRevoked:
+					return nil, exceptions.NewAtUnauthenticatedException("Enrollment " + e.EnrollmentId + " was not approved - " + err.Error())
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, exceptions.NewAtTimeoutException("Timed out waiting for enrollment " + e.EnrollmentId + " to be approved - " + err.Error())
+		case <-time.After(pollInterval):
+		}
+	}
+	defer client.SecondaryConnection.AtConnection.Disconnect()
+	e.Status = verb_builder.EnrollmentStatusApproved
+
+	var what = "fetch encryption private key"
+	encryptionPrivateKey, err := e.fetchKey(client, defaultEncryptionPrivateKeyName)
+	if err != nil {
+		return nil, exceptions.NewAtException("Failed to " + what + " - " + err.Error())
+	}
+	e.Keys[key_utils.EncryptionPrivateKeyName] = encryptionPrivateKey
+
+	what = "fetch self encryption key"
+	selfEncryptionKey, err := e.fetchKey(client, defaultSelfEncryptionKeyName)
+	if err != nil {
+		return nil, exceptions.NewAtException("Failed to " + what + " - " + err.Error())
+	}
+	e.Keys[key_utils.SelfEncryptionKeyName] = selfEncryptionKey
+
+	what = "save keys"
+	if err := e.keyStore.Save(e.AtSign.AtSignStr, e.Keys); err != nil {
+		return nil, exceptions.NewAtException("Failed to " + what + " - " + err.Error())
+	}
+
+	options := append([]AtClientOption{}, e.options...)
+	options = append(options, WithKeys(e.Keys), WithSecondaryAddress(e.secondaryAddress))
+	return NewAtClient(e.AtSign, e.rootAddress, e.verbose, options...)
+}
+
+// fetchKey returns a key that the approver encrypted for this enrollment with the APKAM
+// symmetric key.
+func (e *PendingEnrollment) fetchKey(client *AtClient, keyName string) (string, error) {
+	command := verb_builder.NewKeysVerbBuilder().SetKeyName(e.EnrollmentId + "." + keyName + ".__manage" + e.AtSign.AtSignStr).Build()
+	response, err := client.executeCommand(command)
+	if err != nil {
+		return "", err
+	}
+
+	var key struct {
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal([]byte(response.GetRawDataResponse()), &key); err != nil {
+		return "", exceptions.NewAtResponseHandlingException("Failed to parse " + keyName + " - " + err.Error())
+	}
+
+	iv := make([]byte, aes.BlockSize) // zero iv
+	value, err := encryption_util.NewEncryptionUtil().AesDecryptFromBase64(key.Value, e.Keys[key_utils.ApkamSymmetricKeyName], iv)
+	if err != nil {
+		return "", exceptions.NewAtDecryptionException("Failed to decrypt " + keyName + " with APKAM symmetric key - " + err.Error())
+	}
+	return value, nil
+}
+
+// GenerateOTP returns a one-time password that a new device passes in its EnrollmentRequest.
+func (c *AtClient) GenerateOTP() (string, error) {
+	response, err := c.executeCommand(verb_builder.NewOTPVerbBuilder().Build())
+	if err != nil {
+		return "", err
+	}
+	return response.GetRawDataResponse(), nil
+}
+
+// ListEnrollments returns the enrollments of this atSign, optionally only those with one of
+// statuses (e.g. verb_builder.EnrollmentStatusPending).
+func (c *AtClient) ListEnrollments(statuses ...string) ([]Enrollment, error) {
+	command := verb_builder.NewEnrollVerbBuilder().SetOperation(verb_builder.EnrollOperationList).SetStatusFilter(statuses).Build()
+	response, err := c.executeCommand(command)
+	if err != nil {
+		return nil, err
+	}
+
+	var enrollmentsByKey map[string]Enrollment
+	if err := json.Unmarshal([]byte(response.GetRawDataResponse()), &enrollmentsByKey); err != nil {
+		return nil, exceptions.NewAtResponseHandlingException("Failed to parse enrollments - " + err.Error())
+	}
+
+	enrollments := make([]Enrollment, 0, len(enrollmentsByKey))
+	for key, enrollment := range enrollmentsByKey {
+		enrollment.Id = key
+		if end := strings.Index(key, enrollmentKeySuffix); end > -1 {
+			enrollment.Id = key[:end]
+		}
+		enrollments = append(enrollments, enrollment)
+	}
+	return enrollments, nil
+}
+
+// ApproveEnrollment approves a pending enrollment, sharing this atSign's encryption private key
+// and self encryption key with the enrolled device.
+func (c *AtClient) ApproveEnrollment(enrollmentId string) error {
+	enrollment, err := c.getEnrollment(enrollmentId)
+	if err != nil {
+		return err
+	}
+
+	var what = "decrypt APKAM symmetric key"
+	encryptionUtil := encryption_util.NewEncryptionUtil()
+	apkamSymmetricKey, err := encryptionUtil.RsaDecryptFromBase64(enrollment.EncryptedAPKAMSymmetricKey, []byte(c.Keys[key_utils.EncryptionPrivateKeyName]))
+	if err != nil {
+		return exceptions.NewAtDecryptionException("Failed to " + what + " - " + err.Error())
+	}
+
+	iv := make([]byte, aes.BlockSize) // zero iv
+	what = "encrypt encryption private key with APKAM symmetric key"
+	encryptedEncryptionPrivateKey, err := encryptionUtil.AesEncryptFromBase64(c.Keys[key_utils.EncryptionPrivateKeyName], apkamSymmetricKey, iv)
+	if err != nil {
+		return exceptions.NewAtEncryptionException("Failed to " + what + " - " + err.Error())
+	}
+
+	what = "encrypt self encryption key with APKAM symmetric key"
+	encryptedSelfEncryptionKey, err := encryptionUtil.AesEncryptFromBase64(c.Keys[key_utils.SelfEncryptionKeyName], apkamSymmetricKey, iv)
+	if err != nil {
+		return exceptions.NewAtEncryptionException("Failed to " + what + " - " + err.Error())
+	}
+
+	command := verb_builder.NewEnrollVerbBuilder().
+		SetOperation(verb_builder.EnrollOperationApprove).
+		SetEnrollmentId(enrollmentId).
+		SetEncryptedDefaultEncryptionPrivateKey(encryptedEncryptionPrivateKey).
+		SetEncryptedDefaultSelfEncryptionKey(encryptedSelfEncryptionKey).
+		Build()
+	_, err = c.executeCommand(command)
+	return err
+}
+
+// DenyEnrollment denies a pending enrollment.
+func (c *AtClient) DenyEnrollment(enrollmentId string) error {
+	command := verb_builder.NewEnrollVerbBuilder().SetOperation(verb_builder.EnrollOperationDeny).SetEnrollmentId(enrollmentId).Build()
+	_, err := c.executeCommand(command)
+	return err
+}
+
+// RevokeEnrollment stops an approved enrollment from authenticating.
+func (c *AtClient) RevokeEnrollment(enrollmentId string) error {
+	command := verb_builder.NewEnrollVerbBuilder().SetOperation(verb_builder.EnrollOperationRevoke).SetEnrollmentId(enrollmentId).Build()
+	_, err := c.executeCommand(command)
+	return err
+}
+
+func (c *AtClient) getEnrollment(enrollmentId string) (*Enrollment, error) {
+	enrollments, err := c.ListEnrollments(verb_builder.EnrollmentStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	for _, enrollment := range enrollments {
+		if enrollment.Id == enrollmentId {
+			return &enrollment, nil
+		}
+	}
+	return nil, exceptions.NewAtIllegalArgumentException("No pending enrollment with id " + enrollmentId)
+}
@@ -0,0 +1,257 @@
+package atclient_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atsign-foundation/at_go/at_client/atclient"
+	"github.com/atsign-foundation/at_go/at_client/attest"
+	"github.com/atsign-foundation/at_go/at_client/common"
+	"github.com/atsign-foundation/at_go/at_client/connections"
+	"github.com/atsign-foundation/at_go/at_client/exceptions"
+	"github.com/atsign-foundation/at_go/at_client/utils/key_utils"
+	"github.com/atsign-foundation/at_go/at_client/utils/verb_builder"
+)
+
+// enroll submits an enrollment for @alice with an OTP from approver, saving the keys to store.
+func enroll(t *testing.T, env *attest.Environment, approver *atclient.AtClient, store key_utils.KeyStore, options ...atclient.AtClientOption) *atclient.PendingEnrollment {
+	t.Helper()
+	otp, err := approver.GenerateOTP()
+	if err != nil {
+		t.Fatalf("GenerateOTP: %v", err)
+	}
+	request := atclient.EnrollmentRequest{AppName: "buzz", DeviceName: "phone", Namespaces: map[string]string{"buzz": "rw"}, OTP: otp}
+	options = append([]atclient.AtClientOption{
+		atclient.WithConnectionOptions(connections.WithTLSConfig(env.ClientTLSConfig())), atclient.WithKeyStore(store),
+	}, options...)
+	pending, err := atclient.Enroll(*common.NewAtSign("@alice"), env.RootAddress(), request, false, options...)
+	if err != nil {
+		t.Fatalf("Enroll: %v", err)
+	}
+	return pending
+}
+
+// completeSoon completes pending, polling often and giving up after a few seconds.
+func completeSoon(pending *atclient.PendingEnrollment) (*atclient.AtClient, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return pending.Complete(ctx, 10*time.Millisecond)
+}
+
+func TestEnrollApproveComplete(t *testing.T) {
+	env := newEnvironment(t, "@alice")
+	alice := common.NewAtSign("@alice")
+	approver := newClient(t, env, "@alice")
+	store := key_utils.NewMemoryKeyStore()
+	pending := enroll(t, env, approver, store)
+	if pending.Status != verb_builder.EnrollmentStatusPending {
+		t.Errorf("Status = %q, want pending", pending.Status)
+	}
+
+	enrollments, err := approver.ListEnrollments(verb_builder.EnrollmentStatusPending)
+	if err != nil {
+		t.Fatalf("ListEnrollments: %v", err)
+	}
+	if len(enrollments) != 1 || enrollments[0].Id != pending.EnrollmentId || enrollments[0].AppName != "buzz" ||
+		enrollments[0].DeviceName != "phone" || enrollments[0].Namespaces["buzz"] != "rw" {
+		t.Fatalf("ListEnrollments = %+v, want the pending enrollment %s", enrollments, pending.EnrollmentId)
+	}
+
+	if err := approver.ApproveEnrollment(pending.EnrollmentId); err != nil {
+		t.Fatalf("ApproveEnrollment: %v", err)
+	}
+	client, err := completeSoon(pending)
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	t.Cleanup(client.Close)
+
+	// The keys fetched with keys:get decrypt to the approver's
+	for _, name := range []string{key_utils.EncryptionPrivateKeyName, key_utils.SelfEncryptionKeyName} {
+		if client.Keys[name] != approver.Keys[name] {
+			t.Errorf("enrolled client's %s differs from the approver's", name)
+		}
+	}
+	saved, err := store.Load(alice.AtSignStr)
+	if err != nil {
+		t.Fatalf("Load saved keys: %v", err)
+	}
+	if saved[key_utils.EnrollmentIdName] != pending.EnrollmentId {
+		t.Errorf("saved enrollment id %q, want %q", saved[key_utils.EnrollmentIdName], pending.EnrollmentId)
+	}
+
+	if _, err := approver.Put(common.NewSelfKey("secret", alice, nil), "approver's value"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := client.Get(common.NewSelfKey("secret", alice, nil)); err != nil || got != "approver's value" {
+		t.Errorf("enrolled client Get = %q, %v, want the approver's value", got, err)
+	}
+
+	approved, err := approver.ListEnrollments(verb_builder.EnrollmentStatusApproved)
+	if err != nil || len(approved) != 1 {
+		t.Errorf("ListEnrollments(approved) = %+v, %v, want the enrollment", approved, err)
+	}
+}
+
+func TestEnrollWithInvalidOTP(t *testing.T) {
+	env := newEnvironment(t, "@alice")
+	request := atclient.EnrollmentRequest{AppName: "buzz", DeviceName: "phone", OTP: "WRONG"}
+	_, err := atclient.Enroll(*common.NewAtSign("@alice"), env.RootAddress(), request, false,
+		atclient.WithConnectionOptions(connections.WithTLSConfig(env.ClientTLSConfig())), atclient.WithKeyStore(key_utils.NewMemoryKeyStore()))
+	if err == nil {
+		t.Error("Enroll succeeded with an invalid OTP")
+	}
+}
+
+func TestCompleteWaitsForApproval(t *testing.T) {
+	env := newEnvironment(t, "@alice")
+	approver := newClient(t, env, "@alice")
+	pending := enroll(t, env, approver, key_utils.NewMemoryKeyStore())
+	attempts := recordCommands(t, env.AtServer("@alice"), "pkam:")
+
+	type result struct {
+		client *atclient.AtClient
+		err    error
+	}
+	done := make(chan result)
+	go func() {
+		client, err := completeSoon(pending)
+		done <- result{client, err}
+	}()
+
+	// Complete keeps trying while the enrollment is pending
+	for len(attempts()) < 2 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := approver.ApproveEnrollment(pending.EnrollmentId); err != nil {
+		t.Fatalf("ApproveEnrollment: %v", err)
+	}
+	r := <-done
+	if r.err != nil {
+		t.Fatalf("Complete: %v", r.err)
+	}
+	r.client.Close()
+	if pending.Status != verb_builder.EnrollmentStatusApproved {
+		t.Errorf("Status = %q, want approved", pending.Status)
+	}
+}
+
+func TestCompleteStopsWhenDenied(t *testing.T) {
+	env := newEnvironment(t, "@alice")
+	approver := newClient(t, env, "@alice")
+	pending := enroll(t, env, approver, key_utils.NewMemoryKeyStore())
+	if err := approver.DenyEnrollment(pending.EnrollmentId); err != nil {
+		t.Fatalf("DenyEnrollment: %v", err)
+	}
+	attempts := recordCommands(t, env.AtServer("@alice"), "pkam:")
+
+	_, err := completeSoon(pending)
+	var unauthenticated *exceptions.AtUnauthenticatedException
+	if !errors.As(err, &unauthenticated) {
+		t.Fatalf("Complete error = %v, want AtUnauthenticatedException", err)
+	}
+	if len(attempts()) != 1 {
+		t.Errorf("Complete tried to authenticate %d times after AT0025, want once", len(attempts()))
+	}
+
+	// A denied enrollment cannot be approved
+	if err := approver.ApproveEnrollment(pending.EnrollmentId); err == nil {
+		t.Error("ApproveEnrollment of a denied enrollment succeeded")
+	}
+}
+
+func TestCompleteTimesOut(t *testing.T) {
+	env := newEnvironment(t, "@alice")
+	pending := enroll(t, env, newClient(t, env, "@alice"), key_utils.NewMemoryKeyStore())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := pending.Complete(ctx, 10*time.Millisecond)
+	var timeout *exceptions.AtTimeoutException
+	if !errors.As(err, &timeout) {
+		t.Errorf("Complete error = %v, want AtTimeoutException", err)
+	}
+}
+
+func TestCompleteFailsOnUndecryptableKey(t *testing.T) {
+	env := newEnvironment(t, "@alice")
+	approver := newClient(t, env, "@alice")
+	store := key_utils.NewMemoryKeyStore()
+	pending := enroll(t, env, approver, store)
+	if err := approver.ApproveEnrollment(pending.EnrollmentId); err != nil {
+		t.Fatal(err)
+	}
+	env.AtServer("@alice").InterceptCommands(func(command string) (string, bool) {
+		if strings.HasPrefix(command, "keys:get:") {
+			return `data:{"value":"not base64!"}`, true
+		}
+		return "", false
+	})
+	t.Cleanup(func() { env.AtServer("@alice").InterceptCommands(nil) })
+
+	_, err := completeSoon(pending)
+	if err == nil || !strings.Contains(err.Error(), "decrypt") {
+		t.Errorf("Complete error = %v, want a decryption failure", err)
+	}
+	if store.Exists("@alice") {
+		t.Error("Complete saved keys it could not decrypt")
+	}
+}
+
+func TestRevokeEnrollment(t *testing.T) {
+	env := newEnvironment(t, "@alice")
+	alice := common.NewAtSign("@alice")
+	approver := newClient(t, env, "@alice")
+	store := key_utils.NewMemoryKeyStore()
+	pending := enroll(t, env, approver, store)
+	if err := approver.ApproveEnrollment(pending.EnrollmentId); err != nil {
+		t.Fatal(err)
+	}
+	client, err := completeSoon(pending)
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	client.Close()
+
+	if err := approver.RevokeEnrollment(pending.EnrollmentId); err != nil {
+		t.Fatalf("RevokeEnrollment: %v", err)
+	}
+	keys, err := store.Load(alice.AtSignStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = env.NewAtClient(*alice, atclient.WithKeys(keys))
+	var apkamErr *exceptions.AtApkamAuthenticationException
+	if !errors.As(err, &apkamErr) || apkamErr.EnrollmentStatus != verb_builder.EnrollmentStatusRevoked {
+		t.Errorf("NewAtClient with revoked keys error = %v, want AtApkamAuthenticationException for a revoked enrollment", err)
+	}
+
+	revoked, err := approver.ListEnrollments(verb_builder.EnrollmentStatusRevoked)
+	if err != nil || len(revoked) != 1 || revoked[0].Id != pending.EnrollmentId {
+		t.Errorf("ListEnrollments(revoked) = %+v, %v, want the enrollment", revoked, err)
+	}
+	if err := approver.RevokeEnrollment(pending.EnrollmentId); err == nil {
+		t.Error("revoking a revoked enrollment succeeded")
+	}
+}
+
+func TestCompleteWithConnectionPool(t *testing.T) {
+	env := newEnvironment(t, "@alice")
+	approver := newClient(t, env, "@alice")
+	pending := enroll(t, env, approver, key_utils.NewMemoryKeyStore(), atclient.WithConnectionPool(2, 3, 0))
+	if err := approver.ApproveEnrollment(pending.EnrollmentId); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := completeSoon(pending)
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	t.Cleanup(client.Close)
+	if client.Pool() == nil || client.Pool().Size() != 2 {
+		t.Error("Complete returned a client without a pool of 2 connections")
+	}
+}
@@ -0,0 +1,184 @@
+package atclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/atsign-foundation/at_go/at_client/common"
+	"github.com/atsign-foundation/at_go/at_client/connections"
+	"github.com/atsign-foundation/at_go/at_client/exceptions"
+	"github.com/atsign-foundation/at_go/at_client/utils/verb_builder"
+)
+
+// Notification is a notification received through Monitor.
+type Notification struct {
+	Id          string           `json:"id"`
+	From        string           `json:"from"`
+	To          string           `json:"to"`
+	Key         string           `json:"key"`
+	Value       string           `json:"value"`
+	Operation   string           `json:"operation"`
+	EpochMillis int64            `json:"epochMillis"`
+	MessageType string           `json:"messageType"`
+	IsEncrypted bool             `json:"isEncrypted"`
+	RawMetadata json.RawMessage  `json:"metadata"`
+	Metadata    *common.Metadata `json:"-"`
+
+	// DecryptedValue is the plaintext of Value when IsEncrypted is set and decryption succeeded;
+	// otherwise DecryptionError says why it could not be decrypted.
+	DecryptedValue  string `json:"-"`
+	DecryptionError error  `json:"-"`
+}
+
+// Monitor opens a dedicated, PKAM-authenticated connection to the atServer and sends the
+// monitor verb. Notifications whose key matches regex are delivered on the returned channel.
+// If the connection is lost, Monitor reconnects with backoff, reporting each attempt to the
+// reconnect listener, and asks the atServer for the notifications received since the last one
+// delivered. The channel is closed when ctx is cancelled or reconnecting fails.
+func (c *AtClient) Monitor(ctx context.Context, regex string) (<-chan Notification, error) {
+	conn := &connections.AtSecondaryConnection{
+		AtConnection:  connections.NewAtConnection(c.SecondaryAddress.Host(), c.SecondaryAddress.Port(), ctx, c.Verbose, c.connectionOptions...),
+		Address:       c.SecondaryAddress,
+		Authenticator: c.authenticate,
+		OnReconnect:   c.onReconnect,
+	}
+	if err := conn.AtConnection.Connect(); err != nil {
+		return nil, exceptions.NewAtSecondaryConnectException("Failed to connect to " + c.SecondaryAddress.String() + " - " + err.Error())
+	}
+
+	if err := c.authenticate(conn.AtConnection); err != nil {
+		conn.AtConnection.Disconnect()
+		return nil, err
+	}
+
+	if err := startMonitor(conn.AtConnection, regex, 0); err != nil {
+		conn.AtConnection.Disconnect()
+		return nil, err
+	}
+
+	notifications := make(chan Notification)
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		conn.AtConnection.Disconnect()
+	}()
+
+	go func() {
+		defer close(done)
+		defer close(notifications)
+
+		var lastNotificationTime int64
+		for {
+			frame, err := conn.AtConnection.ReadFrame()
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				if err := c.resumeMonitor(ctx, conn, regex, lastNotificationTime); err != nil {
+					if c.Verbose {
+						fmt.Printf("\tStopped monitoring : %s\n", err)
+					}
+					return
+				}
+				// ctx may have been cancelled, and the connection closed, while reconnecting
+				if ctx.Err() != nil {
+					conn.AtConnection.Disconnect()
+					return
+				}
+				continue
+			}
+
+			notification, ok := c.parseNotification(frame)
+			if !ok {
+				continue
+			}
+			if notification.EpochMillis > lastNotificationTime {
+				lastNotificationTime = notification.EpochMillis
+			}
+			select {
+			case notifications <- *notification:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return notifications, nil
+}
+
+// resumeMonitor reconnects after the monitor connection was lost and asks for the
+// notifications received since lastNotificationTime.
+func (c *AtClient) resumeMonitor(ctx context.Context, conn *connections.AtSecondaryConnection, regex string, lastNotificationTime int64) error {
+	if err := conn.Reconnect(ctx); err != nil {
+		return err
+	}
+	if err := startMonitor(conn.AtConnection, regex, lastNotificationTime); err != nil {
+		conn.AtConnection.Disconnect()
+		return err
+	}
+	return nil
+}
+
+func startMonitor(conn *connections.AtConnection, regex string, lastNotificationTime int64) error {
+	command := verb_builder.NewMonitorVerbBuilder().SetRegex(regex).SetLastNotificationTime(lastNotificationTime).Build()
+	if _, err := conn.ExecuteCommand(command, false); err != nil {
+		return exceptions.NewAtSecondaryConnectException("Failed to execute " + command + " - " + err.Error())
+	}
+	return nil
+}
+
+func (c *AtClient) parseNotification(frame string) (*Notification, bool) {
+	if !strings.HasPrefix(frame, "notification:") {
+		return nil, false
+	}
+	jsonData := strings.TrimSpace(frame[len("notification:"):])
+
+	notification := &Notification{}
+	if err := json.Unmarshal([]byte(jsonData), notification); err != nil {
+		if c.Verbose {
+			fmt.Printf("\tFailed to parse notification : %s : %s\n", jsonData, err)
+		}
+		return nil, false
+	}
+
+	// The atServer sends its own stats notifications with id -1
+	if notification.Id == "-1" {
+		return nil, false
+	}
+
+	notification.Metadata = &common.Metadata{}
+	if len(notification.RawMetadata) > 0 && string(notification.RawMetadata) != "null" {
+		if metadata, err := common.FromJSON(string(notification.RawMetadata)); err == nil {
+			notification.Metadata = metadata
+		}
+	}
+
+	if notification.IsEncrypted && notification.Value != "" {
+		notification.DecryptedValue, notification.DecryptionError = c.decryptNotification(notification)
+	}
+
+	return notification, true
+}
+
+func (c *AtClient) decryptNotification(notification *Notification) (string, error) {
+	sharedKey := common.NewSharedKey("", common.NewAtSign(notification.From), common.NewAtSign(notification.To))
+
+	var what = "fetch shared encryption key"
+	sharedByEncryptionKey, err := c.encryptionKeySharedByOtherFor(*sharedKey, notification.Metadata)
+	if err != nil {
+		return "", exceptions.NewAtDecryptionException("Failed to " + what + " - " + err.Error())
+	}
+
+	what = "decrypt value with shared encryption key"
+	value, err := decryptValue(notification.Value, sharedByEncryptionKey, notification.Metadata)
+	if err != nil {
+		return "", exceptions.NewAtDecryptionException("Failed to " + what + " - " + err.Error())
+	}
+	return value, nil
+}
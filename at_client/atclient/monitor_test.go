@@ -0,0 +1,177 @@
+package atclient_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/atsign-foundation/at_go/at_client/atclient"
+	"github.com/atsign-foundation/at_go/at_client/common"
+	"github.com/atsign-foundation/at_go/at_client/connections"
+	"github.com/atsign-foundation/at_go/at_client/exceptions"
+)
+
+// receive returns the next notification, failing the test if none arrives within a few seconds.
+func receive(t *testing.T, notifications <-chan atclient.Notification) atclient.Notification {
+	t.Helper()
+	select {
+	case notification, ok := <-notifications:
+		if !ok {
+			t.Fatal("notifications closed")
+		}
+		return notification
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a notification")
+	}
+	return atclient.Notification{}
+}
+
+// monitor starts client monitoring regex until the test ends.
+func monitor(t *testing.T, client *atclient.AtClient, regex string) <-chan atclient.Notification {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	notifications, err := client.Monitor(ctx, regex)
+	if err != nil {
+		t.Fatalf("Monitor: %v", err)
+	}
+	return notifications
+}
+
+func TestMonitorSkipsStatsNotifications(t *testing.T) {
+	env := newEnvironment(t, "@alice", "@bob")
+	alice, bob := common.NewAtSign("@alice"), common.NewAtSign("@bob")
+	aliceClient := newClient(t, env, "@alice")
+	notifications := monitor(t, newClient(t, env, "@bob"), "message")
+
+	// Once the first notification arrives the monitor is surely registered
+	if _, err := aliceClient.Notify(common.NewSharedKey("message", alice, bob), "first", nil); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, notifications)
+
+	env.AtServer("@bob").SendStatsNotification()
+	if _, err := aliceClient.Notify(common.NewSharedKey("message", alice, bob), "second", nil); err != nil {
+		t.Fatal(err)
+	}
+	if notification := receive(t, notifications); notification.Id == "-1" || notification.DecryptedValue != "second" {
+		t.Errorf("received %+v, want the second notification", notification)
+	}
+}
+
+func TestMonitorReportsDecryptionError(t *testing.T) {
+	env := newEnvironment(t, "@alice", "@bob")
+	alice, bob := common.NewAtSign("@alice"), common.NewAtSign("@bob")
+	aliceClient := newClient(t, env, "@alice")
+	notifications := monitor(t, newClient(t, env, "@bob"), "message")
+
+	// The first notification creates the key alice shares with bob
+	if _, err := aliceClient.Notify(common.NewSharedKey("message", alice, bob), "first", nil); err != nil {
+		t.Fatal(err)
+	}
+	if notification := receive(t, notifications); notification.DecryptionError != nil || notification.DecryptedValue != "first" {
+		t.Fatalf("received %q, %v, want the decrypted value", notification.DecryptedValue, notification.DecryptionError)
+	}
+
+	command := "notify:isEncrypted:true:@bob:message@alice:not base64!"
+	if _, err := aliceClient.SecondaryConnection.ExecuteCommand(command, true); err != nil {
+		t.Fatal(err)
+	}
+	notification := receive(t, notifications)
+	var decryptionErr *exceptions.AtDecryptionException
+	if !errors.As(notification.DecryptionError, &decryptionErr) {
+		t.Errorf("DecryptionError = %v, want AtDecryptionException", notification.DecryptionError)
+	}
+	if notification.DecryptedValue != "" || notification.Value != "not base64!" {
+		t.Errorf("received %q decrypted from %q, want only the value as sent", notification.DecryptedValue, notification.Value)
+	}
+}
+
+func TestMonitorReconnects(t *testing.T) {
+	env := newEnvironment(t, "@alice", "@bob")
+	alice, bob := common.NewAtSign("@alice"), common.NewAtSign("@bob")
+	aliceClient := newClient(t, env, "@alice")
+	var mu sync.Mutex
+	var events []connections.ReconnectEvent
+	bobClient := newClient(t, env, "@bob", atclient.WithReconnectListener(func(event connections.ReconnectEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}))
+	notifications := monitor(t, bobClient, "message")
+
+	if _, err := aliceClient.Notify(common.NewSharedKey("message", alice, bob), "one", nil); err != nil {
+		t.Fatal(err)
+	}
+	first := receive(t, notifications)
+
+	// alice notifies bob again while the monitor is being resumed
+	server := env.AtServer("@bob")
+	resumed := make(chan string, 1)
+	server.InterceptCommands(func(command string) (string, bool) {
+		if strings.HasPrefix(command, "monitor:") {
+			if _, err := aliceClient.Notify(common.NewSharedKey("message", alice, bob), "two", nil); err != nil {
+				t.Errorf("Notify: %v", err)
+			}
+			resumed <- command
+		}
+		return "", false
+	})
+	t.Cleanup(func() { server.InterceptCommands(nil) })
+	server.DropConnections()
+
+	if notification := receive(t, notifications); notification.DecryptedValue != "two" {
+		t.Errorf("received %q after reconnecting, want the notification sent meanwhile", notification.DecryptedValue)
+	}
+	if command, want := <-resumed, fmt.Sprintf("monitor:%d message", first.EpochMillis); command != want {
+		t.Errorf("resumed with %q, want %q", command, want)
+	}
+	if _, err := aliceClient.Notify(common.NewSharedKey("message", alice, bob), "three", nil); err != nil {
+		t.Fatal(err)
+	}
+	if notification := receive(t, notifications); notification.DecryptedValue != "three" {
+		t.Errorf("received %q, want %q", notification.DecryptedValue, "three")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) == 0 || events[len(events)-1].Err != nil {
+		t.Errorf("reconnect events %+v, want a successful reconnect", events)
+	}
+}
+
+func TestMonitorStopsWhenContextIsDoneWhileReconnecting(t *testing.T) {
+	env := newEnvironment(t, "@alice")
+	failed := make(chan struct{}, 10)
+	client := newClient(t, env, "@alice", atclient.WithReconnectListener(func(event connections.ReconnectEvent) {
+		if event.Err != nil {
+			failed <- struct{}{}
+		}
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	notifications, err := client.Monitor(ctx, "message")
+	if err != nil {
+		t.Fatalf("Monitor: %v", err)
+	}
+
+	env.AtServer("@alice").Close()
+	select {
+	case <-failed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Monitor did not try to reconnect")
+	}
+	cancel()
+	select {
+	case _, ok := <-notifications:
+		if ok {
+			t.Error("received a notification from a closed atServer")
+		}
+	case <-time.After(time.Second):
+		t.Error("notifications were not closed when ctx was cancelled")
+	}
+}
@@ -0,0 +1,138 @@
+package atclient
+
+import (
+	"crypto/rand"
+	"fmt"
+	"reflect"
+
+	"github.com/atsign-foundation/at_go/at_client/common"
+	"github.com/atsign-foundation/at_go/at_client/exceptions"
+	"github.com/atsign-foundation/at_go/at_client/utils/verb_builder"
+)
+
+// NotificationParams holds the optional parts of a notify command. Zero values are left
+// out of the command so the atServer applies its own defaults.
+type NotificationParams struct {
+	Id          string
+	Operation   string
+	MessageType string
+	Priority    string
+	Strategy    string
+	LatestN     int
+	Notifier    string
+	TTLn        int
+}
+
+// Notify sends a notification about key to the atSign it is shared with and returns the
+// notification id. Values of SharedKeys are encrypted with the shared encryption key.
+func (c *AtClient) Notify(key common.AtKey, value string, notificationParams *NotificationParams) (string, error) {
+	params := NotificationParams{}
+	if notificationParams != nil {
+		params = *notificationParams
+	}
+	if params.Operation == "" {
+		params.Operation = verb_builder.NotifyOperationUpdate
+	}
+
+	metadata := *key.GetMetadata()
+	switch k := key.(type) {
+	case *common.SharedKey:
+		if c.AtSign != *k.SharedBy {
+			return "", exceptions.NewAtIllegalArgumentException("sharedBy is " + k.SharedBy.AtSignStr + " but should be this client's atSign " + c.AtSign.AtSignStr)
+		}
+		if params.Operation == verb_builder.NotifyOperationUpdate && value != "" {
+			var what = "fetch/create shared encryption key"
+			sharedToEncryptionKey, err := c.GetEncryptionKeySharedByMe(*k)
+			if err != nil {
+				return "", exceptions.NewAtEncryptionException("Failed to " + what + " - " + err.Error())
+			}
+
+			what = "fetch shared key metadata"
+			if err := c.setSharedKeyMetadata(*k.SharedWith, &metadata); err != nil {
+				return "", exceptions.NewAtEncryptionException("Failed to " + what + " - " + err.Error())
+			}
+
+			what = "encrypt value with shared encryption key"
+			value, err = c.encryptValue(value, sharedToEncryptionKey, &metadata)
+			if err != nil {
+				return "", exceptions.NewAtEncryptionException("Failed to " + what + " - " + err.Error())
+			}
+			metadata.IsEncrypted = true
+		}
+	case *common.PublicKey:
+	default:
+		return "", exceptions.NewAtException("No implementation found for key type: " + reflect.TypeOf(key).String())
+	}
+
+	if params.Operation == verb_builder.NotifyOperationDelete {
+		value = ""
+	}
+	if params.MessageType == "" {
+		params.MessageType = verb_builder.NotifyMessageTypeKey
+	}
+
+	builder := verb_builder.NewNotifyVerbBuilder().WithAtKey(key, value).SetMetadata(&metadata)
+	return c.notify(builder, params)
+}
+
+// NotifyText sends a plain text message to sharedWith and returns the notification id.
+func (c *AtClient) NotifyText(sharedWith common.AtSign, text string, notificationParams *NotificationParams) (string, error) {
+	params := NotificationParams{}
+	if notificationParams != nil {
+		params = *notificationParams
+	}
+	params.Operation = ""
+	params.MessageType = verb_builder.NotifyMessageTypeText
+
+	builder := verb_builder.NewNotifyVerbBuilder().SetSharedWith(sharedWith.AtSignStr).SetKeyName(text)
+	return c.notify(builder, params)
+}
+
+// NotifyStatus returns the delivery status (e.g. delivered, errored, expired) of a notification
+// previously sent by this client.
+func (c *AtClient) NotifyStatus(notificationId string) (string, error) {
+	command := verb_builder.NewNotifyStatusVerbBuilder().SetId(notificationId).Build()
+	response, err := c.executeCommand(command)
+	if err != nil {
+		return "", err
+	}
+	return response.GetRawDataResponse(), nil
+}
+
+func (c *AtClient) notify(builder *verb_builder.NotifyVerbBuilder, params NotificationParams) (string, error) {
+	if params.Id == "" {
+		id, err := newNotificationId()
+		if err != nil {
+			return "", err
+		}
+		params.Id = id
+	}
+
+	command := builder.
+		SetId(params.Id).
+		SetOperation(params.Operation).
+		SetMessageType(params.MessageType).
+		SetPriority(params.Priority).
+		SetStrategy(params.Strategy).
+		SetLatestN(params.LatestN).
+		SetNotifier(params.Notifier).
+		SetTTLn(params.TTLn).
+		Build()
+
+	response, err := c.executeCommand(command)
+	if err != nil {
+		return "", err
+	}
+	return response.GetRawDataResponse(), nil
+}
+
+// newNotificationId returns a random (version 4) UUID.
+func newNotificationId() (string, error) {
+	uuid := make([]byte, 16)
+	if _, err := rand.Read(uuid); err != nil {
+		return "", err
+	}
+	uuid[6] = (uuid[6] & 0x0f) | 0x40
+	uuid[8] = (uuid[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:]), nil
+}
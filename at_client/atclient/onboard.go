@@ -0,0 +1,117 @@
+package atclient
+
+import (
+	"errors"
+
+	"github.com/atsign-foundation/at_go/at_client/common"
+	"github.com/atsign-foundation/at_go/at_client/connections"
+	"github.com/atsign-foundation/at_go/at_client/exceptions"
+	"github.com/atsign-foundation/at_go/at_client/utils/auth_util"
+	"github.com/atsign-foundation/at_go/at_client/utils/key_utils"
+	"github.com/atsign-foundation/at_go/at_client/utils/verb_builder"
+)
+
+const (
+	pkamPublicKeyName = "privatekey:at_pkam_publickey"
+	cramSecretName    = "privatekey:at_secret"
+)
+
+// Onboard activates a newly registered atSign. It authenticates with cramSecret, generates the
+// atSign's PKAM and encryption key pairs and self encryption key, stores the PKAM public key,
+// checks that PKAM authentication now works, publishes public:publickey and deletes the CRAM
+// secret so that only PKAM is accepted from then on. The keys are saved, to
+// ~/.atsign/keys/@alice_key.atKeys or the store given with WithKeyStore, as soon as the
+// atServer accepts them, and a client authenticated with them is returned.
+//
+// Onboard refuses to run when the key store already has keys for the atSign.
+func Onboard(atsign common.AtSign, rootAddress connections.Address, cramSecret string, verbose bool, options ...AtClientOption) (*AtClient, error) {
+	if rootAddress.String() == ":0" {
+		rootAddress = *connections.DefaultRootAddress()
+	}
+
+	client := &AtClient{
+		AtSign:      atsign,
+		RootAddress: rootAddress,
+		Verbose:     verbose,
+	}
+	for _, option := range options {
+		option(client)
+	}
+
+	keyStore := client.getKeyStore()
+	if keyStore.Exists(atsign.AtSignStr) {
+		return nil, exceptions.NewAtIllegalArgumentException("Keys for " + atsign.AtSignStr + " already exist")
+	}
+
+	var what = "generate keys"
+	keys, err := key_utils.NewKeysUtil().GenerateKeys()
+	if err != nil {
+		return nil, exceptions.NewAtException("Failed to " + what + " - " + err.Error())
+	}
+	client.Keys = keys
+
+	if err := client.findSecondary(); err != nil {
+		return nil, err
+	}
+	if err := client.connectSecondary(); err != nil {
+		return nil, err
+	}
+	onboarded := false
+	defer func() {
+		if !onboarded {
+			client.SecondaryConnection.AtConnection.Disconnect()
+		}
+	}()
+
+	if exception := auth_util.AuthenticateWithCram(client.SecondaryConnection.AtConnection, atsign, cramSecret); exception != nil {
+		if !client.SecondaryConnection.AtConnection.IsConnected() {
+			client.invalidateSecondary()
+		}
+		return nil, exception
+	}
+
+	what = "store PKAM public key"
+	command := verb_builder.NewUpdateVerbBuilder().SetKeyName(pkamPublicKeyName).SetValue(keys[key_utils.PkamPublicKeyName]).Build()
+	if _, err := client.executeCommand(command); err != nil {
+		return nil, exceptions.NewAtException("Failed to " + what + " - " + err.Error())
+	}
+
+	what = "authenticate with PKAM"
+	if err := client.authenticate(client.SecondaryConnection.AtConnection); err != nil {
+		return nil, exceptions.NewAtException("Failed to " + what + " - " + err.Error())
+	}
+	client.SecondaryConnection.Authenticator = client.authenticate
+	client.Authenticated = true
+
+	// The atServer now only accepts these keys, so save them before going any further
+	what = "save keys"
+	if err := keyStore.Save(atsign.AtSignStr, keys); err != nil {
+		return nil, exceptions.NewAtException("Failed to " + what + " - " + err.Error())
+	}
+
+	what = "publish encryption public key"
+	publicKey := common.NewPublicKey("publickey", &atsign)
+	command = verb_builder.NewUpdateVerbBuilder().WithAtKey(&publicKey.AtKeyBase, keys[key_utils.EncryptionPublicKeyName]).Build()
+	if _, err := client.executeCommand(command); err != nil {
+		return nil, exceptions.NewAtException("Failed to " + what + " - " + err.Error())
+	}
+
+	what = "delete CRAM secret"
+	command = verb_builder.NewDeleteVerbBuilder().SetKeyName(cramSecretName).Build()
+	if _, err := client.executeCommand(command); err != nil {
+		var notFound *exceptions.AtKeyNotFoundException
+		if !errors.As(err, &notFound) {
+			return nil, exceptions.NewAtException("Failed to " + what + " - " + err.Error())
+		}
+	}
+
+	what = "start connection pool"
+	if client.poolOptions != nil {
+		if err := client.startPool(); err != nil {
+			return nil, exceptions.NewAtException("Failed to " + what + " - " + err.Error())
+		}
+	}
+
+	onboarded = true
+	return client, nil
+}
@@ -0,0 +1,180 @@
+package atclient_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/atsign-foundation/at_go/at_client/atclient"
+	"github.com/atsign-foundation/at_go/at_client/attest"
+	"github.com/atsign-foundation/at_go/at_client/common"
+	"github.com/atsign-foundation/at_go/at_client/exceptions"
+)
+
+// putSelfValues puts n self keys of alice, "key0" to "key<n-1>", with the value "value "
+// and their number, and a TTL in seconds of 1000 plus their number.
+func putSelfValues(t *testing.T, client *atclient.AtClient, n int) []common.AtKey {
+	t.Helper()
+	alice := common.NewAtSign("@alice")
+	keys := []common.AtKey{}
+	for i := 0; i < n; i++ {
+		key := common.NewSelfKey(fmt.Sprintf("key%d", i), alice, nil)
+		key.Metadata.TTL = 1000 + i
+		if _, err := client.Put(key, fmt.Sprintf("value %d", i)); err != nil {
+			t.Fatalf("Put(key%d): %v", i, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// dropConnectionsOn drops the server's connections, without answering, the first time it
+// receives a command starting with prefix. It returns a function counting how many times the
+// server has received commands starting with prefix.
+func dropConnectionsOn(t *testing.T, server *attest.AtServer, prefix string) func() int {
+	t.Helper()
+	var mu sync.Mutex
+	received := 0
+	server.InterceptCommands(func(command string) (string, bool) {
+		if !strings.HasPrefix(command, prefix) {
+			return "", false
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		received++
+		if received == 1 {
+			server.DropConnections()
+			return "", true
+		}
+		return "", false
+	})
+	t.Cleanup(func() { server.InterceptCommands(nil) })
+	return func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return received
+	}
+}
+
+// checkBulkGet checks that results hold the values put by putSelfValues, in the order of keys,
+// and an AtKeyNotFoundException for any other key.
+func checkBulkGet(t *testing.T, results []atclient.BulkGetResult, keys []common.AtKey) {
+	t.Helper()
+	if len(results) != len(keys) {
+		t.Fatalf("%d results for %d keys", len(results), len(keys))
+	}
+	for i, result := range results {
+		if result.Key != keys[i] {
+			t.Errorf("result %d is for %s, want %s", i, result.Key.String(), keys[i].String())
+		}
+		var n int
+		if _, err := fmt.Sscanf(keys[i].String(), "key%d@alice", &n); err != nil {
+			var notFound *exceptions.AtKeyNotFoundException
+			if !errors.As(result.Err, &notFound) {
+				t.Errorf("result for %s has error %v, want AtKeyNotFoundException", keys[i].String(), result.Err)
+			}
+			continue
+		}
+		if want := fmt.Sprintf("value %d", n); result.Err != nil || result.Value != want {
+			t.Errorf("result for %s = %q, %v, want %q", keys[i].String(), result.Value, result.Err, want)
+		}
+	}
+}
+
+func TestBulkGetKeepsOrderWithSmallWindow(t *testing.T) {
+	env := newEnvironment(t, "@alice")
+	client := newClient(t, env, "@alice", atclient.WithPipelineWindow(2))
+	keys := putSelfValues(t, client, 7)
+
+	// A key that does not exist part way through fails on its own
+	missing := common.NewSelfKey("missing", common.NewAtSign("@alice"), nil)
+	keys = append(keys[:3], append([]common.AtKey{missing}, keys[3:]...)...)
+
+	results, err := client.BulkGet(keys)
+	if err != nil {
+		t.Fatalf("BulkGet: %v", err)
+	}
+	checkBulkGet(t, results, keys)
+}
+
+func TestBulkGetResendsLookupsAfterDrop(t *testing.T) {
+	env := newEnvironment(t, "@alice")
+	client := newClient(t, env, "@alice", atclient.WithPipelineWindow(2))
+	keys := putSelfValues(t, client, 6)
+	received := dropConnectionsOn(t, env.AtServer("@alice"), "llookup:all:key3@alice")
+
+	results, err := client.BulkGet(keys)
+	if err != nil {
+		t.Fatalf("BulkGet: %v", err)
+	}
+	checkBulkGet(t, results, keys)
+	if received() != 2 {
+		t.Errorf("the dropped lookup was sent %d times, want twice", received())
+	}
+}
+
+// checkAtKeysMetadata checks that atKeys are the keys put by putSelfValues, with their metadata.
+func checkAtKeysMetadata(t *testing.T, atKeys []common.AtKey, n int) {
+	t.Helper()
+	if len(atKeys) != n {
+		t.Fatalf("GetAtKeys returned %d keys, want %d", len(atKeys), n)
+	}
+	for _, atKey := range atKeys {
+		var i int
+		if _, err := fmt.Sscanf(atKey.String(), "key%d@alice", &i); err != nil {
+			t.Fatalf("unexpected key %s", atKey.String())
+		}
+		if ttl := atKey.GetMetadata().TTL; ttl != 1000+i {
+			t.Errorf("%s has TTL %d, want %d", atKey.String(), ttl, 1000+i)
+		}
+	}
+}
+
+func TestGetAtKeysFetchesMetadataWithSmallWindow(t *testing.T) {
+	env := newEnvironment(t, "@alice")
+	client := newClient(t, env, "@alice", atclient.WithPipelineWindow(2))
+	putSelfValues(t, client, 7)
+
+	atKeys, err := client.GetAtKeys("^key", true)
+	if err != nil {
+		t.Fatalf("GetAtKeys: %v", err)
+	}
+	checkAtKeysMetadata(t, atKeys, 7)
+}
+
+func TestGetAtKeysReturnsErrorResponse(t *testing.T) {
+	env := newEnvironment(t, "@alice")
+	client := newClient(t, env, "@alice", atclient.WithPipelineWindow(2))
+	putSelfValues(t, client, 5)
+	env.AtServer("@alice").InterceptCommands(func(command string) (string, bool) {
+		if command == "llookup:meta:key2@alice" {
+			return "error:AT0015-Key not found : key2@alice does not exist", true
+		}
+		return "", false
+	})
+	t.Cleanup(func() { env.AtServer("@alice").InterceptCommands(nil) })
+
+	_, err := client.GetAtKeys("^key", true)
+	var notFound *exceptions.AtKeyNotFoundException
+	if !errors.As(err, &notFound) {
+		t.Errorf("GetAtKeys error = %v, want AtKeyNotFoundException", err)
+	}
+}
+
+func TestGetAtKeysResendsLookupsAfterDrop(t *testing.T) {
+	env := newEnvironment(t, "@alice")
+	client := newClient(t, env, "@alice", atclient.WithPipelineWindow(2))
+	putSelfValues(t, client, 6)
+	received := dropConnectionsOn(t, env.AtServer("@alice"), "llookup:meta:key3@alice")
+
+	atKeys, err := client.GetAtKeys("^key", true)
+	if err != nil {
+		t.Fatalf("GetAtKeys: %v", err)
+	}
+	checkAtKeysMetadata(t, atKeys, 6)
+	if received() != 2 {
+		t.Errorf("the dropped lookup was sent %d times, want twice", received())
+	}
+}
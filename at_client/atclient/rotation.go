@@ -0,0 +1,97 @@
+package atclient
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/atsign-foundation/at_go/at_client/common"
+	"github.com/atsign-foundation/at_go/at_client/connections"
+	"github.com/atsign-foundation/at_go/at_client/exceptions"
+	"github.com/atsign-foundation/at_go/at_client/utils/encryption_util"
+)
+
+const sharedKeyName = "shared_key"
+
+// RotateSharedKey replaces the AES key this atSign shares values with sharedWith under, e.g.
+// after sharedWith was compromised or changed their encryption key pair. Every value shared
+// with sharedWith is re-encrypted with the new key and sent with it, as sharedKeyEnc and
+// pubKeyCS, so that sharedWith's clients use it instead of their cached key.
+//
+// Rotation is not atomic, as the atServer has no transactions. Values are read before the key
+// is replaced, and values that cannot be read with the old key are left as they are. The new
+// key is then saved and each value rewritten in turn. Values that cannot be rewritten are left
+// encrypted with the old key, which sharedWith can no longer read; RotateSharedKey still tries
+// the rest, then returns an AtSharedKeyRotationException naming them, so that the caller can
+// put them again.
+func (c *AtClient) RotateSharedKey(sharedWith common.AtSign) error {
+	var what = "list keys shared with " + sharedWith.AtSignStr
+	regex := "^" + regexp.QuoteMeta(sharedWith.AtSignStr+":") + ".*" + regexp.QuoteMeta(c.AtSign.AtSignStr) + "$"
+	atKeys, err := c.GetAtKeys(regex, false)
+	if err != nil {
+		return exceptions.NewAtException("Failed to " + what + " - " + err.Error())
+	}
+
+	keys := []*common.SharedKey{}
+	values := []string{}
+	for _, atKey := range atKeys {
+		key, ok := atKey.(*common.SharedKey)
+		if !ok || *key.SharedBy != c.AtSign || key.GetMetadata().IsCached || key.Name == sharedKeyName {
+			continue
+		}
+
+		value, err := c.Get(key)
+		if err != nil {
+			if c.Verbose {
+				fmt.Printf("\tSkipping %s - %s\n", key.String(), err)
+			}
+			continue
+		}
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+
+	what = "generate new shared key"
+	aesKey, err := encryption_util.NewEncryptionUtil().GenerateAESKeyBase64()
+	if err != nil {
+		return exceptions.NewAtEncryptionException("Failed to " + what + " - " + err.Error())
+	}
+
+	what = "save new shared key"
+	c.sharedByMeMu.Lock()
+	delete(c.sharedByMe, sharedWith.AtSignStr)
+	err = c.saveSharedEncryptionKey(sharedWith, aesKey)
+	if err == nil {
+		c.cacheSharedByMe(sharedWith, sharedByMeKey{key: aesKey})
+	}
+	c.sharedByMeMu.Unlock()
+	if err != nil {
+		return exceptions.NewAtEncryptionException("Failed to " + what + " - " + err.Error())
+	}
+
+	// putSharedKey sends the new key with each value as sharedKeyEnc and pubKeyCS
+	notRewritten := []string{}
+	var lastErr error
+	for i, key := range keys {
+		response, err := c.putSharedKey(*key, values[i])
+		if err == nil {
+			response, err = connections.ParseRawResponse(response.GetRawDataResponse())
+		}
+		if err == nil && response.IsError() {
+			err = response.GetException()
+		}
+		if err != nil {
+			if c.Verbose {
+				fmt.Printf("\tFailed to re-encrypt %s - %s\n", key.String(), err)
+			}
+			notRewritten = append(notRewritten, key.String())
+			lastErr = err
+		}
+	}
+
+	if len(notRewritten) > 0 {
+		what = fmt.Sprintf("re-encrypt %d of %d values, left encrypted with the old key: %s", len(notRewritten), len(keys), strings.Join(notRewritten, ", "))
+		return exceptions.NewAtSharedKeyRotationException("Failed to "+what+" - "+lastErr.Error(), notRewritten)
+	}
+	return nil
+}
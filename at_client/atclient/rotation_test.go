@@ -0,0 +1,154 @@
+package atclient_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/atsign-foundation/at_go/at_client/atclient"
+	"github.com/atsign-foundation/at_go/at_client/attest"
+	"github.com/atsign-foundation/at_go/at_client/common"
+	"github.com/atsign-foundation/at_go/at_client/exceptions"
+	"github.com/atsign-foundation/at_go/at_client/utils/encryption_util"
+)
+
+// putSharedValues shares each name with bob, with the value "value of " and the name.
+func putSharedValues(t *testing.T, client *atclient.AtClient, names ...string) {
+	t.Helper()
+	alice, bob := common.NewAtSign("@alice"), common.NewAtSign("@bob")
+	for _, name := range names {
+		if _, err := client.Put(common.NewSharedKey(name, alice, bob), "value of "+name); err != nil {
+			t.Fatalf("Put(%s): %v", name, err)
+		}
+	}
+}
+
+// checkSharedValues checks that client reads each value put by putSharedValues.
+func checkSharedValues(t *testing.T, client *atclient.AtClient, names ...string) {
+	t.Helper()
+	alice, bob := common.NewAtSign("@alice"), common.NewAtSign("@bob")
+	for _, name := range names {
+		got, err := client.Get(common.NewSharedKey(name, alice, bob))
+		if err != nil {
+			t.Errorf("Get(%s): %v", name, err)
+		} else if got != "value of "+name {
+			t.Errorf("Get(%s) = %q, want %q", name, got, "value of "+name)
+		}
+	}
+}
+
+// decryptStoredValue decrypts the value stored under key on server with sharedKey.
+func decryptStoredValue(t *testing.T, server *attest.AtServer, key common.SharedKey, sharedKey string) (string, error) {
+	t.Helper()
+	record, ok := server.Get(key.String())
+	if !ok {
+		t.Fatalf("%s is not stored", key.String())
+	}
+	e := encryption_util.NewEncryptionUtil()
+	if record.Metadata.EncAlgo == encryption_util.EncryptionModeAesGcm {
+		return e.AesGcmDecryptFromBase64(record.Value, sharedKey, record.Metadata.IVNonce)
+	}
+	iv, err := base64.StdEncoding.DecodeString(record.Metadata.IVNonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e.AesDecryptFromBase64(record.Value, sharedKey, iv)
+}
+
+func TestRotateSharedKeyReencryptsValues(t *testing.T) {
+	env := newEnvironment(t, "@alice", "@bob")
+	alice, bob := common.NewAtSign("@alice"), common.NewAtSign("@bob")
+	aliceClient := newClient(t, env, "@alice")
+	names := []string{"one", "two", "three"}
+	putSharedValues(t, aliceClient, names...)
+
+	oldKey, err := aliceClient.GetEncryptionKeySharedByMe(*common.NewSharedKey("one", alice, bob))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := aliceClient.RotateSharedKey(*bob); err != nil {
+		t.Fatalf("RotateSharedKey: %v", err)
+	}
+	newKey, err := aliceClient.GetEncryptionKeySharedByMe(*common.NewSharedKey("one", alice, bob))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newKey == oldKey {
+		t.Fatal("RotateSharedKey kept the old key")
+	}
+
+	for _, name := range names {
+		key := common.NewSharedKey(name, alice, bob)
+		got, err := decryptStoredValue(t, env.AtServer("@alice"), *key, newKey)
+		if err != nil || got != "value of "+name {
+			t.Errorf("%s decrypted with the new key = %q, %v", name, got, err)
+		}
+		record, _ := env.AtServer("@alice").Get(key.String())
+		if record.Metadata.SharedKeyEnc == "" || record.Metadata.PubKeyCS == "" {
+			t.Errorf("%s was rewritten without sharedKeyEnc and pubKeyCS", name)
+		}
+	}
+	checkSharedValues(t, newClient(t, env, "@bob"), names...)
+}
+
+func TestRotateSharedKeyEvictsStaleCachedKey(t *testing.T) {
+	env := newEnvironment(t, "@alice", "@bob")
+	alice, bob := common.NewAtSign("@alice"), common.NewAtSign("@bob")
+	aliceClient := newClient(t, env, "@alice")
+	bobClient := newClient(t, env, "@bob")
+	putSharedValues(t, aliceClient, "one")
+
+	// bob caches the old key, together with the sharedKeyEnc it came from
+	checkSharedValues(t, bobClient, "one")
+	if err := aliceClient.RotateSharedKey(*bob); err != nil {
+		t.Fatalf("RotateSharedKey: %v", err)
+	}
+
+	// A pubKeyCS that is not bob's stops bob using the embedded key, so bob must notice that
+	// the new sharedKeyEnc is not the one its cached key came from and look the key up again
+	key := common.NewSharedKey("one", alice, bob)
+	record, _ := env.AtServer("@alice").Get(key.String())
+	record.Metadata.PubKeyCS = "not bob's checksum"
+	env.AtServer("@alice").Put(key.String(), record.Value, record.Metadata)
+
+	checkSharedValues(t, bobClient, "one")
+}
+
+func TestRotateSharedKeyReportsValuesNotRewritten(t *testing.T) {
+	env := newEnvironment(t, "@alice", "@bob")
+	alice, bob := common.NewAtSign("@alice"), common.NewAtSign("@bob")
+	aliceClient := newClient(t, env, "@alice")
+	putSharedValues(t, aliceClient, "one", "two", "three")
+
+	env.AtServer("@alice").InterceptCommands(func(command string) (string, bool) {
+		if strings.HasPrefix(command, "update:") && strings.Contains(command, "@bob:two@alice ") {
+			return "error:AT0011-Internal server exception : disk full", true
+		}
+		return "", false
+	})
+	err := aliceClient.RotateSharedKey(*bob)
+	env.AtServer("@alice").InterceptCommands(nil)
+
+	var rotationErr *exceptions.AtSharedKeyRotationException
+	if !errors.As(err, &rotationErr) {
+		t.Fatalf("RotateSharedKey error = %v, want AtSharedKeyRotationException", err)
+	}
+	if want := []string{"@bob:two@alice"}; !reflect.DeepEqual(rotationErr.NotRewritten, want) {
+		t.Errorf("NotRewritten = %v, want %v", rotationErr.NotRewritten, want)
+	}
+	if !strings.Contains(err.Error(), "@bob:two@alice") {
+		t.Errorf("error %q does not name the value left with the old key", err)
+	}
+
+	// The values after the failed one were still rewritten
+	checkSharedValues(t, newClient(t, env, "@bob"), "one", "three")
+	newKey, err := aliceClient.GetEncryptionKeySharedByMe(*common.NewSharedKey("two", alice, bob))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := decryptStoredValue(t, env.AtServer("@alice"), *common.NewSharedKey("two", alice, bob), newKey); err == nil && got == "value of two" {
+		t.Error("two decrypts with the new key although it was not rewritten")
+	}
+}
@@ -0,0 +1,105 @@
+package atclient_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/atsign-foundation/at_go/at_client/attest"
+	"github.com/atsign-foundation/at_go/at_client/common"
+	"github.com/atsign-foundation/at_go/at_client/utils/encryption_util"
+	"github.com/atsign-foundation/at_go/at_client/utils/key_utils"
+)
+
+// recordCommands records the commands server receives that start with prefix.
+func recordCommands(t *testing.T, server *attest.AtServer, prefix string) func() []string {
+	t.Helper()
+	var mu sync.Mutex
+	var commands []string
+	server.InterceptCommands(func(command string) (string, bool) {
+		if strings.HasPrefix(command, prefix) {
+			mu.Lock()
+			commands = append(commands, command)
+			mu.Unlock()
+		}
+		return "", false
+	})
+	t.Cleanup(func() { server.InterceptCommands(nil) })
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), commands...)
+	}
+}
+
+func TestGetDecryptsWithEmbeddedSharedKey(t *testing.T) {
+	env := newEnvironment(t, "@alice", "@bob")
+	bob := common.NewAtSign("@bob")
+	aliceClient := newClient(t, env, "@alice")
+	bobClient := newClient(t, env, "@bob")
+	putSharedValues(t, aliceClient, "one")
+
+	record, _ := env.AtServer("@alice").Get("@bob:one@alice")
+	bobPublicKey := env.Keys(*bob)[key_utils.EncryptionPublicKeyName]
+	if record.Metadata.SharedKeyEnc == "" || record.Metadata.PubKeyCS != encryption_util.NewEncryptionUtil().PublicKeyChecksum(bobPublicKey) {
+		t.Fatalf("Put stored metadata %+v, want sharedKeyEnc and bob's pubKeyCS", record.Metadata)
+	}
+
+	lookups := recordCommands(t, env.AtServer("@bob"), "lookup:shared_key")
+	checkSharedValues(t, bobClient, "one")
+	if len(lookups()) != 0 {
+		t.Errorf("bob looked up the shared key (%v) instead of using sharedKeyEnc", lookups())
+	}
+}
+
+func TestGetLooksUpSharedKeyWhenEmbeddedKeyIsUnusable(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(metadata *common.Metadata)
+	}{
+		{"pubKeyCS of another key", func(metadata *common.Metadata) {
+			metadata.PubKeyCS = encryption_util.NewEncryptionUtil().PublicKeyChecksum("another public key")
+		}},
+		{"sharedKeyEnc not encrypted for us", func(metadata *common.Metadata) {
+			metadata.SharedKeyEnc = "bm90IGVuY3J5cHRlZCBmb3IgYm9i"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newEnvironment(t, "@alice", "@bob")
+			aliceClient := newClient(t, env, "@alice")
+			bobClient := newClient(t, env, "@bob")
+			putSharedValues(t, aliceClient, "one")
+
+			record, _ := env.AtServer("@alice").Get("@bob:one@alice")
+			tt.edit(&record.Metadata)
+			env.AtServer("@alice").Put("@bob:one@alice", record.Value, record.Metadata)
+
+			lookups := recordCommands(t, env.AtServer("@bob"), "lookup:shared_key")
+			checkSharedValues(t, bobClient, "one")
+			if len(lookups()) != 1 {
+				t.Errorf("bob looked up the shared key %d times, want once", len(lookups()))
+			}
+		})
+	}
+}
+
+func TestPutCachesSharedKeyMetadata(t *testing.T) {
+	env := newEnvironment(t, "@alice", "@bob")
+	aliceClient := newClient(t, env, "@alice")
+
+	lookups := recordCommands(t, env.AtServer("@alice"), "llookup:all:@bob:shared_key@alice")
+	putSharedValues(t, aliceClient, "one", "two", "three")
+	if len(lookups()) != 1 {
+		t.Errorf("Put looked up sharedKeyEnc %d times for three values, want once", len(lookups()))
+	}
+
+	sharedKey, _ := env.AtServer("@alice").Get("@bob:shared_key@alice")
+	for _, name := range []string{"one", "two", "three"} {
+		record, _ := env.AtServer("@alice").Get("@bob:" + name + "@alice")
+		if record.Metadata.SharedKeyEnc != sharedKey.Value {
+			t.Errorf("%s was sent with sharedKeyEnc %q, want the stored shared key", name, record.Metadata.SharedKeyEnc)
+		}
+	}
+	checkSharedValues(t, newClient(t, env, "@bob"), "one", "two", "three")
+}
@@ -0,0 +1,764 @@
+package attest
+
+import (
+	"bufio"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/atsign-foundation/at_go/at_client/common"
+	"github.com/atsign-foundation/at_go/at_client/connections"
+	"github.com/atsign-foundation/at_go/at_client/utils/encryption_util"
+)
+
+const (
+	pkamPublicKeyKey = "privatekey:at_pkam_publickey"
+	cramSecretKey    = "privatekey:at_secret"
+)
+
+// Record is a value held by an AtServer together with its metadata.
+type Record struct {
+	Value    string
+	Metadata common.Metadata
+}
+
+// AtServer is a fake atServer for a single atSign that keeps its keys in memory. Lookups of
+// other atSigns' keys and notifications to other atSigns are served by the AtServers in the
+// same Environment.
+type AtServer struct {
+	AtSign common.AtSign
+
+	listener net.Listener
+	server   *listenerServer
+	peers    func(atSign string) *AtServer
+
+	mu                 sync.Mutex
+	cramSecret         string
+	pkamPublicKey      string
+	records            map[string]*Record
+	commitId           int
+	monitors           map[*serverConnection]*regexp.Regexp
+	received           []*notification
+	notificationStatus map[string]string
+	enrollments        map[string]*enrollment
+	otps               map[string]time.Time
+	intercept          func(command string) (string, bool)
+}
+
+type serverConnection struct {
+	server *AtServer
+	conn   net.Conn
+
+	writeMu       sync.Mutex
+	fromAtSign    string
+	challenge     string
+	authenticated bool
+	enrollmentId  string
+}
+
+type notification struct {
+	Id          string            `json:"id"`
+	From        string            `json:"from"`
+	To          string            `json:"to"`
+	Key         string            `json:"key"`
+	Value       string            `json:"value,omitempty"`
+	Operation   string            `json:"operation"`
+	EpochMillis int64             `json:"epochMillis"`
+	MessageType string            `json:"messageType"`
+	IsEncrypted bool              `json:"isEncrypted"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// NewAtServer starts an atServer for atSign on a random local port. Clients authenticate
+// with PKAM against pkamPublicKey, or with CRAM using cramSecret. peers, which may be nil,
+// finds the AtServer of another atSign.
+func NewAtServer(atSign common.AtSign, pkamPublicKey string, cramSecret string, config *tls.Config, peers func(atSign string) *AtServer) (*AtServer, error) {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		return nil, err
+	}
+	if peers == nil {
+		peers = func(string) *AtServer { return nil }
+	}
+	s := &AtServer{
+		AtSign:             *common.NewAtSign(strings.ToLower(atSign.AtSignStr)),
+		listener:           listener,
+		peers:              peers,
+		cramSecret:         cramSecret,
+		pkamPublicKey:      pkamPublicKey,
+		records:            map[string]*Record{},
+		monitors:           map[*serverConnection]*regexp.Regexp{},
+		notificationStatus: map[string]string{},
+		enrollments:        map[string]*enrollment{},
+		otps:               map[string]time.Time{},
+	}
+	s.server = serve(listener, s.handle)
+	return s, nil
+}
+
+func (s *AtServer) Address() connections.Address {
+	return *connections.NewAddress("127.0.0.1", s.listener.Addr().(*net.TCPAddr).Port)
+}
+
+func (s *AtServer) Close() error {
+	return s.server.close()
+}
+
+// DropConnections closes every client connection, as a restarting or failing atServer would.
+func (s *AtServer) DropConnections() {
+	s.server.dropConnections()
+}
+
+// InterceptCommands passes every command the server receives to intercept before executing it.
+// When intercept returns true, the server answers with its response instead, or sends nothing
+// if the response is empty. A nil intercept stops intercepting commands.
+func (s *AtServer) InterceptCommands(intercept func(command string) (string, bool)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.intercept = intercept
+}
+
+// SendStatsNotification sends every monitoring connection the stats notification an atServer
+// sends periodically, with id -1 and the latest commit id as its value.
+func (s *AtServer) SendStatsNotification() {
+	s.mu.Lock()
+	n := &notification{
+		Id:          "-1",
+		From:        s.AtSign.AtSignStr,
+		To:          s.AtSign.AtSignStr,
+		Key:         "statsNotification." + s.AtSign.AtSignStr,
+		Value:       strconv.Itoa(s.commitId),
+		Operation:   "update",
+		EpochMillis: time.Now().UnixMilli(),
+		MessageType: "MessageType.key",
+	}
+	monitors := make([]*serverConnection, 0, len(s.monitors))
+	for sc := range s.monitors {
+		monitors = append(monitors, sc)
+	}
+	s.mu.Unlock()
+
+	// Stats notifications are sent whatever the monitor's regex
+	for _, sc := range monitors {
+		sc.sendNotification(matchAll, n)
+	}
+}
+
+// Put stores value under key (e.g. "public:location@alice") as if a client had updated it.
+func (s *AtServer) Put(key string, value string, metadata common.Metadata) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(strings.ToLower(key), value, metadata)
+}
+
+// Get returns the record stored under key.
+func (s *AtServer) Get(key string) (Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.records[strings.ToLower(key)]
+	if !ok {
+		return Record{}, false
+	}
+	return *record, true
+}
+
+// Keys returns every key held by the server, sorted.
+func (s *AtServer) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.records))
+	for key := range s.records {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *AtServer) put(key string, value string, metadata common.Metadata) int {
+	now := time.Now().UTC()
+	if existing, ok := s.records[key]; ok {
+		metadata.CreatedAt = existing.Metadata.CreatedAt
+		metadata.Version = existing.Metadata.Version + 1
+	} else {
+		metadata.CreatedAt = &now
+	}
+	metadata.UpdatedAt = &now
+	metadata.CreatedBy = s.AtSign.AtSignStr
+	metadata.IsPublic = strings.HasPrefix(key, "public:")
+	s.records[key] = &Record{Value: value, Metadata: metadata}
+	s.commitId++
+	return s.commitId
+}
+
+func (s *AtServer) handle(conn net.Conn) {
+	sc := &serverConnection{server: s, conn: conn}
+	defer func() {
+		s.mu.Lock()
+		delete(s.monitors, sc)
+		s.mu.Unlock()
+	}()
+
+	if err := sc.write("@"); err != nil {
+		return
+	}
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.TrimSpace(line)
+		if command == "" {
+			continue
+		}
+		if command == "@exit" {
+			return
+		}
+
+		response := sc.execute(command)
+		if response == "" {
+			continue
+		}
+		prompt := "@"
+		if sc.authenticated {
+			prompt = s.AtSign.AtSignStr + "@"
+		}
+		if err := sc.write(response + "\n" + prompt); err != nil {
+			return
+		}
+	}
+}
+
+func (sc *serverConnection) write(data string) error {
+	sc.writeMu.Lock()
+	defer sc.writeMu.Unlock()
+	_, err := sc.conn.Write([]byte(data))
+	return err
+}
+
+func (sc *serverConnection) execute(command string) string {
+	sc.server.mu.Lock()
+	intercept := sc.server.intercept
+	sc.server.mu.Unlock()
+	if intercept != nil {
+		if response, ok := intercept(command); ok {
+			return response
+		}
+	}
+
+	verb := command
+	if end := strings.IndexAny(verb, ": "); end > -1 {
+		verb = verb[:end]
+	}
+
+	switch verb {
+	case "from":
+		return sc.from(strings.TrimPrefix(command, "from:"))
+	case "pkam":
+		return sc.pkam(strings.TrimPrefix(command, "pkam:"))
+	case "cram":
+		return sc.cram(strings.TrimPrefix(command, "cram:"))
+	case "noop":
+		return "data:ok"
+	case "info":
+		return `data:{"version":"attest","features":[]}`
+	case "plookup":
+		return sc.plookup(strings.TrimPrefix(command, "plookup:"))
+	case "lookup":
+		return sc.lookup(strings.TrimPrefix(command, "lookup:"))
+	case "enroll":
+		return sc.enroll(strings.TrimPrefix(command, "enroll:"))
+	}
+
+	if !sc.authenticated {
+		return "error:AT0401-Client authentication failed : " + verb + " requires an authenticated connection"
+	}
+
+	switch verb {
+	case "scan":
+		return sc.scan(strings.TrimPrefix(command, "scan"))
+	case "update":
+		return sc.update(strings.TrimPrefix(command, "update:"))
+	case "llookup":
+		return sc.llookup(strings.TrimPrefix(command, "llookup:"))
+	case "delete":
+		return sc.delete(strings.TrimPrefix(command, "delete:"))
+	case "notify":
+		return sc.notify(strings.TrimPrefix(command, "notify:"))
+	case "monitor":
+		return sc.monitor(strings.TrimPrefix(command, "monitor"))
+	case "otp":
+		return sc.otp()
+	case "keys":
+		return sc.keys(strings.TrimPrefix(command, "keys:"))
+	}
+	return "error:AT0003-Invalid syntax : unknown verb " + verb
+}
+
+func (sc *serverConnection) from(atSign string) string {
+	sc.fromAtSign = common.NewAtSign(strings.ToLower(atSign)).AtSignStr
+	sc.authenticated = false
+	sc.challenge = fmt.Sprintf("_%s%s:%s", randomHex(16), sc.fromAtSign, randomHex(16))
+	return "data:" + sc.challenge
+}
+
+func (sc *serverConnection) pkam(arguments string) string {
+	// pkam:<signature> or pkam:signingAlgo:rsa2048:hashingAlgo:sha256:enrollmentId:<id>:<signature>
+	tokens := strings.Split(arguments, ":")
+	signature := tokens[len(tokens)-1]
+	options := map[string]string{}
+	for i := 0; i+1 < len(tokens)-1; i += 2 {
+		options[tokens[i]] = tokens[i+1]
+	}
+	enrollmentId := options["enrollmentId"]
+
+	s := sc.server
+	s.mu.Lock()
+	pkamPublicKey := s.pkamPublicKey
+	if enrollmentId != "" {
+		pkamPublicKey = ""
+		if e, ok := s.enrollments[enrollmentId]; ok {
+			if e.Status != "approved" {
+				s.mu.Unlock()
+				return "error:AT0025-Apkam authentication failed : enrollment " + enrollmentId + " is " + e.Status
+			}
+			pkamPublicKey = e.ApkamPublicKey
+		}
+	}
+	s.mu.Unlock()
+
+	if sc.challenge == "" || sc.fromAtSign != s.AtSign.AtSignStr || pkamPublicKey == "" {
+		return "error:AT0401-Client authentication failed : pkam is not possible on this connection"
+	}
+	publicKey, err := encryption_util.NewEncryptionUtil().PublicKeyFromBase64(pkamPublicKey)
+	if err != nil {
+		return "error:AT0011-Internal server exception : " + err.Error()
+	}
+	signatureBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return "error:AT0401-Client authentication failed : signature is not base64"
+	}
+	hashed := sha256.Sum256([]byte(sc.challenge))
+	if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hashed[:], signatureBytes); err != nil {
+		return "error:AT0401-Client authentication failed : pkam signature does not match"
+	}
+
+	sc.authenticated = true
+	sc.enrollmentId = enrollmentId
+	return "data:success"
+}
+
+func (sc *serverConnection) cram(digest string) string {
+	s := sc.server
+	s.mu.Lock()
+	cramSecret := s.cramSecret
+	s.mu.Unlock()
+
+	if sc.challenge == "" || sc.fromAtSign != s.AtSign.AtSignStr || cramSecret == "" {
+		return "error:AT0401-Client authentication failed : cram is not possible on this connection"
+	}
+	expected := sha512.Sum512([]byte(cramSecret + sc.challenge))
+	if hex.EncodeToString(expected[:]) != digest {
+		return "error:AT0401-Client authentication failed : cram digest does not match"
+	}
+
+	sc.authenticated = true
+	return "data:success"
+}
+
+func (sc *serverConnection) scan(arguments string) string {
+	showHidden := false
+	if strings.HasPrefix(arguments, ":showHidden:true") {
+		showHidden = true
+		arguments = strings.TrimPrefix(arguments, ":showHidden:true")
+	}
+	regex, err := regexp.Compile(strings.TrimSpace(arguments))
+	if err != nil {
+		return "error:AT0003-Invalid syntax : " + err.Error()
+	}
+
+	keys := []string{}
+	for _, key := range sc.server.Keys() {
+		if !showHidden && isHidden(key) {
+			continue
+		}
+		if regex.MatchString(key) {
+			keys = append(keys, key)
+		}
+	}
+	keysJSON, _ := json.Marshal(keys)
+	return "data:" + string(keysJSON)
+}
+
+func isHidden(key string) bool {
+	name := key
+	if colon := strings.LastIndex(name, ":"); colon > -1 {
+		name = name[colon+1:]
+	}
+	return strings.HasPrefix(key, "privatekey:") || strings.HasPrefix(name, "_")
+}
+
+var updateMetadataNames = map[string]bool{
+	"ttl": true, "ttb": true, "ttr": true, "ccd": true, "isBinary": true, "isEncrypted": true,
+	"dataSignature": true, "sharedKeyEnc": true, "pubKeyCS": true, "encoding": true,
+	"encAlgo": true, "ivNonce": true, "sharedKeyStatus": true,
+}
+
+func (sc *serverConnection) update(arguments string) string {
+	keyPart, value, found := strings.Cut(arguments, " ")
+	if !found {
+		return "error:AT0003-Invalid syntax : update requires a value"
+	}
+
+	metadata, key, err := parseMetadata(keyPart)
+	if err != nil {
+		return "error:AT0003-Invalid syntax : " + err.Error()
+	}
+	if strings.HasPrefix(key, "cached:") {
+		return "error:AT0022-Illegal arguments : cached keys cannot be updated"
+	}
+
+	s := sc.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key == pkamPublicKeyKey {
+		s.pkamPublicKey = value
+	}
+	return "data:" + strconv.Itoa(s.put(strings.ToLower(key), value, metadata))
+}
+
+// parseMetadata splits "ttl:100:isEncrypted:true:@bob:phone@alice" into metadata and key.
+func parseMetadata(arguments string) (common.Metadata, string, error) {
+	metadata := common.Metadata{}
+	tokens := strings.Split(arguments, ":")
+	i := 0
+	for ; i+1 < len(tokens) && updateMetadataNames[tokens[i]]; i += 2 {
+		name, value := tokens[i], tokens[i+1]
+		var err error
+		switch name {
+		case "ttl":
+			metadata.TTL, err = strconv.Atoi(value)
+		case "ttb":
+			metadata.TTB, err = strconv.Atoi(value)
+		case "ttr":
+			metadata.TTR, err = strconv.Atoi(value)
+		case "ccd":
+			metadata.CCD = value == "true"
+		case "isBinary":
+			metadata.IsBinary = value == "true"
+		case "isEncrypted":
+			metadata.IsEncrypted = value == "true"
+		case "dataSignature":
+			metadata.DataSignature = value
+		case "sharedKeyEnc":
+			metadata.SharedKeyEnc = value
+		case "pubKeyCS":
+			metadata.PubKeyCS = value
+		case "encoding":
+			metadata.Encoding = value
+		case "encAlgo":
+			metadata.EncAlgo = value
+		case "ivNonce":
+			metadata.IVNonce = value
+		case "sharedKeyStatus":
+			metadata.SharedKeyStatus = value
+		}
+		if err != nil {
+			return metadata, "", fmt.Errorf("%s must be a number", name)
+		}
+	}
+	key := strings.Join(tokens[i:], ":")
+	if key == "" {
+		return metadata, "", fmt.Errorf("missing key")
+	}
+	return metadata, key, nil
+}
+
+// splitLookupType separates the optional "all:" or "meta:" prefix of a lookup.
+func splitLookupType(arguments string) (string, string) {
+	for _, lookupType := range []string{"all", "meta"} {
+		if strings.HasPrefix(arguments, lookupType+":") {
+			return lookupType, strings.TrimPrefix(arguments, lookupType+":")
+		}
+	}
+	return "", arguments
+}
+
+func lookupResponse(lookupType string, key string, record Record) string {
+	switch lookupType {
+	case "all":
+		response, _ := json.Marshal(map[string]interface{}{
+			"key":      key,
+			"data":     record.Value,
+			"metaData": record.Metadata,
+		})
+		return "data:" + string(response)
+	case "meta":
+		response, _ := json.Marshal(record.Metadata)
+		return "data:" + string(response)
+	}
+	return "data:" + record.Value
+}
+
+func keyNotFound(key string) string {
+	return "error:AT0015-key not found : " + key + " does not exist"
+}
+
+func (sc *serverConnection) llookup(arguments string) string {
+	lookupType, key := splitLookupType(arguments)
+	key = strings.ToLower(key)
+	record, ok := sc.server.Get(key)
+	if !ok {
+		return keyNotFound(key)
+	}
+	return lookupResponse(lookupType, key, record)
+}
+
+func (sc *serverConnection) lookup(arguments string) string {
+	lookupType, key := splitLookupType(arguments)
+	key = strings.ToLower(key)
+	at := strings.LastIndex(key, "@")
+	if at < 0 {
+		return "error:AT0003-Invalid syntax : lookup requires key@atSign"
+	}
+	owner := key[at:]
+
+	if owner == sc.server.AtSign.AtSignStr {
+		record, ok := Record{}, false
+		if sc.authenticated {
+			record, ok = sc.server.Get(key)
+		}
+		if !ok {
+			key = "public:" + key
+			record, ok = sc.server.Get(key)
+		}
+		if !ok {
+			return keyNotFound(key)
+		}
+		return lookupResponse(lookupType, key, record)
+	}
+	if !sc.authenticated {
+		return "error:AT0401-Client authentication failed : lookup of another atSign's key requires an authenticated connection"
+	}
+
+	peer := sc.server.peers(owner)
+	if peer == nil {
+		return "error:AT0007-No secondary found for " + owner
+	}
+	sharedKey := sc.server.AtSign.AtSignStr + ":" + key
+	record, ok := peer.Get(sharedKey)
+	if !ok {
+		return keyNotFound(key)
+	}
+	return lookupResponse(lookupType, sharedKey, record)
+}
+
+func (sc *serverConnection) plookup(arguments string) string {
+	arguments = strings.TrimPrefix(arguments, "bypassCache:true:")
+	arguments = strings.TrimPrefix(arguments, "bypassCache:false:")
+	lookupType, key := splitLookupType(arguments)
+	key = strings.ToLower(key)
+	at := strings.LastIndex(key, "@")
+	if at < 0 {
+		return "error:AT0003-Invalid syntax : plookup requires key@atSign"
+	}
+	owner := key[at:]
+
+	server := sc.server
+	if owner != server.AtSign.AtSignStr {
+		server = server.peers(owner)
+		if server == nil {
+			return "error:AT0007-No secondary found for " + owner
+		}
+	}
+	publicKey := "public:" + key
+	record, ok := server.Get(publicKey)
+	if !ok {
+		return keyNotFound(publicKey)
+	}
+	return lookupResponse(lookupType, publicKey, record)
+}
+
+func (sc *serverConnection) delete(key string) string {
+	key = strings.ToLower(key)
+	s := sc.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.records[key]; !ok {
+		return keyNotFound(key)
+	}
+	delete(s.records, key)
+	if key == cramSecretKey {
+		s.cramSecret = ""
+	}
+	s.commitId++
+	return "data:" + strconv.Itoa(s.commitId)
+}
+
+var notifyOptionNames = map[string]bool{
+	"id": true, "messageType": true, "priority": true, "strategy": true, "latestN": true,
+	"notifier": true, "ttln": true, "ttl": true, "ttb": true, "ttr": true, "ccd": true,
+	"isEncrypted": true, "sharedKeyEnc": true, "pubKeyCS": true, "ivNonce": true, "encoding": true,
+	"encAlgo": true,
+}
+
+func (sc *serverConnection) notify(arguments string) string {
+	if strings.HasPrefix(arguments, "status:") {
+		id := strings.TrimPrefix(arguments, "status:")
+		sc.server.mu.Lock()
+		status, ok := sc.server.notificationStatus[id]
+		sc.server.mu.Unlock()
+		if !ok {
+			return "data:expired"
+		}
+		return "data:" + status
+	}
+
+	options := map[string]string{}
+	operation := "update"
+	for {
+		name, rest, found := strings.Cut(arguments, ":")
+		if !found {
+			break
+		}
+		if name == "update" || name == "delete" {
+			operation = name
+			arguments = rest
+			continue
+		}
+		if !notifyOptionNames[name] {
+			break
+		}
+		value, rest, found := strings.Cut(rest, ":")
+		if !found {
+			return "error:AT0003-Invalid syntax : notify is missing a key"
+		}
+		options[name] = value
+		arguments = rest
+	}
+
+	recipient, rest, found := strings.Cut(arguments, ":")
+	if !found || !strings.HasPrefix(recipient, "@") {
+		return "error:AT0003-Invalid syntax : notify requires @recipient:key"
+	}
+	recipient = strings.ToLower(recipient)
+
+	n := &notification{
+		Id:          options["id"],
+		From:        sc.server.AtSign.AtSignStr,
+		To:          recipient,
+		Operation:   operation,
+		EpochMillis: time.Now().UnixMilli(),
+		MessageType: "MessageType.key",
+		IsEncrypted: options["isEncrypted"] == "true",
+		Metadata:    map[string]string{},
+	}
+	if n.Id == "" {
+		n.Id = randomHex(16)
+	}
+	for _, name := range []string{"ivNonce", "sharedKeyEnc", "pubKeyCS", "encoding", "encAlgo", "ttl", "ttr"} {
+		if value, ok := options[name]; ok {
+			n.Metadata[name] = value
+		}
+	}
+
+	if options["messageType"] == "text" {
+		n.MessageType = "MessageType.text"
+		n.Key = recipient + ":" + rest
+	} else {
+		at := strings.Index(rest, "@")
+		if at < 0 {
+			return "error:AT0003-Invalid syntax : notify requires key@atSign"
+		}
+		owner, value, _ := strings.Cut(rest[at+1:], ":")
+		n.Key = strings.ToLower(recipient + ":" + rest[:at] + "@" + owner)
+		n.Value = value
+	}
+
+	status := "errored"
+	if peer := sc.server.peers(recipient); peer != nil {
+		peer.receive(n)
+		status = "delivered"
+	}
+	sc.server.mu.Lock()
+	sc.server.notificationStatus[n.Id] = status
+	sc.server.mu.Unlock()
+
+	return "data:" + n.Id
+}
+
+func (sc *serverConnection) monitor(arguments string) string {
+	var lastNotificationTime int64
+	if strings.HasPrefix(arguments, ":") {
+		timePart, rest, _ := strings.Cut(arguments[1:], " ")
+		lastNotificationTime, _ = strconv.ParseInt(timePart, 10, 64)
+		arguments = rest
+	}
+	regex, err := regexp.Compile(strings.TrimSpace(arguments))
+	if err != nil {
+		return "error:AT0003-Invalid syntax : " + err.Error()
+	}
+
+	s := sc.server
+	s.mu.Lock()
+	s.monitors[sc] = regex
+	var replay []*notification
+	if lastNotificationTime > 0 {
+		for _, n := range s.received {
+			if n.EpochMillis > lastNotificationTime {
+				replay = append(replay, n)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	for _, n := range replay {
+		sc.sendNotification(regex, n)
+	}
+	return ""
+}
+
+// receive delivers a notification sent by another atSign to every monitoring connection.
+func (s *AtServer) receive(n *notification) {
+	s.mu.Lock()
+	s.received = append(s.received, n)
+	monitors := make(map[*serverConnection]*regexp.Regexp, len(s.monitors))
+	for sc, regex := range s.monitors {
+		monitors[sc] = regex
+	}
+	s.mu.Unlock()
+
+	for sc, regex := range monitors {
+		sc.sendNotification(regex, n)
+	}
+}
+
+func (sc *serverConnection) sendNotification(regex *regexp.Regexp, n *notification) {
+	if !regex.MatchString(n.Key) {
+		return
+	}
+	notificationJSON, _ := json.Marshal(n)
+	sc.write("notification: " + string(notificationJSON) + "\n")
+}
+
+var matchAll = regexp.MustCompile("")
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,168 @@
+// Package attest runs a fake root server and fake atServers in-process so that AtClient code
+// can be exercised without network access or real atSigns.
+//
+//	env, err := attest.NewEnvironment()
+//	...
+//	defer env.Close()
+//	env.AddAtSign(*common.NewAtSign("@alice"))
+//	client, err := env.NewAtClient(*common.NewAtSign("@alice"))
+//
+// The servers implement the subset of the at protocol used by this module: from, pkam (with
+// and without an APKAM enrollment id), cram, scan, update, llookup, lookup, plookup, delete,
+// notify, notify:status, monitor, enroll, otp:get, keys:get, noop and info.
+package attest
+
+import (
+	"crypto/tls"
+	"strings"
+	"sync"
+
+	"github.com/atsign-foundation/at_go/at_client/atclient"
+	"github.com/atsign-foundation/at_go/at_client/common"
+	"github.com/atsign-foundation/at_go/at_client/connections"
+	"github.com/atsign-foundation/at_go/at_client/exceptions"
+	"github.com/atsign-foundation/at_go/at_client/utils/key_utils"
+)
+
+// Environment is a root server and the atServers registered with it, all sharing one
+// self-signed certificate.
+type Environment struct {
+	Certificate *Certificate
+	Root        *RootServer
+
+	mu      sync.Mutex
+	servers map[string]*AtServer
+	keys    map[string]map[string]string
+}
+
+func NewEnvironment() (*Environment, error) {
+	certificate, err := NewCertificate()
+	if err != nil {
+		return nil, err
+	}
+	root, err := NewRootServer(certificate.ServerTLSConfig())
+	if err != nil {
+		return nil, err
+	}
+	return &Environment{
+		Certificate: certificate,
+		Root:        root,
+		servers:     map[string]*AtServer{},
+		keys:        map[string]map[string]string{},
+	}, nil
+}
+
+// AddAtSign generates keys for atSign, starts its atServer with the PKAM and encryption
+// public keys already stored, and registers it with the root server.
+func (e *Environment) AddAtSign(atSign common.AtSign) (*AtServer, error) {
+	keys, err := key_utils.NewKeysUtil().GenerateKeys()
+	if err != nil {
+		return nil, err
+	}
+	server, err := e.addAtSign(atSign, keys[key_utils.PkamPublicKeyName], "")
+	if err != nil {
+		return nil, err
+	}
+	server.Put("public:publickey"+server.AtSign.AtSignStr, keys[key_utils.EncryptionPublicKeyName], common.Metadata{})
+	server.Put(pkamPublicKeyKey, keys[key_utils.PkamPublicKeyName], common.Metadata{})
+
+	e.mu.Lock()
+	e.keys[server.AtSign.AtSignStr] = keys
+	e.mu.Unlock()
+	return server, nil
+}
+
+// AddUnonboardedAtSign starts an atServer for atSign that only accepts CRAM authentication
+// with cramSecret, as the atServer of a newly registered atSign would. CRAM is disabled once
+// privatekey:at_secret is deleted.
+func (e *Environment) AddUnonboardedAtSign(atSign common.AtSign, cramSecret string) (*AtServer, error) {
+	server, err := e.addAtSign(atSign, "", cramSecret)
+	if err != nil {
+		return nil, err
+	}
+	server.Put(cramSecretKey, cramSecret, common.Metadata{})
+	return server, nil
+}
+
+func (e *Environment) addAtSign(atSign common.AtSign, pkamPublicKey string, cramSecret string) (*AtServer, error) {
+	atSignStr := strings.ToLower(atSign.AtSignStr)
+
+	e.mu.Lock()
+	_, exists := e.servers[atSignStr]
+	e.mu.Unlock()
+	if exists {
+		return nil, exceptions.NewAtIllegalArgumentException(atSign.AtSignStr + " has already been added")
+	}
+
+	server, err := NewAtServer(atSign, pkamPublicKey, cramSecret, e.Certificate.ServerTLSConfig(), e.AtServer)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	e.servers[atSignStr] = server
+	e.mu.Unlock()
+	e.Root.Register(server.AtSign, server.Address())
+	return server, nil
+}
+
+// AtServer returns the atServer of atSign (with or without the leading "@"), or nil.
+func (e *Environment) AtServer(atSign string) *AtServer {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.servers[common.NewAtSign(strings.ToLower(atSign)).AtSignStr]
+}
+
+// Keys returns the keys generated for atSign by AddAtSign, or nil.
+func (e *Environment) Keys(atSign common.AtSign) map[string]string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	keys, ok := e.keys[strings.ToLower(atSign.AtSignStr)]
+	if !ok {
+		return nil
+	}
+	copied := make(map[string]string, len(keys))
+	for name, value := range keys {
+		copied[name] = value
+	}
+	return copied
+}
+
+func (e *Environment) RootAddress() connections.Address {
+	return e.Root.Address()
+}
+
+// ClientTLSConfig returns a TLS configuration that trusts the environment's servers.
+func (e *Environment) ClientTLSConfig() *tls.Config {
+	return e.Certificate.ClientTLSConfig()
+}
+
+// ClientOptions returns the options NewAtClient needs to use atSign's keys and trust the
+// environment's certificate.
+func (e *Environment) ClientOptions(atSign common.AtSign) []atclient.AtClientOption {
+	options := []atclient.AtClientOption{
+		atclient.WithConnectionOptions(connections.WithTLSConfig(e.ClientTLSConfig())),
+	}
+	if keys := e.Keys(atSign); keys != nil {
+		options = append(options, atclient.WithKeys(keys))
+	}
+	return options
+}
+
+// NewAtClient returns an AtClient for atSign connected to the environment.
+func (e *Environment) NewAtClient(atSign common.AtSign, options ...atclient.AtClientOption) (*atclient.AtClient, error) {
+	return atclient.NewAtClient(atSign, e.RootAddress(), false, append(e.ClientOptions(atSign), options...)...)
+}
+
+// Close stops every atServer and the root server.
+func (e *Environment) Close() error {
+	e.mu.Lock()
+	servers := e.servers
+	e.servers = map[string]*AtServer{}
+	e.mu.Unlock()
+
+	for _, server := range servers {
+		server.Close()
+	}
+	return e.Root.Close()
+}
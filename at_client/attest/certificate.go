@@ -0,0 +1,133 @@
+package attest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"time"
+)
+
+// Certificate is a certificate for localhost, 127.0.0.1 and ::1 that the fake servers
+// present and that clients are configured to trust. NewCertificate makes a self-signed one,
+// and NewCertificateChain one issued through an intermediate CA.
+type Certificate struct {
+	TLSCertificate tls.Certificate
+	CertPool       *x509.CertPool
+	PEM            []byte
+	// Chain is the certificates from the leaf to the root in CertPool.
+	Chain []*x509.Certificate
+}
+
+func NewCertificate() (*Certificate, error) {
+	leaf, privateKey, err := newCertificate(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "attest"},
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IsCA:        true,
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1"), net.IPv6loopback},
+	}, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return newChain(privateKey, leaf), nil
+}
+
+// NewCertificateChain returns a certificate issued by an intermediate CA, which the server
+// presents with it, that is issued by a root CA.
+func NewCertificateChain() (*Certificate, error) {
+	root, rootKey, err := newCertificate(&x509.Certificate{
+		Subject:  pkix.Name{CommonName: "attest root CA"},
+		KeyUsage: x509.KeyUsageCertSign,
+		IsCA:     true,
+	}, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	intermediate, intermediateKey, err := newCertificate(&x509.Certificate{
+		Subject:  pkix.Name{CommonName: "attest intermediate CA"},
+		KeyUsage: x509.KeyUsageCertSign,
+		IsCA:     true,
+	}, root, rootKey)
+	if err != nil {
+		return nil, err
+	}
+	leaf, leafKey, err := newCertificate(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "attest"},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1"), net.IPv6loopback},
+	}, intermediate, intermediateKey)
+	if err != nil {
+		return nil, err
+	}
+	return newChain(leafKey, leaf, intermediate, root), nil
+}
+
+// newCertificate fills in template's serial number and validity and signs it with the
+// issuer's key, or self-signs it if issuer is nil.
+func newCertificate(template *x509.Certificate, issuer *x509.Certificate, issuerKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	template.SerialNumber = serialNumber
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(24 * time.Hour)
+	template.BasicConstraintsValid = true
+	if issuer == nil {
+		issuer, issuerKey = template, privateKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &privateKey.PublicKey, issuerKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return certificate, privateKey, nil
+}
+
+// newChain returns the Certificate for chain, from the leaf, whose key is privateKey, to the
+// root. The server presents every certificate but the root.
+func newChain(privateKey *ecdsa.PrivateKey, chain ...*x509.Certificate) *Certificate {
+	leaf, root := chain[0], chain[len(chain)-1]
+	certPool := x509.NewCertPool()
+	certPool.AddCert(root)
+
+	var presented [][]byte
+	for i, certificate := range chain {
+		if i == 0 || i < len(chain)-1 {
+			presented = append(presented, certificate.Raw)
+		}
+	}
+	return &Certificate{
+		TLSCertificate: tls.Certificate{Certificate: presented, PrivateKey: privateKey, Leaf: leaf},
+		CertPool:       certPool,
+		PEM:            pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root.Raw}),
+		Chain:          chain,
+	}
+}
+
+// ServerTLSConfig returns the configuration the fake servers listen with.
+func (c *Certificate) ServerTLSConfig() *tls.Config {
+	return &tls.Config{Certificates: []tls.Certificate{c.TLSCertificate}}
+}
+
+// ClientTLSConfig returns a configuration that trusts only this certificate.
+func (c *Certificate) ClientTLSConfig() *tls.Config {
+	return &tls.Config{RootCAs: c.CertPool}
+}
@@ -0,0 +1,187 @@
+package attest
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+const otpValidity = 5 * time.Minute
+
+type enrollment struct {
+	AppName                              string            `json:"appName"`
+	DeviceName                           string            `json:"deviceName"`
+	Namespaces                           map[string]string `json:"namespace"`
+	EncryptedAPKAMSymmetricKey           string            `json:"encryptedAPKAMSymmetricKey"`
+	Status                               string            `json:"status"`
+	ApkamPublicKey                       string            `json:"-"`
+	EncryptedDefaultEncryptionPrivateKey string            `json:"-"`
+	EncryptedDefaultSelfEncryptionKey    string            `json:"-"`
+}
+
+type enrollParams struct {
+	EnrollmentId                         string            `json:"enrollmentId"`
+	AppName                              string            `json:"appName"`
+	DeviceName                           string            `json:"deviceName"`
+	Namespaces                           map[string]string `json:"namespaces"`
+	OTP                                  string            `json:"otp"`
+	ApkamPublicKey                       string            `json:"apkamPublicKey"`
+	EncryptedAPKAMSymmetricKey           string            `json:"encryptedAPKAMSymmetricKey"`
+	EncryptedDefaultEncryptionPrivateKey string            `json:"encryptedDefaultEncryptionPrivateKey"`
+	EncryptedDefaultSelfEncryptionKey    string            `json:"encryptedDefaultSelfEncryptionKey"`
+	EnrollmentStatusFilter               []string          `json:"enrollmentStatusFilter"`
+}
+
+func enrollmentResponse(enrollmentId string, status string) string {
+	response, _ := json.Marshal(map[string]string{"enrollmentId": enrollmentId, "status": status})
+	return "data:" + string(response)
+}
+
+// enroll handles enroll:<operation>[:<json>]. Requests are accepted on unauthenticated
+// connections with a valid OTP; every other operation needs a connection authenticated with
+// the atSign's own PKAM key.
+func (sc *serverConnection) enroll(arguments string) string {
+	operation, paramsJSON, _ := strings.Cut(arguments, ":")
+	params := enrollParams{}
+	if paramsJSON != "" {
+		if err := json.Unmarshal([]byte(paramsJSON), &params); err != nil {
+			return "error:AT0003-Invalid syntax : " + err.Error()
+		}
+	}
+
+	s := sc.server
+	if operation == "request" {
+		return sc.enrollRequest(params)
+	}
+	if !sc.authenticated || sc.enrollmentId != "" {
+		return "error:AT0401-Client authentication failed : enroll:" + operation + " requires the atSign's own PKAM key"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if operation == "list" {
+		enrollments := map[string]*enrollment{}
+		for id, e := range s.enrollments {
+			if len(params.EnrollmentStatusFilter) > 0 && !contains(params.EnrollmentStatusFilter, e.Status) {
+				continue
+			}
+			enrollments[id+".new.enrollments.__manage"+s.AtSign.AtSignStr] = e
+		}
+		response, _ := json.Marshal(enrollments)
+		return "data:" + string(response)
+	}
+
+	e, ok := s.enrollments[params.EnrollmentId]
+	if !ok {
+		return "error:AT0028-Enrollment id not found : " + params.EnrollmentId
+	}
+	switch operation {
+	case "approve":
+		if e.Status != "pending" {
+			return "error:AT0030-Invalid enrollment status : enrollment " + params.EnrollmentId + " is " + e.Status
+		}
+		e.Status = "approved"
+		e.EncryptedDefaultEncryptionPrivateKey = params.EncryptedDefaultEncryptionPrivateKey
+		e.EncryptedDefaultSelfEncryptionKey = params.EncryptedDefaultSelfEncryptionKey
+	case "deny":
+		if e.Status != "pending" {
+			return "error:AT0030-Invalid enrollment status : enrollment " + params.EnrollmentId + " is " + e.Status
+		}
+		e.Status = "denied"
+	case "revoke":
+		if e.Status != "approved" {
+			return "error:AT0030-Invalid enrollment status : enrollment " + params.EnrollmentId + " is " + e.Status
+		}
+		e.Status = "revoked"
+	default:
+		return "error:AT0003-Invalid syntax : unknown enroll operation " + operation
+	}
+	return enrollmentResponse(params.EnrollmentId, e.Status)
+}
+
+func (sc *serverConnection) enrollRequest(params enrollParams) string {
+	if params.AppName == "" || params.DeviceName == "" || params.ApkamPublicKey == "" {
+		return "error:AT0022-Illegal arguments : appName, deviceName and apkamPublicKey are required"
+	}
+
+	s := sc.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := "approved"
+	if !sc.authenticated {
+		expiry, ok := s.otps[params.OTP]
+		if !ok || time.Now().After(expiry) {
+			return "error:AT0011-Internal server exception : invalid otp. Cannot process enroll request"
+		}
+		delete(s.otps, params.OTP)
+		status = "pending"
+	}
+
+	enrollmentId := randomHex(16)
+	s.enrollments[enrollmentId] = &enrollment{
+		AppName:                    params.AppName,
+		DeviceName:                 params.DeviceName,
+		Namespaces:                 params.Namespaces,
+		EncryptedAPKAMSymmetricKey: params.EncryptedAPKAMSymmetricKey,
+		Status:                     status,
+		ApkamPublicKey:             params.ApkamPublicKey,
+	}
+	return enrollmentResponse(enrollmentId, status)
+}
+
+func (sc *serverConnection) otp() string {
+	if sc.enrollmentId != "" {
+		return "error:AT0401-Client authentication failed : otp:get requires the atSign's own PKAM key"
+	}
+	otp := strings.ToUpper(randomHex(3))
+	s := sc.server
+	s.mu.Lock()
+	s.otps[otp] = time.Now().Add(otpValidity)
+	s.mu.Unlock()
+	return "data:" + otp
+}
+
+// keys handles keys:get:keyName:<enrollmentId>.<name>.__manage@alice, returning a key the
+// approver encrypted for the enrollment.
+func (sc *serverConnection) keys(arguments string) string {
+	keyName, ok := strings.CutPrefix(arguments, "get:keyName:")
+	if !ok {
+		return "error:AT0003-Invalid syntax : only keys:get:keyName is supported"
+	}
+	enrollmentId, name, _ := strings.Cut(strings.TrimSuffix(keyName, ".__manage"+sc.server.AtSign.AtSignStr), ".")
+	if sc.enrollmentId != "" && sc.enrollmentId != enrollmentId {
+		return "error:AT0009-Unauthorized : " + keyName + " belongs to another enrollment"
+	}
+
+	s := sc.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, found := s.enrollments[enrollmentId]
+	if !found {
+		return keyNotFound(keyName)
+	}
+
+	value := ""
+	switch name {
+	case "default_enc_private_key":
+		value = e.EncryptedDefaultEncryptionPrivateKey
+	case "default_self_enc_key":
+		value = e.EncryptedDefaultSelfEncryptionKey
+	}
+	if value == "" {
+		return keyNotFound(keyName)
+	}
+	response, _ := json.Marshal(map[string]string{"value": value})
+	return "data:" + string(response)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
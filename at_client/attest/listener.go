@@ -0,0 +1,74 @@
+package attest
+
+import (
+	"net"
+	"sync"
+)
+
+// listenerServer accepts connections until closed, and on close waits for all handlers to
+// return after closing their connections.
+type listenerServer struct {
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+}
+
+func serve(listener net.Listener, handle func(conn net.Conn)) *listenerServer {
+	s := &listenerServer{
+		listener: listener,
+		conns:    map[net.Conn]struct{}{},
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			if s.closed {
+				s.mu.Unlock()
+				conn.Close()
+				return
+			}
+			s.conns[conn] = struct{}{}
+			s.mu.Unlock()
+
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				defer func() {
+					conn.Close()
+					s.mu.Lock()
+					delete(s.conns, conn)
+					s.mu.Unlock()
+				}()
+				handle(conn)
+			}()
+		}
+	}()
+	return s
+}
+
+// dropConnections closes every open connection but keeps accepting new ones.
+func (s *listenerServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *listenerServer) close() error {
+	err := s.listener.Close()
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.dropConnections()
+	s.wg.Wait()
+	return err
+}
@@ -0,0 +1,94 @@
+package attest
+
+import (
+	"bufio"
+	"crypto/tls"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/atsign-foundation/at_go/at_client/common"
+	"github.com/atsign-foundation/at_go/at_client/connections"
+)
+
+// RootServer is a fake root directory that answers secondary lookups from an in-memory map.
+type RootServer struct {
+	listener net.Listener
+	server   *listenerServer
+
+	mu          sync.Mutex
+	secondaries map[string]string
+	lookups     map[string]int
+}
+
+// NewRootServer starts a root server on a random local port.
+func NewRootServer(config *tls.Config) (*RootServer, error) {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		return nil, err
+	}
+	root := &RootServer{
+		listener:    listener,
+		secondaries: map[string]string{},
+		lookups:     map[string]int{},
+	}
+	root.server = serve(listener, root.handle)
+	return root, nil
+}
+
+func (r *RootServer) Address() connections.Address {
+	return *connections.NewAddress("127.0.0.1", r.listener.Addr().(*net.TCPAddr).Port)
+}
+
+// Register makes the root server return address for atSign.
+func (r *RootServer) Register(atSign common.AtSign, address connections.Address) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.secondaries[strings.ToLower(atSign.WithoutPrefix)] = address.String()
+}
+
+// Unregister makes the root server return null for atSign.
+func (r *RootServer) Unregister(atSign common.AtSign) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.secondaries, strings.ToLower(atSign.WithoutPrefix))
+}
+
+// Lookups returns how many times atSign has been looked up.
+func (r *RootServer) Lookups(atSign common.AtSign) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lookups[strings.ToLower(atSign.WithoutPrefix)]
+}
+
+func (r *RootServer) Close() error {
+	return r.server.close()
+}
+
+func (r *RootServer) handle(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	if _, err := conn.Write([]byte("@")); err != nil {
+		return
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		query := strings.ToLower(strings.TrimLeft(strings.TrimSpace(line), "@"))
+		if query == "exit" {
+			return
+		}
+
+		r.mu.Lock()
+		address, ok := r.secondaries[query]
+		r.lookups[query]++
+		r.mu.Unlock()
+		if !ok {
+			address = "null"
+		}
+		if _, err := conn.Write([]byte(address + "\r\n@")); err != nil {
+			return
+		}
+	}
+}
@@ -134,8 +134,8 @@ func (a *AtKeyBase) String() string {
 	s := ""
 	if a.Metadata.IsPublic {
 		s += "public:"
-	} else if a.SharedBy != nil {
-		s += a.SharedBy.AtSignStr + ":"
+	} else if a.SharedWith != nil && a.SharedWith.AtSignStr != "" {
+		s += a.SharedWith.AtSignStr + ":"
 	}
 	s += a.GetFullyQualifiedKeyName()
 	if a.SharedBy != nil {
@@ -157,6 +157,7 @@ func NewPublicKey(name string, sharedBy *AtSign) *PublicKey {
 		AtKeyBase: AtKeyBase{
 			Name:     name,
 			SharedBy: sharedBy,
+			Metadata: Metadata{IsPublic: true},
 		},
 	}
 }
@@ -170,22 +171,20 @@ func (pk *PublicKey) Cache(ttr int, ccd bool) *PublicKey {
 
 type SelfKey struct {
 	AtKeyBase
-	SharedWith *AtSign
 }
 
 func NewSelfKey(name string, sharedBy *AtSign, sharedWith *AtSign) *SelfKey {
 	return &SelfKey{
 		AtKeyBase: AtKeyBase{
-			Name:     name,
-			SharedBy: sharedBy,
+			Name:       name,
+			SharedBy:   sharedBy,
+			SharedWith: sharedWith,
 		},
-		SharedWith: sharedWith,
 	}
 }
 
 type SharedKey struct {
 	AtKeyBase
-	SharedWith *AtSign
 }
 
 func NewSharedKey(name string, sharedBy *AtSign, sharedWith *AtSign) *SharedKey {
@@ -194,10 +193,10 @@ func NewSharedKey(name string, sharedBy *AtSign, sharedWith *AtSign) *SharedKey
 	}
 	return &SharedKey{
 		AtKeyBase: AtKeyBase{
-			Name:     name,
-			SharedBy: sharedBy,
+			Name:       name,
+			SharedBy:   sharedBy,
+			SharedWith: sharedWith,
 		},
-		SharedWith: sharedWith,
 	}
 }
 
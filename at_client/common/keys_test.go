@@ -0,0 +1,30 @@
+package common_test
+
+import (
+	"testing"
+
+	"github.com/atsign-foundation/at_go/at_client/common"
+)
+
+func TestAtKeyString(t *testing.T) {
+	alice, bob := common.NewAtSign("@alice"), common.NewAtSign("@bob")
+
+	namespaced := common.NewSharedKey("message", alice, bob)
+	namespaced.SetNamespace("wavi")
+
+	tests := []struct {
+		key  common.AtKey
+		want string
+	}{
+		{common.NewSelfKey("phone", alice, nil), "phone@alice"},
+		{common.NewPublicKey("location", alice), "public:location@alice"},
+		{common.NewSharedKey("message", alice, bob), "@bob:message@alice"},
+		{common.NewSharedKey("message", bob, alice), "@alice:message@bob"},
+		{namespaced, "@bob:message.wavi@alice"},
+	}
+	for _, test := range tests {
+		if got := test.key.String(); got != test.want {
+			t.Errorf("String() = %q, want %q", got, test.want)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package common
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -34,6 +33,7 @@ type Metadata struct {
 	SharedKeyEnc    string     `json:"sharedKeyEnc,omitempty"`
 	PubKeyCS        string     `json:"pubKeyCS,omitempty"`
 	Encoding        string     `json:"encoding,omitempty"`
+	EncAlgo         string     `json:"encAlgo,omitempty"`
 	IVNonce         string     `json:"ivNonce,omitempty"`
 }
 
@@ -79,12 +79,13 @@ func FromJSON(jsonStr string) (*Metadata, error) {
 		"sharedKeyEnc":    &metadata.SharedKeyEnc,
 		"pubKeyCS":        &metadata.PubKeyCS,
 		"encoding":        &metadata.Encoding,
+		"encAlgo":         &metadata.EncAlgo,
 		"ivNonce":         &metadata.IVNonce,
 	}
 
 	for field, target := range fields {
 		value, exists := data[field]
-		if exists {
+		if exists && value != nil {
 			switch field {
 			case "availableAt", "expiresAt", "refreshAt", "createdAt", "updatedAt":
 				if valString, ok := value.(string); ok {
@@ -113,7 +114,7 @@ func FromJSON(jsonStr string) (*Metadata, error) {
 				} else {
 					return nil, errors.New("Field 'version' not valid")
 				}
-			case "createdBy", "updatedBy", "status", "dataSignature", "sharedKeyStatus", "sharedKeyEnc", "pubKeyCS", "encoding", "ivNonce":
+			case "createdBy", "updatedBy", "status", "dataSignature", "sharedKeyStatus", "sharedKeyEnc", "pubKeyCS", "encoding", "encAlgo", "ivNonce":
 				if valString, ok := value.(string); ok {
 					*target.(*string) = valString
 				} else {
@@ -165,11 +166,11 @@ func (metadata *Metadata) String() string {
 	if metadata.Encoding != "" {
 		s += fmt.Sprintf(":encoding:%s", metadata.Encoding)
 	}
+	if metadata.EncAlgo != "" {
+		s += fmt.Sprintf(":encAlgo:%s", metadata.EncAlgo)
+	}
 	if metadata.IVNonce != "" {
-		ivNonce, err := base64.StdEncoding.DecodeString(metadata.IVNonce)
-		if err == nil {
-			s += fmt.Sprintf(":ivNonce:%s", string(ivNonce))
-		}
+		s += fmt.Sprintf(":ivNonce:%s", metadata.IVNonce)
 	}
 	return s
 }
@@ -248,6 +249,11 @@ func Squash(firstMetadata, secondMetadata *Metadata) *Metadata {
 	} else {
 		metadata.Encoding = secondMetadata.Encoding
 	}
+	if firstMetadata.EncAlgo != "" {
+		metadata.EncAlgo = firstMetadata.EncAlgo
+	} else {
+		metadata.EncAlgo = secondMetadata.EncAlgo
+	}
 	if firstMetadata.IVNonce != "" {
 		metadata.IVNonce = firstMetadata.IVNonce
 	} else {
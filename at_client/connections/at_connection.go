@@ -3,10 +3,20 @@ package connections
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/atsign-foundation/at_go/at_client/exceptions"
 )
 
+// DefaultPipelineWindow is the number of commands ExecuteCommandsContext writes ahead of the
+// responses it has read when no window is given.
+const DefaultPipelineWindow = 64
+
 type AtConnection struct {
 	host       string
 	port       int
@@ -14,96 +24,208 @@ type AtConnection struct {
 	config     *tls.Config
 	verbose    bool
 	connection *tls.Conn
+	reader     *FrameReader
 	connected  bool
+
+	// pinnedSPKIHashes are checked by dialConfig; see WithPinnedSPKIHashes.
+	pinnedSPKIHashes []string
+	// dialer opens the TCP connection TLS runs over; nil means DefaultDialer.
+	dialer Dialer
+
+	// MaxResponseSize limits the size of a single response. Zero means no limit.
+	MaxResponseSize int
+
+	// mu is held while a command is sent and its response read, so that concurrent callers
+	// each get the response to their own command. stateMu guards connection, reader and
+	// connected, and is never held during I/O, so Disconnect can interrupt a blocked read.
+	mu      sync.Mutex
+	stateMu sync.Mutex
+}
+
+// ConnectionOption configures optional behaviour of an AtConnection.
+type ConnectionOption func(*AtConnection)
+
+// WithTLSConfig sets the TLS configuration used when dialling. Options given after it, such as
+// WithRootCAs, change a copy of config.
+func WithTLSConfig(config *tls.Config) ConnectionOption {
+	return func(atconn *AtConnection) {
+		atconn.config = config.Clone()
+	}
 }
 
-func NewAtConnection(host string, port int, ctx context.Context, verbose bool) *AtConnection {
+// NewAtConnection returns an unconnected AtConnection. ctx is used by Connect and
+// ExecuteCommand; use ConnectContext and ExecuteCommandContext to override it per call.
+func NewAtConnection(host string, port int, ctx context.Context, verbose bool, options ...ConnectionOption) *AtConnection {
 
-	// config := &tls.Config{
-	// 	MinVersion: tls.VersionTLS12,
-	// 	MaxVersion: tls.VersionTLS13,
-	// }
+	if ctx == nil {
+		ctx = context.Background()
+	}
 
-	return &AtConnection{
+	atconn := &AtConnection{
 		host: host,
 		port: port,
 		ctx:  ctx,
-		// config:    config,
+		config: &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		},
 		verbose:   verbose,
 		connected: false,
 	}
+	for _, option := range options {
+		option(atconn)
+	}
+	return atconn
 }
 
 func (atconn *AtConnection) String() string {
 	return fmt.Sprintf("%s:%d", atconn.host, atconn.port)
 }
 
-func (atconn *AtConnection) write(data string) {
-	atconn.connection.Write([]byte(data))
+func (atconn *AtConnection) write(connection *tls.Conn, data string) error {
+	_, err := connection.Write([]byte(data))
+	return err
 }
 
-func (atconn *AtConnection) read() string {
-	response := ""
-	buf := make([]byte, 1024)
-	for {
-		chunk, err := atconn.connection.Read(buf)
-		if err != nil {
-			break
-		}
-		// fmt.Println(string(buf))
-		response += string(buf[:chunk])
-		if string(buf[:chunk]) == "@" || strings.Contains(string(buf[:chunk]), "\n") {
-			break
-		}
+func (atconn *AtConnection) read(reader *FrameReader) (string, error) {
+	reader.MaxFrameSize = atconn.MaxResponseSize
+	return reader.ReadFrame()
+}
+
+// ReadFrame blocks until the server sends its next response, e.g. a notification on a
+// connection that is monitoring, and returns it without the surrounding prompts.
+func (atconn *AtConnection) ReadFrame() (string, error) {
+	atconn.mu.Lock()
+	defer atconn.mu.Unlock()
+
+	_, reader, connected := atconn.state()
+	if !connected {
+		return "", fmt.Errorf("Not connected")
 	}
-	return response
+	return atconn.read(reader)
 }
 
 func (atconn *AtConnection) IsConnected() bool {
-	return atconn.connected
+	_, _, connected := atconn.state()
+	return connected
+}
+
+// state returns the current connection, its reader, and whether it is connected.
+func (atconn *AtConnection) state() (*tls.Conn, *FrameReader, bool) {
+	atconn.stateMu.Lock()
+	defer atconn.stateMu.Unlock()
+	return atconn.connection, atconn.reader, atconn.connected
 }
 
 func (atconn *AtConnection) Connect() error {
-	if !atconn.connected {
-		address := fmt.Sprintf("%s:%d", atconn.host, atconn.port)
-		dirconn, err := tls.Dial("tcp", address, atconn.config)
-		if err != nil {
-			return err
-		}
-		atconn.connection = dirconn
-		atconn.connected = true
-		atconn.read()
+	return atconn.ConnectContext(atconn.ctx)
+}
+
+// ConnectContext dials the server and reads its prompt. It returns an AtTimeoutException if
+// ctx expires first.
+func (atconn *AtConnection) ConnectContext(ctx context.Context) error {
+	atconn.mu.Lock()
+	defer atconn.mu.Unlock()
+
+	if atconn.IsConnected() {
+		return nil
+	}
+
+	dialer := atconn.dialer
+	if dialer == nil {
+		// An https:// proxy is trusted like the server
+		dialer = newEnvironmentDialer(atconn.tlsConfig().RootCAs)
+	}
+	rawConnection, err := dialer.DialContext(ctx, "tcp", atconn.String())
+	if err != nil {
+		return contextError(ctx, "connect to "+atconn.String(), err)
+	}
+
+	// The TLS handshake runs through any proxy tunnel the dialer opened
+	config := atconn.dialConfig()
+	if config.ServerName == "" {
+		config.ServerName = atconn.host
+	}
+	connection := tls.Client(rawConnection, config)
+	if err := connection.HandshakeContext(ctx); err != nil {
+		rawConnection.Close()
+		return contextError(ctx, "connect to "+atconn.String(), err)
+	}
+	reader := NewFrameReader(connection)
+
+	atconn.stateMu.Lock()
+	atconn.connection = connection
+	atconn.reader = reader
+	atconn.connected = true
+	atconn.stateMu.Unlock()
+
+	stop := atconn.applyContext(ctx, connection)
+	defer stop()
+	if err := reader.ReadPrompt(); err != nil {
+		atconn.Disconnect()
+		return contextError(ctx, "read prompt from "+atconn.String(), err)
 	}
 	return nil
 }
 
+// Disconnect closes the connection. It may be called while another goroutine is executing a
+// command, which then fails.
 func (atconn *AtConnection) Disconnect() {
-	atconn.connection.Close()
+	atconn.stateMu.Lock()
+	defer atconn.stateMu.Unlock()
+	if atconn.connection != nil {
+		atconn.connection.Close()
+	}
 	atconn.connected = false
 }
 
 func (atconn *AtConnection) ExecuteCommand(command string, readTheResponse bool) (*Response, error) {
-	// atconn.connection.SetWriteDeadline(time.Now().Add(10*time.Second))
+	return atconn.ExecuteCommandContext(atconn.ctx, command, readTheResponse)
+}
+
+// ExecuteCommandContext is ExecuteCommand with the read and write deadlines taken from ctx.
+// Cancelling ctx interrupts an in-flight read. Because the rest of the response may still
+// arrive, the connection is closed after any I/O failure.
+//
+// Commands from concurrent callers are executed one at a time, in the order they acquire the
+// connection, and each caller gets the response to its own command.
+func (atconn *AtConnection) ExecuteCommandContext(ctx context.Context, command string, readTheResponse bool) (*Response, error) {
+	atconn.mu.Lock()
+	defer atconn.mu.Unlock()
+
 	response := NewResponse()
-	if !atconn.connected {
+	connection, reader, connected := atconn.state()
+	if !connected {
 		return response, fmt.Errorf("Not connected")
 	}
+	if ctx.Err() != nil {
+		return response, contextError(ctx, "execute "+strings.TrimSpace(command), ctx.Err())
+	}
+
+	stop := atconn.applyContext(ctx, connection)
+	defer stop()
 
 	if !strings.HasSuffix(command, "\n") {
 		command += "\n"
 	}
-	atconn.write(command)
+	if err := atconn.write(connection, command); err != nil {
+		atconn.Disconnect()
+		return response, contextError(ctx, "send "+strings.TrimSpace(command), err)
+	}
 
 	if atconn.verbose {
 		fmt.Printf("\tSENT: %s\n", command)
 	}
 
 	if readTheResponse {
-		rawResponse := atconn.read()
+		rawResponse, err := atconn.read(reader)
+		if err != nil {
+			atconn.Disconnect()
+			return response, contextError(ctx, "read response to "+strings.TrimSpace(command), err)
+		}
 		if atconn.verbose {
 			fmt.Printf("\tRCVD: %s\n", rawResponse)
 		}
-		response := atRootConnection.ParseRawResponse(rawResponse)
+		response := NewResponse().SetRawDataResponse(rawResponse)
 		return response, nil
 	}
 
@@ -111,3 +233,94 @@ func (atconn *AtConnection) ExecuteCommand(command string, readTheResponse bool)
 
 	return response, nil
 }
+
+// ExecuteCommandsContext pipelines commands: it writes up to window commands ahead of the
+// responses it has read, and returns the responses in the order of the commands. The commands
+// are executed without any other caller's commands in between. If the connection fails, the
+// responses read so far are returned with the error.
+func (atconn *AtConnection) ExecuteCommandsContext(ctx context.Context, commands []string, window int) ([]*Response, error) {
+	atconn.mu.Lock()
+	defer atconn.mu.Unlock()
+
+	responses := make([]*Response, 0, len(commands))
+	connection, reader, connected := atconn.state()
+	if !connected {
+		return responses, fmt.Errorf("Not connected")
+	}
+	if ctx.Err() != nil {
+		return responses, contextError(ctx, "execute pipelined commands", ctx.Err())
+	}
+	if window <= 0 {
+		window = DefaultPipelineWindow
+	}
+
+	stop := atconn.applyContext(ctx, connection)
+	defer stop()
+
+	sent := 0
+	for len(responses) < len(commands) {
+		var batch strings.Builder
+		for ; sent < len(commands) && sent-len(responses) < window; sent++ {
+			command := commands[sent]
+			if !strings.HasSuffix(command, "\n") {
+				command += "\n"
+			}
+			batch.WriteString(command)
+			if atconn.verbose {
+				fmt.Printf("\tSENT: %s", command)
+			}
+		}
+		if batch.Len() > 0 {
+			if err := atconn.write(connection, batch.String()); err != nil {
+				atconn.Disconnect()
+				return responses, contextError(ctx, "send pipelined commands", err)
+			}
+		}
+
+		rawResponse, err := atconn.read(reader)
+		if err != nil {
+			atconn.Disconnect()
+			return responses, contextError(ctx, "read response to "+strings.TrimSpace(commands[len(responses)]), err)
+		}
+		if atconn.verbose {
+			fmt.Printf("\tRCVD: %s\n", rawResponse)
+		}
+		responses = append(responses, NewResponse().SetRawDataResponse(rawResponse))
+	}
+	return responses, nil
+}
+
+// applyContext sets the connection deadline to ctx's deadline and arranges for blocked I/O to
+// be interrupted if ctx is cancelled. The returned function undoes both and must be called once
+// the I/O has finished.
+func (atconn *AtConnection) applyContext(ctx context.Context, connection *tls.Conn) func() {
+	deadline, _ := ctx.Deadline()
+	connection.SetDeadline(deadline)
+
+	interrupted := make(chan struct{})
+	stopAfterFunc := context.AfterFunc(ctx, func() {
+		connection.SetDeadline(time.Now())
+		close(interrupted)
+	})
+
+	return func() {
+		if !stopAfterFunc() {
+			<-interrupted
+		}
+		connection.SetDeadline(time.Time{})
+	}
+}
+
+// contextError maps an I/O error caused by ctx expiring (or by a connection deadline) to an
+// AtTimeoutException. Cancellation is reported as ctx.Err().
+func contextError(ctx context.Context, what string, err error) error {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return fmt.Errorf("Failed to %s: %w", what, ctx.Err())
+	}
+	var netErr net.Error
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) || errors.Is(err, context.DeadlineExceeded) ||
+		(errors.As(err, &netErr) && netErr.Timeout()) {
+		return exceptions.NewAtTimeoutException("Timed out trying to " + what)
+	}
+	return err
+}
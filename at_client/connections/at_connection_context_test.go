@@ -0,0 +1,191 @@
+package connections
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atsign-foundation/at_go/at_client/exceptions"
+)
+
+// newCertificate returns a self-signed certificate for 127.0.0.1 and atserver.test.
+func newCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		DNSNames:              []string{"atserver.test"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// startServer starts a TLS server that sends a prompt and answers each command with "data:"
+// and the command, except for commands starting with "sleep", which are never answered. It
+// returns an unconnected AtConnection to the server.
+func startServer(t *testing.T) *AtConnection {
+	t.Helper()
+	certificate := newCertificate(t)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.Write([]byte("@"))
+				lines := bufio.NewScanner(conn)
+				for lines.Scan() {
+					if strings.HasPrefix(lines.Text(), "sleep") {
+						continue
+					}
+					conn.Write([]byte("data:" + lines.Text() + "\n@alice@"))
+				}
+			}()
+		}
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(certificate.Leaf)
+	atconn := NewAtConnection("127.0.0.1", listener.Addr().(*net.TCPAddr).Port, context.Background(), false)
+	atconn.config = &tls.Config{RootCAs: roots}
+	t.Cleanup(func() {
+		if atconn.IsConnected() {
+			atconn.Disconnect()
+		}
+	})
+	return atconn
+}
+
+func TestExecuteCommandContextTimesOut(t *testing.T) {
+	atconn := startServer(t)
+	if err := atconn.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := atconn.ExecuteCommandContext(ctx, "sleep", true)
+	var timeout *exceptions.AtTimeoutException
+	if !errors.As(err, &timeout) {
+		t.Fatalf("ExecuteCommandContext error = %v, want AtTimeoutException", err)
+	}
+	if atconn.IsConnected() {
+		t.Error("connection is still open after a timed out read")
+	}
+}
+
+func TestExecuteCommandContextCancelled(t *testing.T) {
+	atconn := startServer(t)
+	if err := atconn.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, err := atconn.ExecuteCommandContext(ctx, "sleep", true)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ExecuteCommandContext error = %v, want context.Canceled", err)
+	}
+	if atconn.IsConnected() {
+		t.Error("connection is still open after a cancelled read")
+	}
+
+	if _, err := atconn.ExecuteCommandContext(context.Background(), "noop:0", true); err == nil {
+		t.Error("ExecuteCommandContext succeeded on the closed connection")
+	}
+}
+
+func TestExecuteCommandContextDeadlineIsCleared(t *testing.T) {
+	atconn := startServer(t)
+	if err := atconn.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := atconn.ExecuteCommandContext(ctx, "noop:0", true); err != nil {
+		t.Fatal(err)
+	}
+
+	// The deadline of the earlier command must not apply to this one
+	time.Sleep(100 * time.Millisecond)
+	response, err := atconn.ExecuteCommandContext(context.Background(), "noop:0", true)
+	if err != nil {
+		t.Fatalf("ExecuteCommandContext after an earlier deadline passed: %v", err)
+	}
+	if !strings.Contains(response.GetRawDataResponse(), "data:noop:0") {
+		t.Errorf("response = %q", response.GetRawDataResponse())
+	}
+}
+
+func TestConnectContextTimesOut(t *testing.T) {
+	// A server that accepts the TCP connection but never completes the TLS handshake
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+	defer func() {
+		select {
+		case conn := <-accepted:
+			conn.Close()
+		default:
+		}
+	}()
+
+	atconn := NewAtConnection("127.0.0.1", listener.Addr().(*net.TCPAddr).Port, context.Background(), false)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = atconn.ConnectContext(ctx)
+	var timeout *exceptions.AtTimeoutException
+	if !errors.As(err, &timeout) {
+		t.Fatalf("ConnectContext error = %v, want AtTimeoutException", err)
+	}
+	if atconn.IsConnected() {
+		t.Error("IsConnected after a timed out connect")
+	}
+}
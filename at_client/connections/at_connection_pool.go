@@ -0,0 +1,337 @@
+package connections
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/atsign-foundation/at_go/at_client/exceptions"
+)
+
+const (
+	DefaultPoolMinSize          = 1
+	DefaultPoolMaxSize          = 4
+	DefaultPoolIdleTimeout      = 5 * time.Minute
+	DefaultPoolHealthCheckAfter = 30 * time.Second
+
+	healthCheckCommand = "noop:0"
+)
+
+// AtConnectionPool lends authenticated AtSecondaryConnections to one atServer, so that
+// commands from several goroutines can be executed in parallel. Connections are opened as
+// they are needed, up to MaxSize, and closed again once idle for IdleTimeout, down to MinSize.
+//
+// Change the exported fields before the pool is first used.
+type AtConnectionPool struct {
+	Address Address
+
+	// Authenticator is run on every new connection, and on every reconnect, e.g. to run PKAM
+	// authentication.
+	Authenticator func(conn *AtConnection) error
+	// OnReconnect, when set, is called after every reconnect attempt of a pooled connection.
+	OnReconnect func(event ReconnectEvent)
+
+	MinSize int
+	MaxSize int
+	// IdleTimeout is how long a connection may go unused before it is closed.
+	IdleTimeout time.Duration
+	// HealthCheckAfter is how long a connection may go unused before it is checked with the
+	// noop verb when it is next borrowed.
+	HealthCheckAfter time.Duration
+
+	verbose bool
+	options []ConnectionOption
+
+	// tokens holds one token per connection that may be lent, so Get blocks once MaxSize
+	// connections are lent.
+	tokens    chan struct{}
+	startOnce sync.Once
+
+	mu     sync.Mutex
+	idle   []*pooledConnection
+	open   int
+	closed bool
+	// stop is closed by Close to stop evictIdle, if Start started it.
+	stop chan struct{}
+}
+
+type pooledConnection struct {
+	conn     *AtSecondaryConnection
+	lastUsed time.Time
+}
+
+// NewAtConnectionPool returns an empty pool of connections to the atServer at address, each
+// authenticated with authenticator.
+func NewAtConnectionPool(address Address, authenticator func(conn *AtConnection) error, verbose bool, options ...ConnectionOption) *AtConnectionPool {
+	return &AtConnectionPool{
+		Address:          address,
+		Authenticator:    authenticator,
+		MinSize:          DefaultPoolMinSize,
+		MaxSize:          DefaultPoolMaxSize,
+		IdleTimeout:      DefaultPoolIdleTimeout,
+		HealthCheckAfter: DefaultPoolHealthCheckAfter,
+		verbose:          verbose,
+		options:          options,
+	}
+}
+
+// Add puts conn, which must already be connected and authenticated, in the pool as an idle
+// connection, e.g. a connection opened before the pool was created. It is closed instead if
+// the pool already has MaxSize connections or is closed.
+func (p *AtConnectionPool) Add(conn *AtSecondaryConnection) {
+	maxSize := p.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultPoolMaxSize
+	}
+
+	p.mu.Lock()
+	if p.closed || p.open >= maxSize {
+		p.mu.Unlock()
+		conn.AtConnection.Disconnect()
+		return
+	}
+	p.open++
+	p.idle = append(p.idle, &pooledConnection{conn: conn, lastUsed: time.Now()})
+	p.mu.Unlock()
+}
+
+// Start opens connections until there are MinSize, and starts evicting idle ones. It is
+// called by the first Get if it has not been called before.
+func (p *AtConnectionPool) Start(ctx context.Context) error {
+	var err error
+	p.startOnce.Do(func() {
+		maxSize := p.MaxSize
+		if maxSize <= 0 {
+			maxSize = DefaultPoolMaxSize
+		}
+		if p.MinSize > maxSize {
+			p.MinSize = maxSize
+		}
+		p.tokens = make(chan struct{}, maxSize)
+		for i := 0; i < maxSize; i++ {
+			p.tokens <- struct{}{}
+		}
+
+		for i := p.Size(); i < p.MinSize && err == nil; i++ {
+			var conn *AtSecondaryConnection
+			if conn, err = p.connect(ctx); err == nil {
+				p.Add(conn)
+			}
+		}
+
+		if p.IdleTimeout > 0 {
+			p.mu.Lock()
+			if !p.closed {
+				p.stop = make(chan struct{})
+				go p.evictIdle(p.stop)
+			}
+			p.mu.Unlock()
+		}
+	})
+	return err
+}
+
+// Get borrows a connection, waiting while MaxSize connections are lent. The connection must be
+// given back with Put.
+func (p *AtConnectionPool) Get(ctx context.Context) (*AtSecondaryConnection, error) {
+	if err := p.Start(ctx); err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-p.tokens:
+	case <-ctx.Done():
+		return nil, contextError(ctx, "borrow a connection to "+p.Address.String(), ctx.Err())
+	}
+
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			p.tokens <- struct{}{}
+			return nil, exceptions.NewAtException("Connection pool for " + p.Address.String() + " is closed")
+		}
+		if len(p.idle) == 0 {
+			p.open++
+			p.mu.Unlock()
+			break
+		}
+		pooled := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		p.mu.Unlock()
+
+		if p.healthy(ctx, pooled) {
+			return pooled.conn, nil
+		}
+		p.discard(pooled.conn)
+	}
+
+	conn, err := p.connect(ctx)
+	if err != nil {
+		p.mu.Lock()
+		p.open--
+		p.mu.Unlock()
+		p.tokens <- struct{}{}
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Put gives back a connection borrowed with Get. Connections that have been lost are closed
+// rather than lent again.
+func (p *AtConnectionPool) Put(conn *AtSecondaryConnection) {
+	p.mu.Lock()
+	if p.closed || !conn.AtConnection.IsConnected() {
+		p.open--
+		p.mu.Unlock()
+		conn.AtConnection.Disconnect()
+	} else {
+		p.idle = append(p.idle, &pooledConnection{conn: conn, lastUsed: time.Now()})
+		p.mu.Unlock()
+	}
+	p.tokens <- struct{}{}
+}
+
+func (p *AtConnectionPool) ExecuteCommand(command string, readTheResponse bool) (*Response, error) {
+	return p.ExecuteCommandContext(context.Background(), command, readTheResponse)
+}
+
+// ExecuteCommandContext borrows a connection, executes command on it and gives it back.
+func (p *AtConnectionPool) ExecuteCommandContext(ctx context.Context, command string, readTheResponse bool) (*Response, error) {
+	conn, err := p.Get(ctx)
+	if err != nil {
+		return NewResponse(), err
+	}
+	defer p.Put(conn)
+	return conn.ExecuteCommandContext(ctx, command, readTheResponse)
+}
+
+// ExecuteCommandsContext borrows a connection, pipelines commands on it and gives it back.
+func (p *AtConnectionPool) ExecuteCommandsContext(ctx context.Context, commands []string, window int) ([]*Response, error) {
+	conn, err := p.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer p.Put(conn)
+	return conn.ExecuteCommandsContext(ctx, commands, window)
+}
+
+// Size returns the number of open connections, idle or lent.
+func (p *AtConnectionPool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.open
+}
+
+// Close closes the idle connections, and each lent connection once it is given back.
+func (p *AtConnectionPool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.open -= len(idle)
+	stop := p.stop
+	p.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+	}
+	for _, pooled := range idle {
+		pooled.conn.AtConnection.Disconnect()
+	}
+}
+
+func (p *AtConnectionPool) connect(ctx context.Context) (*AtSecondaryConnection, error) {
+	conn := &AtSecondaryConnection{
+		AtConnection:         NewAtConnection(p.Address.host, p.Address.port, context.Background(), p.verbose, p.options...),
+		Address:              p.Address,
+		Authenticator:        p.Authenticator,
+		OnReconnect:          p.OnReconnect,
+		MaxReconnectAttempts: DefaultMaxReconnectAttempts,
+		InitialBackoff:       DefaultInitialBackoff,
+		MaxBackoff:           DefaultMaxBackoff,
+	}
+
+	if err := conn.AtConnection.ConnectContext(ctx); err != nil {
+		return nil, exceptions.NewAtSecondaryConnectException("Failed to connect to " + p.Address.String() + " - " + err.Error())
+	}
+	if p.Authenticator != nil {
+		if err := p.Authenticator(conn.AtConnection); err != nil {
+			conn.AtConnection.Disconnect()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// healthy reports whether a connection taken from the idle list can be lent, sending noop
+// if it has been idle for longer than HealthCheckAfter.
+func (p *AtConnectionPool) healthy(ctx context.Context, pooled *pooledConnection) bool {
+	if !pooled.conn.AtConnection.IsConnected() {
+		return false
+	}
+	if p.HealthCheckAfter <= 0 || time.Since(pooled.lastUsed) < p.HealthCheckAfter {
+		return true
+	}
+
+	response, err := pooled.conn.AtConnection.ExecuteCommandContext(ctx, healthCheckCommand, true)
+	if err == nil {
+		response, err = ParseRawResponse(response.GetRawDataResponse())
+	}
+	if err != nil || response.IsError() {
+		if p.verbose {
+			fmt.Printf("\tClosing unhealthy connection to %s\n", p.Address.String())
+		}
+		return false
+	}
+	return true
+}
+
+func (p *AtConnectionPool) discard(conn *AtSecondaryConnection) {
+	conn.AtConnection.Disconnect()
+	p.mu.Lock()
+	p.open--
+	p.mu.Unlock()
+}
+
+// evictIdle closes connections that have been idle for longer than IdleTimeout, keeping at
+// least MinSize open, until the pool is closed.
+func (p *AtConnectionPool) evictIdle(stop <-chan struct{}) {
+	interval := p.IdleTimeout / 2
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		p.mu.Lock()
+		evicted := []*pooledConnection{}
+		kept := p.idle[:0]
+		for _, pooled := range p.idle {
+			if p.open > p.MinSize && time.Since(pooled.lastUsed) > p.IdleTimeout {
+				evicted = append(evicted, pooled)
+				p.open--
+			} else {
+				kept = append(kept, pooled)
+			}
+		}
+		p.idle = kept
+		p.mu.Unlock()
+
+		for _, pooled := range evicted {
+			pooled.conn.AtConnection.Disconnect()
+		}
+	}
+}
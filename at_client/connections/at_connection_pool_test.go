@@ -0,0 +1,233 @@
+package connections_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/atsign-foundation/at_go/at_client/attest"
+	"github.com/atsign-foundation/at_go/at_client/common"
+	"github.com/atsign-foundation/at_go/at_client/connections"
+	"github.com/atsign-foundation/at_go/at_client/exceptions"
+	"github.com/atsign-foundation/at_go/at_client/utils/auth_util"
+)
+
+// newPool returns a pool of PKAM-authenticated connections to the atServer of @alice, and the
+// server, both closed when the test ends.
+func newPool(t *testing.T, minSize, maxSize int) (*connections.AtConnectionPool, *attest.AtServer) {
+	t.Helper()
+	env, err := attest.NewEnvironment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { env.Close() })
+	alice := common.NewAtSign("@alice")
+	server, err := env.AddAtSign(*alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := env.Keys(*alice)
+	authenticate := func(conn *connections.AtConnection) error {
+		return auth_util.AuthenticateWithPkam(conn, *alice, keys)
+	}
+	pool := connections.NewAtConnectionPool(server.Address(), authenticate, false, connections.WithTLSConfig(env.ClientTLSConfig()))
+	pool.MinSize = minSize
+	pool.MaxSize = maxSize
+	t.Cleanup(pool.Close)
+	return pool, server
+}
+
+// checkAuthenticated fails the test unless conn can execute a command that needs authentication.
+func checkAuthenticated(t *testing.T, conn *connections.AtSecondaryConnection) {
+	t.Helper()
+	response, err := conn.ExecuteCommandContext(context.Background(), "scan", true)
+	if err == nil {
+		response, err = connections.ParseRawResponse(response.GetRawDataResponse())
+	}
+	if err == nil && response.IsError() {
+		err = response.GetException()
+	}
+	if err != nil {
+		t.Fatalf("scan on pooled connection: %v", err)
+	}
+}
+
+func TestPoolBorrowAndReturn(t *testing.T) {
+	pool, _ := newPool(t, 1, 2)
+	ctx := context.Background()
+
+	if err := pool.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if size := pool.Size(); size != 1 {
+		t.Fatalf("Size after Start = %d, want MinSize 1", size)
+	}
+
+	first, err := pool.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	checkAuthenticated(t, first)
+	pool.Put(first)
+
+	second, err := pool.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if second != first {
+		t.Error("Get opened a new connection instead of lending the idle one")
+	}
+	pool.Put(second)
+	if size := pool.Size(); size != 1 {
+		t.Errorf("Size = %d, want 1", size)
+	}
+}
+
+func TestPoolGetBlocksAtMaxSize(t *testing.T) {
+	pool, _ := newPool(t, 1, 2)
+	ctx := context.Background()
+
+	lent := []*connections.AtSecondaryConnection{}
+	for i := 0; i < 2; i++ {
+		conn, err := pool.Get(ctx)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		lent = append(lent, conn)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err := pool.Get(timeoutCtx)
+	var timeout *exceptions.AtTimeoutException
+	if !errors.As(err, &timeout) {
+		t.Fatalf("Get with MaxSize connections lent: error = %v, want AtTimeoutException", err)
+	}
+
+	borrowed := make(chan *connections.AtSecondaryConnection)
+	go func() {
+		conn, err := pool.Get(ctx)
+		if err != nil {
+			t.Errorf("Get: %v", err)
+		}
+		borrowed <- conn
+	}()
+	select {
+	case <-borrowed:
+		t.Fatal("Get returned while MaxSize connections were lent")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	pool.Put(lent[0])
+	select {
+	case conn := <-borrowed:
+		if conn != lent[0] {
+			t.Error("blocked Get did not get the connection given back")
+		}
+		pool.Put(conn)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Get still blocked after a connection was given back")
+	}
+	pool.Put(lent[1])
+
+	if size := pool.Size(); size != 2 {
+		t.Errorf("Size = %d, want MaxSize 2", size)
+	}
+}
+
+func TestPoolHealthChecksIdleConnections(t *testing.T) {
+	pool, server := newPool(t, 1, 2)
+	pool.HealthCheckAfter = time.Millisecond
+	ctx := context.Background()
+
+	dropped, err := pool.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	pool.Put(dropped)
+
+	// The connection still looks open until noop is sent on it
+	server.DropConnections()
+	time.Sleep(10 * time.Millisecond)
+
+	conn, err := pool.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer pool.Put(conn)
+	if conn == dropped {
+		t.Fatal("Get lent the connection the server dropped")
+	}
+	if dropped.AtConnection.IsConnected() {
+		t.Error("dropped connection was not closed")
+	}
+	checkAuthenticated(t, conn)
+	if size := pool.Size(); size != 1 {
+		t.Errorf("Size = %d, want 1", size)
+	}
+}
+
+func TestPoolEvictsIdleConnections(t *testing.T) {
+	pool, _ := newPool(t, 1, 3)
+	pool.IdleTimeout = 50 * time.Millisecond
+	ctx := context.Background()
+
+	lent := []*connections.AtSecondaryConnection{}
+	for i := 0; i < 3; i++ {
+		conn, err := pool.Get(ctx)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		lent = append(lent, conn)
+	}
+	for _, conn := range lent {
+		pool.Put(conn)
+	}
+	if size := pool.Size(); size != 3 {
+		t.Fatalf("Size = %d, want 3", size)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for pool.Size() > 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if size := pool.Size(); size != 1 {
+		t.Fatalf("Size after IdleTimeout = %d, want MinSize 1", size)
+	}
+	open := 0
+	for _, conn := range lent {
+		if conn.AtConnection.IsConnected() {
+			open++
+		}
+	}
+	if open != 1 {
+		t.Errorf("%d connections still open, want 1", open)
+	}
+
+	pool.Close()
+	if size := pool.Size(); size != 0 {
+		t.Errorf("Size after Close = %d, want 0", size)
+	}
+	if _, err := pool.Get(ctx); err == nil {
+		t.Error("Get succeeded after Close")
+	}
+}
+
+func TestPoolLiteralCanBeClosed(t *testing.T) {
+	for _, started := range []bool{false, true} {
+		pool := &connections.AtConnectionPool{IdleTimeout: time.Minute}
+		if started {
+			if err := pool.Start(context.Background()); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+		}
+		pool.Close()
+		pool.Close()
+
+		if _, err := pool.Get(context.Background()); err == nil {
+			t.Errorf("Get from a closed pool (started %v) succeeded", started)
+		}
+	}
+}
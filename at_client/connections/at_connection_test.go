@@ -0,0 +1,260 @@
+package connections_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/atsign-foundation/at_go/at_client/attest"
+	"github.com/atsign-foundation/at_go/at_client/connections"
+)
+
+// startEchoServer starts a TLS server that answers each command line with "data:" and the
+// command, after a random delay and split over several writes, followed by an "@alice@"
+// prompt. It returns a connection to the server, which is closed with the server when the
+// test ends.
+func startEchoServer(t *testing.T) *connections.AtConnection {
+	t.Helper()
+	certificate, err := attest.NewCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", certificate.ServerTLSConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer conn.Close()
+				echo(conn)
+			}()
+		}
+	}()
+	t.Cleanup(func() {
+		listener.Close()
+		wg.Wait()
+	})
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	atconn := connections.NewAtConnection("127.0.0.1", port, context.Background(), false,
+		connections.WithTLSConfig(certificate.ClientTLSConfig()))
+	if err := atconn.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(atconn.Disconnect)
+	return atconn
+}
+
+func echo(conn net.Conn) {
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+	if _, err := conn.Write([]byte("@")); err != nil {
+		return
+	}
+	lines := bufio.NewScanner(conn)
+	for lines.Scan() {
+		command := lines.Text()
+		time.Sleep(time.Duration(random.Intn(200)) * time.Microsecond)
+		response := "data:" + command + "\n@alice@"
+		for response != "" {
+			n := 1 + random.Intn(len(response))
+			if _, err := conn.Write([]byte(response[:n])); err != nil {
+				return
+			}
+			response = response[n:]
+		}
+	}
+}
+
+func TestExecuteCommandContextPairsConcurrentResponses(t *testing.T) {
+	atconn := startEchoServer(t)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16*25)
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				command := fmt.Sprintf("command-%d-%d", g, i)
+				response, err := atconn.ExecuteCommandContext(context.Background(), command, true)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if got := response.GetRawDataResponse(); got != "data:"+command {
+					errs <- fmt.Errorf("response to %s = %q", command, got)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestExecuteCommandsContextIsNotInterleaved(t *testing.T) {
+	atconn := startEchoServer(t)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16*10)
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				if g%2 == 0 {
+					command := fmt.Sprintf("single-%d-%d", g, i)
+					response, err := atconn.ExecuteCommandContext(context.Background(), command, true)
+					if err != nil {
+						errs <- err
+						return
+					}
+					if got := response.GetRawDataResponse(); got != "data:"+command {
+						errs <- fmt.Errorf("response to %s = %q", command, got)
+					}
+					continue
+				}
+
+				commands := []string{}
+				for j := 0; j < 7; j++ {
+					commands = append(commands, fmt.Sprintf("pipelined-%d-%d-%d", g, i, j))
+				}
+				responses, err := atconn.ExecuteCommandsContext(context.Background(), commands, 3)
+				if err != nil {
+					errs <- err
+					return
+				}
+				for j, response := range responses {
+					if got := response.GetRawDataResponse(); got != "data:"+commands[j] {
+						errs <- fmt.Errorf("response to %s = %q", commands[j], got)
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// startBatchingServer starts a TLS server that holds back its responses until the client has
+// stopped sending for a moment, then answers every held command like the echo server. It
+// returns a connection to the server and a function returning the most commands it held at once.
+func startBatchingServer(t *testing.T) (*connections.AtConnection, func() int) {
+	t.Helper()
+	certificate, err := attest.NewCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", certificate.ServerTLSConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	maxHeld := 0
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if _, err := conn.Write([]byte("@")); err != nil {
+			return
+		}
+
+		lines := make(chan string, 100)
+		go func() {
+			defer close(lines)
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				lines <- scanner.Text()
+			}
+		}()
+		var held []string
+		for {
+			select {
+			case command, ok := <-lines:
+				if !ok {
+					return
+				}
+				held = append(held, command)
+				mu.Lock()
+				maxHeld = max(maxHeld, len(held))
+				mu.Unlock()
+			case <-time.After(20 * time.Millisecond):
+				for _, command := range held {
+					if _, err := conn.Write([]byte("data:" + command + "\n@alice@")); err != nil {
+						return
+					}
+				}
+				held = nil
+			}
+		}
+	}()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	atconn := connections.NewAtConnection("127.0.0.1", port, context.Background(), false,
+		connections.WithTLSConfig(certificate.ClientTLSConfig()))
+	if err := atconn.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		atconn.Disconnect()
+		listener.Close()
+		wg.Wait()
+	})
+	return atconn, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return maxHeld
+	}
+}
+
+func TestExecuteCommandsContextWindow(t *testing.T) {
+	atconn, maxHeld := startBatchingServer(t)
+
+	commands := []string{}
+	for i := 0; i < 10; i++ {
+		commands = append(commands, fmt.Sprintf("command-%d", i))
+	}
+	responses, err := atconn.ExecuteCommandsContext(context.Background(), commands, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(responses) != len(commands) {
+		t.Fatalf("%d responses to %d commands", len(responses), len(commands))
+	}
+	for i, response := range responses {
+		if got := response.GetRawDataResponse(); got != "data:"+commands[i] {
+			t.Errorf("response to %s = %q", commands[i], got)
+		}
+	}
+	if got := maxHeld(); got != 3 {
+		t.Errorf("at most %d commands were sent ahead of their responses, want the window of 3", got)
+	}
+}
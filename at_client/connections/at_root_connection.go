@@ -3,26 +3,31 @@ package connections
 import (
 	"context"
 	"strings"
-	"sync"
 
 	"github.com/atsign-foundation/at_go/at_client/common"
 	"github.com/atsign-foundation/at_go/at_client/exceptions"
 )
 
-var atRootConnection *AtRootConnection
-var once sync.Once
+const (
+	DefaultRootHost = "root.atsign.org"
+	DefaultRootPort = 64
+)
 
 type AtRootConnection struct {
 	AtConnection *AtConnection
 }
 
-func GetAtRootConnectionInstance() *AtRootConnection {
-	once.Do(func() {
-		atRootConnection = &AtRootConnection{
-			AtConnection: NewAtConnection("root.atsign.org", 64, context.Background(), false),
-		}
-	})
-	return atRootConnection
+// NewAtRootConnection returns an unconnected AtRootConnection for the root server at address.
+// Each AtRootConnection owns its own connection, so several root servers can be used in one process.
+func NewAtRootConnection(address Address, verbose bool, options ...ConnectionOption) *AtRootConnection {
+	return &AtRootConnection{
+		AtConnection: NewAtConnection(address.host, address.port, context.Background(), verbose, options...),
+	}
+}
+
+// DefaultRootAddress returns the address of the public atSign root server.
+func DefaultRootAddress() *Address {
+	return NewAddress(DefaultRootHost, DefaultRootPort)
 }
 
 func (arc *AtRootConnection) ParseRawResponse(rawResponse string) *Response {
@@ -32,22 +37,36 @@ func (arc *AtRootConnection) ParseRawResponse(rawResponse string) *Response {
 	return NewResponse().SetRawDataResponse(strings.TrimSpace(rawResponse))
 }
 
+// FindSecondary asks the root server for the address of atSign's secondary.
 func (arc *AtRootConnection) FindSecondary(atSign common.AtSign) (*Address, *exceptions.AtException) {
-	if !arc.AtConnection.connected {
-		err := arc.AtConnection.Connect()
+	address, err := arc.FindSecondaryContext(arc.AtConnection.ctx, atSign)
+	if err != nil {
+		return nil, exceptions.NewAtException(err.Error())
+	}
+	return address, nil
+}
+
+// FindSecondaryContext is FindSecondary with the deadlines taken from ctx. It returns an
+// AtSecondaryNotFoundException if the root server has no secondary for atSign.
+func (arc *AtRootConnection) FindSecondaryContext(ctx context.Context, atSign common.AtSign) (*Address, error) {
+	if !arc.AtConnection.IsConnected() {
+		err := arc.AtConnection.ConnectContext(ctx)
 		if err != nil {
 			return nil, exceptions.NewAtException("Root Connection failed - " + err.Error())
 		}
 	}
-	response, err := arc.AtConnection.ExecuteCommand(atSign.WithoutPrefix, true)
+	response, err := arc.AtConnection.ExecuteCommandContext(ctx, atSign.WithoutPrefix, true)
 	if err != nil || response.rawDataResponse == "" {
 		return nil, exceptions.NewAtException("Root lookup returned null for " + atSign.AtSignStr)
 	}
-	address, err := AddressFromString(response.rawDataResponse)
-	if address != nil {
-		return NewAddress(address.host, address.port), nil
-	} else if err != nil {
+
+	rawResponse := arc.ParseRawResponse(response.rawDataResponse).rawDataResponse
+	if rawResponse == "null" || strings.HasPrefix(rawResponse, "error:AT0007") {
+		return nil, exceptions.NewAtSecondaryNotFoundException("No secondary found for " + atSign.AtSignStr)
+	}
+	address, err := AddressFromString(rawResponse)
+	if err != nil {
 		return nil, exceptions.NewAtException("Root lookup returned error for " + atSign.AtSignStr + ": " + err.Error())
 	}
-	return nil, nil
+	return address, nil
 }
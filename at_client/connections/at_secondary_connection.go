@@ -3,42 +3,217 @@ package connections
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/atsign-foundation/at_go/at_client/exceptions"
+)
+
+const (
+	DefaultMaxReconnectAttempts = 5
+	DefaultInitialBackoff       = 250 * time.Millisecond
+	DefaultMaxBackoff           = 10 * time.Second
 )
 
+// idempotentVerbs are the verbs that are safe to send again after the connection was lost
+// while waiting for their response.
+var idempotentVerbs = map[string]bool{
+	"llookup": true,
+	"lookup":  true,
+	"plookup": true,
+	"scan":    true,
+	"noop":    true,
+	"info":    true,
+	"stats":   true,
+}
+
+// ReconnectEvent describes one attempt by an AtSecondaryConnection to re-establish a lost
+// connection. Err is nil if the attempt succeeded.
+type ReconnectEvent struct {
+	Address Address
+	Attempt int
+	Err     error
+}
+
 type AtSecondaryConnection struct {
 	AtConnection *AtConnection
+	Address      Address
+
+	// Authenticator, when set, is run on every new connection before it is used again,
+	// e.g. to re-run PKAM authentication.
+	Authenticator func(conn *AtConnection) error
+	// OnReconnect, when set, is called after every reconnect attempt.
+	OnReconnect func(event ReconnectEvent)
+
+	MaxReconnectAttempts int
+	InitialBackoff       time.Duration
+	MaxBackoff           time.Duration
+
+	// mu serialises commands together with any reconnect they trigger, so that concurrent
+	// callers neither reconnect twice nor send commands on a connection being authenticated.
+	mu sync.Mutex
 }
 
-func NewAtSecondaryConnection(address Address, verbose bool) *AtSecondaryConnection {
+// NewAtSecondaryConnection returns a connection to the secondary at address and tries to
+// connect straight away. A failure to connect is not returned: the first command reconnects,
+// with backoff, and returns the error if that fails too. Call AtConnection.Connect to find out
+// at once whether the secondary is reachable.
+func NewAtSecondaryConnection(address Address, verbose bool, options ...ConnectionOption) *AtSecondaryConnection {
 	var atSecondaryConnection = &AtSecondaryConnection{
-		AtConnection: NewAtConnection(address.host, address.port, context.Background(), verbose),
+		AtConnection:         NewAtConnection(address.host, address.port, context.Background(), verbose, options...),
+		Address:              address,
+		MaxReconnectAttempts: DefaultMaxReconnectAttempts,
+		InitialBackoff:       DefaultInitialBackoff,
+		MaxBackoff:           DefaultMaxBackoff,
 	}
 	atSecondaryConnection.AtConnection.Connect()
 	return atSecondaryConnection
 }
 
-func ParseRawResponse(rawResponse string) (*Response, error) {
-	if strings.HasSuffix(rawResponse, "@") {
-		rawResponse = rawResponse[:len(rawResponse)-1]
+func (sc *AtSecondaryConnection) ExecuteCommand(command string, readTheResponse bool) (*Response, error) {
+	return sc.ExecuteCommandContext(sc.AtConnection.ctx, command, readTheResponse)
+}
+
+// ExecuteCommandContext executes command, first reconnecting (and re-authenticating) if the
+// connection has been lost. If the connection breaks while the command is in flight it is
+// re-established, and the command is sent again if its verb is idempotent. It is safe to call
+// from several goroutines at once.
+func (sc *AtSecondaryConnection) ExecuteCommandContext(ctx context.Context, command string, readTheResponse bool) (*Response, error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if !sc.AtConnection.IsConnected() {
+		if err := sc.reconnect(ctx); err != nil {
+			return NewResponse(), err
+		}
+	}
+
+	response, err := sc.AtConnection.ExecuteCommandContext(ctx, command, readTheResponse)
+	if err == nil || sc.AtConnection.IsConnected() || ctx.Err() != nil {
+		return response, err
+	}
+
+	if reconnectErr := sc.reconnect(ctx); reconnectErr != nil {
+		return response, reconnectErr
+	}
+	if !isIdempotent(command) {
+		return response, err
+	}
+	return sc.AtConnection.ExecuteCommandContext(ctx, command, readTheResponse)
+}
+
+// ExecuteCommandsContext pipelines commands like AtConnection.ExecuteCommandsContext, first
+// reconnecting if the connection has been lost. If the connection breaks part way through,
+// it is re-established and the remaining commands are sent again if they are all idempotent.
+func (sc *AtSecondaryConnection) ExecuteCommandsContext(ctx context.Context, commands []string, window int) ([]*Response, error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if !sc.AtConnection.IsConnected() {
+		if err := sc.reconnect(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	responses, err := sc.AtConnection.ExecuteCommandsContext(ctx, commands, window)
+	if err == nil || sc.AtConnection.IsConnected() || ctx.Err() != nil {
+		return responses, err
+	}
+
+	if reconnectErr := sc.reconnect(ctx); reconnectErr != nil {
+		return responses, reconnectErr
+	}
+	remaining := commands[len(responses):]
+	for _, command := range remaining {
+		if !isIdempotent(command) {
+			return responses, err
+		}
+	}
+	rest, err := sc.AtConnection.ExecuteCommandsContext(ctx, remaining, window)
+	return append(responses, rest...), err
+}
+
+// Reconnect closes the current connection and dials the atServer again, backing off
+// exponentially between failed attempts.
+func (sc *AtSecondaryConnection) Reconnect(ctx context.Context) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.reconnect(ctx)
+}
+
+func (sc *AtSecondaryConnection) reconnect(ctx context.Context) error {
+	maxAttempts := sc.MaxReconnectAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxReconnectAttempts
 	}
-	rawResponse = strings.TrimSpace(rawResponse)
+	backoff := sc.InitialBackoff
+	if backoff <= 0 {
+		backoff = DefaultInitialBackoff
+	}
+	maxBackoff := sc.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		sc.AtConnection.Disconnect()
+		err = sc.AtConnection.ConnectContext(ctx)
+		if err == nil && sc.Authenticator != nil {
+			if err = sc.Authenticator(sc.AtConnection); err != nil {
+				sc.AtConnection.Disconnect()
+			}
+		}
+		if sc.OnReconnect != nil {
+			sc.OnReconnect(ReconnectEvent{Address: sc.Address, Attempt: attempt, Err: err})
+		}
+		if err == nil {
+			return nil
+		}
+		if attempt == maxAttempts {
+			break
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return contextError(ctx, "reconnect to "+sc.Address.String(), ctx.Err())
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+	return exceptions.NewAtSecondaryConnectException(fmt.Sprintf("Failed to reconnect to %s after %d attempts - %s", sc.Address.String(), maxAttempts, err))
+}
 
-	dataIndex := strings.Index(rawResponse, "data:")
-	errorIndex := strings.Index(rawResponse, "error:")
-	notificationIndex := strings.Index(rawResponse, "notification")
+func isIdempotent(command string) bool {
+	verb := strings.TrimSpace(command)
+	if end := strings.IndexAny(verb, ": "); end > -1 {
+		verb = verb[:end]
+	}
+	if verb == "notify" {
+		return strings.HasPrefix(strings.TrimSpace(command), "notify:status:")
+	}
+	return idempotentVerbs[verb]
+}
+
+// ParseRawResponse parses a response line read from an atServer. Any prompts before the
+// response and anything after the first line are ignored.
+func ParseRawResponse(rawResponse string) (*Response, error) {
+	rawResponse = stripPrompts(strings.TrimSpace(rawResponse))
+	line := strings.TrimRight(strings.Split(rawResponse, "\n")[0], "\r")
 
 	response := NewResponse()
 
-	if dataIndex > -1 {
-		data := strings.Split(rawResponse[dataIndex+len("data:"):], "\n")[0]
-		response.SetRawDataResponse(data)
-	} else if errorIndex > -1 {
-		errorMsg := rawResponse[errorIndex+len("error:"):]
-		response.SetRawErrorResponse(errorMsg)
-	} else if notificationIndex > -1 {
-		notification := rawResponse[notificationIndex+len("notification:"):]
-		response.SetRawDataResponse(notification)
+	if strings.HasPrefix(line, "data:") {
+		response.SetRawDataResponse(line[len("data:"):])
+	} else if strings.HasPrefix(line, "error:") {
+		response.SetRawErrorResponse(line[len("error:"):])
+	} else if strings.HasPrefix(line, "notification:") {
+		response.SetRawDataResponse(strings.TrimSpace(line[len("notification:"):]))
 	} else {
 		return nil, errors.New("Invalid response from server: " + rawResponse)
 	}
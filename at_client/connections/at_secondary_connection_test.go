@@ -0,0 +1,322 @@
+package connections_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/atsign-foundation/at_go/at_client/attest"
+	"github.com/atsign-foundation/at_go/at_client/common"
+	"github.com/atsign-foundation/at_go/at_client/connections"
+	"github.com/atsign-foundation/at_go/at_client/exceptions"
+	"github.com/atsign-foundation/at_go/at_client/utils/auth_util"
+)
+
+// newSecondaryConnection returns a PKAM-authenticated connection to the atServer of @alice,
+// which re-authenticates when it reconnects, and the server, both closed when the test ends.
+func newSecondaryConnection(t *testing.T) (*connections.AtSecondaryConnection, *attest.AtServer) {
+	t.Helper()
+	env, err := attest.NewEnvironment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { env.Close() })
+	alice := common.NewAtSign("@alice")
+	server, err := env.AddAtSign(*alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := env.Keys(*alice)
+	conn := connections.NewAtSecondaryConnection(server.Address(), false, connections.WithTLSConfig(env.ClientTLSConfig()))
+	conn.Authenticator = func(atconn *connections.AtConnection) error {
+		return auth_util.AuthenticateWithPkam(atconn, *alice, keys)
+	}
+	conn.InitialBackoff = time.Millisecond
+	if err := conn.Authenticator(conn.AtConnection); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(conn.AtConnection.Disconnect)
+	return conn, server
+}
+
+// dropConnectionsOn drops the server's connections, without answering, the first time it
+// receives a command starting with prefix. It returns a function counting how many times the
+// server has received a command.
+func dropConnectionsOn(t *testing.T, server *attest.AtServer, prefix string) func(command string) int {
+	t.Helper()
+	var mu sync.Mutex
+	received := map[string]int{}
+	dropped := false
+	server.InterceptCommands(func(command string) (string, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		received[command]++
+		if !dropped && strings.HasPrefix(command, prefix) {
+			dropped = true
+			server.DropConnections()
+			return "", true
+		}
+		return "", false
+	})
+	t.Cleanup(func() { server.InterceptCommands(nil) })
+	return func(command string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return received[command]
+	}
+}
+
+func FuzzParseRawResponse(f *testing.F) {
+	f.Add("data:ok")
+	f.Add("@data:ok\n")
+	f.Add("@alice@data:{\"key\":\"value\"}\r\n@alice@")
+	f.Add("@alice@error:AT0015-key not found : public:missing@alice")
+	f.Add("error:AT0025-Apkam authentication failed : enrollment 1234 is denied\n@")
+	f.Add("@alice@notification: {\"id\":\"1\",\"key\":\"@bob:message@alice\"}\n@alice@data:ok\n")
+	f.Add("@@alice@@")
+	f.Add("host:64")
+
+	f.Fuzz(func(t *testing.T, raw string) {
+		response, err := connections.ParseRawResponse(raw)
+		if err != nil {
+			if response != nil {
+				t.Fatalf("ParseRawResponse(%q) returned a response with error %v", raw, err)
+			}
+			return
+		}
+		if response.IsError() {
+			if response.GetRawDataResponse() != "" {
+				t.Fatalf("ParseRawResponse(%q) is both data and error", raw)
+			}
+			response.GetException()
+		}
+		for _, part := range []string{response.GetRawDataResponse(), response.GetRawErrorResponse()} {
+			if strings.ContainsAny(part, "\n") {
+				t.Fatalf("ParseRawResponse(%q) kept more than one line: %q", raw, part)
+			}
+		}
+
+		// Prompts before a response and whatever the server sends after it do not matter
+		trimmed := strings.TrimSpace(raw)
+		for _, variant := range []string{"@alice@" + trimmed, trimmed + "\n@alice@", trimmed + "\n@alice@data:next\n"} {
+			got, err := connections.ParseRawResponse(variant)
+			if err != nil {
+				t.Fatalf("ParseRawResponse(%q) failed with %v but ParseRawResponse(%q) did not", variant, err, raw)
+			}
+			if got.GetRawDataResponse() != response.GetRawDataResponse() || got.GetRawErrorResponse() != response.GetRawErrorResponse() {
+				t.Fatalf("ParseRawResponse(%q) = %+v, want %+v as for %q", variant, got, response, raw)
+			}
+		}
+	})
+}
+
+func TestExecuteCommandsContextResendsIdempotentCommandsAfterDrop(t *testing.T) {
+	conn, server := newSecondaryConnection(t)
+	commands := []string{}
+	for i := 0; i < 6; i++ {
+		key := fmt.Sprintf("key%d@alice", i)
+		server.Put(key, fmt.Sprintf("value %d", i), common.Metadata{})
+		commands = append(commands, "llookup:"+key)
+	}
+	received := dropConnectionsOn(t, server, commands[3])
+
+	responses, err := conn.ExecuteCommandsContext(context.Background(), commands, 2)
+	if err != nil {
+		t.Fatalf("ExecuteCommandsContext: %v", err)
+	}
+	if len(responses) != len(commands) {
+		t.Fatalf("%d responses to %d commands", len(responses), len(commands))
+	}
+	for i, response := range responses {
+		if got, want := response.GetRawDataResponse(), fmt.Sprintf("data:value %d", i); !strings.HasPrefix(got, want) {
+			t.Errorf("response to %s = %q, want %q", commands[i], got, want)
+		}
+	}
+	if received(commands[0]) != 1 || received(commands[3]) != 2 {
+		t.Errorf("the server received %s %d times and %s %d times, want only the dropped command resent",
+			commands[0], received(commands[0]), commands[3], received(commands[3]))
+	}
+}
+
+func TestExecuteCommandsContextDoesNotResendUpdatesAfterDrop(t *testing.T) {
+	conn, server := newSecondaryConnection(t)
+	server.Put("key0@alice", "value 0", common.Metadata{})
+	commands := []string{"llookup:key0@alice", "update:key1@alice value 1", "llookup:key0@alice"}
+	received := dropConnectionsOn(t, server, "update:")
+
+	responses, err := conn.ExecuteCommandsContext(context.Background(), commands, 1)
+	if err == nil {
+		t.Fatal("ExecuteCommandsContext succeeded although the connection dropped before an update was answered")
+	}
+	if len(responses) != 1 {
+		t.Errorf("%d responses, want the one read before the drop", len(responses))
+	}
+	if n := received(commands[1]); n != 1 {
+		t.Errorf("the update was sent %d times, want once", n)
+	}
+	if !conn.AtConnection.IsConnected() {
+		t.Error("the connection was not re-established")
+	}
+}
+
+// recordReconnects records the events conn reports, with the time of each.
+func recordReconnects(conn *connections.AtSecondaryConnection) func() ([]connections.ReconnectEvent, []time.Time) {
+	var mu sync.Mutex
+	var events []connections.ReconnectEvent
+	var times []time.Time
+	conn.OnReconnect = func(event connections.ReconnectEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+		times = append(times, time.Now())
+	}
+	return func() ([]connections.ReconnectEvent, []time.Time) {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]connections.ReconnectEvent(nil), events...), append([]time.Time(nil), times...)
+	}
+}
+
+func TestNewAtSecondaryConnectionReconnectsOnFirstCommand(t *testing.T) {
+	conn := connections.NewAtSecondaryConnection(unusedAddress(t), false)
+	if conn.AtConnection.IsConnected() {
+		t.Fatal("connected to an address nothing listens on")
+	}
+	conn.MaxReconnectAttempts = 2
+	conn.InitialBackoff = time.Millisecond
+	events := recordReconnects(conn)
+
+	_, err := conn.ExecuteCommand("noop:0", true)
+	var connectErr *exceptions.AtSecondaryConnectException
+	if !errors.As(err, &connectErr) {
+		t.Errorf("ExecuteCommand error = %v, want AtSecondaryConnectException", err)
+	}
+	if got, _ := events(); len(got) != 2 {
+		t.Errorf("%d reconnect attempts, want 2", len(got))
+	}
+}
+
+func TestReconnectBacksOff(t *testing.T) {
+	conn := connections.NewAtSecondaryConnection(unusedAddress(t), false)
+	conn.MaxReconnectAttempts = 5
+	conn.InitialBackoff = 20 * time.Millisecond
+	conn.MaxBackoff = 50 * time.Millisecond
+	events := recordReconnects(conn)
+
+	if err := conn.Reconnect(context.Background()); err == nil {
+		t.Fatal("Reconnect succeeded to an address nothing listens on")
+	}
+	got, times := events()
+	if len(got) != 5 {
+		t.Fatalf("%d reconnect attempts, want 5", len(got))
+	}
+	for i, event := range got {
+		if event.Attempt != i+1 || event.Err == nil || event.Address.String() != conn.Address.String() {
+			t.Errorf("event %d = %+v, want failed attempt %d", i, event, i+1)
+		}
+	}
+
+	// The backoff doubles from InitialBackoff up to MaxBackoff
+	for i, want := range []time.Duration{20, 40, 50, 50} {
+		if gap := times[i+1].Sub(times[i]); gap < want*time.Millisecond {
+			t.Errorf("attempt %d came %v after the one before, want at least %v", i+2, gap, want*time.Millisecond)
+		}
+	}
+}
+
+func TestReconnectStopsWhenContextIsDone(t *testing.T) {
+	conn := connections.NewAtSecondaryConnection(unusedAddress(t), false)
+	conn.InitialBackoff = time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	var timeout *exceptions.AtTimeoutException
+	if err := conn.Reconnect(ctx); !errors.As(err, &timeout) {
+		t.Errorf("Reconnect error = %v, want AtTimeoutException", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Reconnect took %v, want it to stop when ctx expired", elapsed)
+	}
+}
+
+func TestExecuteCommandReauthenticatesAfterDrop(t *testing.T) {
+	conn, server := newSecondaryConnection(t)
+	events := recordReconnects(conn)
+	server.DropConnections()
+
+	response, err := conn.ExecuteCommand("scan", true)
+	if err == nil {
+		response, err = connections.ParseRawResponse(response.GetRawDataResponse())
+	}
+	if err == nil && response.IsError() {
+		err = response.GetException()
+	}
+	if err != nil {
+		t.Fatalf("scan after the connection dropped: %v", err)
+	}
+	if got, _ := events(); len(got) != 1 || got[0].Err != nil {
+		t.Errorf("reconnect events %+v, want one successful attempt", got)
+	}
+}
+
+func TestReconnectFailsWhenAuthenticationFails(t *testing.T) {
+	conn, server := newSecondaryConnection(t)
+	conn.MaxReconnectAttempts = 2
+	authErr := errors.New("keys revoked")
+	conn.Authenticator = func(*connections.AtConnection) error { return authErr }
+	events := recordReconnects(conn)
+	server.DropConnections()
+
+	if _, err := conn.ExecuteCommand("scan", true); err == nil || !strings.Contains(err.Error(), authErr.Error()) {
+		t.Errorf("ExecuteCommand error = %v, want the authentication error", err)
+	}
+	got, _ := events()
+	if len(got) != 2 || !errors.Is(got[0].Err, authErr) {
+		t.Errorf("reconnect events %+v, want two failing with the authentication error", got)
+	}
+	if conn.AtConnection.IsConnected() {
+		t.Error("the unauthenticated connection was kept")
+	}
+}
+
+func TestExecuteCommandResendsOnlyIdempotentCommands(t *testing.T) {
+	tests := []struct {
+		name       string
+		command    string
+		wantResent bool
+	}{
+		{"llookup", "llookup:key0@alice", true},
+		{"notify:status", "notify:status:1234", true},
+		{"update", "update:key1@alice value 1", false},
+		{"delete", "delete:key0@alice", false},
+		{"notify", "notify:update:@bob:key0@alice:value", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, server := newSecondaryConnection(t)
+			server.Put("key0@alice", "value 0", common.Metadata{})
+			received := dropConnectionsOn(t, server, tt.command)
+
+			_, err := conn.ExecuteCommand(tt.command, true)
+			want := 1
+			if tt.wantResent {
+				want = 2
+			}
+			if n := received(tt.command); n != want {
+				t.Errorf("the server received the command %d times, want %d", n, want)
+			}
+			if (err == nil) != tt.wantResent {
+				t.Errorf("ExecuteCommand error = %v, want an error only if the command was not resent", err)
+			}
+			if !conn.AtConnection.IsConnected() {
+				t.Error("the connection was not re-established")
+			}
+		})
+	}
+}
@@ -0,0 +1,571 @@
+package connections
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startGreeter starts a TCP server that sends "hello\n" and then echoes each line it reads.
+// It returns the server's address.
+func startGreeter(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.Write([]byte("hello\n"))
+				lines := bufio.NewScanner(conn)
+				for lines.Scan() {
+					conn.Write([]byte(lines.Text() + "\n"))
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// fakeProxy is a SOCKS5 or HTTP CONNECT proxy that tunnels every connection to backend,
+// whatever address it is asked for, and records the addresses it is asked for.
+type fakeProxy struct {
+	backend string
+	// username and password, if set, are required from clients.
+	username string
+	password string
+	// status, if not zero, is the SOCKS5 reply status or HTTP status code sent instead of
+	// success.
+	status int
+	// prefetch sends the backend's first line in the same write as the CONNECT response.
+	prefetch bool
+	// certificate, if set, makes the proxy accept TLS connections.
+	certificate *tls.Certificate
+
+	mu      sync.Mutex
+	targets []string
+}
+
+func (p *fakeProxy) record(target string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.targets = append(p.targets, target)
+}
+
+func (p *fakeProxy) Targets() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.targets...)
+}
+
+// start serves each connection to the proxy with serve and returns the proxy's address.
+func (p *fakeProxy) start(t *testing.T, serve func(conn net.Conn)) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.certificate != nil {
+		listener = tls.NewListener(listener, &tls.Config{Certificates: []tls.Certificate{*p.certificate}})
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func startSOCKS5Proxy(t *testing.T, p *fakeProxy) string {
+	return p.start(t, p.serveSOCKS5)
+}
+
+func startHTTPProxy(t *testing.T, p *fakeProxy) string {
+	return p.start(t, p.serveHTTP)
+}
+
+func (p *fakeProxy) serveSOCKS5(conn net.Conn) {
+	defer conn.Close()
+	greeting := make([]byte, 2)
+	if _, err := io.ReadFull(conn, greeting); err != nil {
+		return
+	}
+	methods := make([]byte, greeting[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return
+	}
+	method := byte(socks5NoAuth)
+	if p.username != "" {
+		method = socks5UserPassAuth
+	}
+	if bytes.IndexByte(methods, method) < 0 {
+		conn.Write([]byte{socks5Version, socks5NoAcceptable})
+		return
+	}
+	conn.Write([]byte{socks5Version, method})
+
+	if method == socks5UserPassAuth {
+		username, password, err := readSOCKS5Credentials(conn)
+		if err != nil {
+			return
+		}
+		if username != p.username || password != p.password {
+			conn.Write([]byte{socks5UserPassVersion, 0x01})
+			return
+		}
+		conn.Write([]byte{socks5UserPassVersion, 0x00})
+	}
+
+	// The request is the version, command, a reserved byte, then the address and port
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil || header[1] != socks5Connect {
+		return
+	}
+	var address []byte
+	switch header[3] {
+	case socks5AddressIPv4:
+		address = make([]byte, net.IPv4len)
+	case socks5AddressIPv6:
+		address = make([]byte, net.IPv6len)
+	case socks5AddressDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return
+		}
+		address = make([]byte, length[0])
+	default:
+		return
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, address); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return
+	}
+	host := string(address)
+	if header[3] != socks5AddressDomain {
+		host = net.IP(address).String()
+	}
+	p.record(net.JoinHostPort(host, strconv.Itoa(int(port[0])<<8|int(port[1]))))
+
+	status := byte(p.status)
+	backend, err := net.Dial("tcp", p.backend)
+	if err != nil && status == 0 {
+		status = 0x01
+	}
+	// The reply binds the address asked for, so that each address type is read back
+	reply := []byte{socks5Version, status, 0x00, header[3]}
+	if header[3] == socks5AddressDomain {
+		reply = append(reply, byte(len(address)))
+	}
+	reply = append(append(reply, address...), port...)
+	conn.Write(reply)
+	if status != 0 {
+		if backend != nil {
+			backend.Close()
+		}
+		return
+	}
+	relay(conn, backend, backend)
+}
+
+func readSOCKS5Credentials(conn net.Conn) (string, string, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", "", err
+	}
+	username := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, username); err != nil {
+		return "", "", err
+	}
+	length := make([]byte, 1)
+	if _, err := io.ReadFull(conn, length); err != nil {
+		return "", "", err
+	}
+	password := make([]byte, length[0])
+	if _, err := io.ReadFull(conn, password); err != nil {
+		return "", "", err
+	}
+	return string(username), string(password), nil
+}
+
+func (p *fakeProxy) serveHTTP(conn net.Conn) {
+	defer conn.Close()
+	request, err := http.ReadRequest(bufio.NewReader(conn))
+	if err != nil || request.Method != http.MethodConnect {
+		return
+	}
+	p.record(request.Host)
+
+	status := http.StatusOK
+	if p.username != "" {
+		credentials := base64.StdEncoding.EncodeToString([]byte(p.username + ":" + p.password))
+		if request.Header.Get("Proxy-Authorization") != "Basic "+credentials {
+			status = http.StatusProxyAuthRequired
+		}
+	}
+	if p.status != 0 {
+		status = p.status
+	}
+	if status != http.StatusOK {
+		fmt.Fprintf(conn, "HTTP/1.1 %d %s\r\nContent-Length: 0\r\n\r\n", status, http.StatusText(status))
+		return
+	}
+
+	backend, err := net.Dial("tcp", p.backend)
+	if err != nil {
+		fmt.Fprintf(conn, "HTTP/1.1 502 Bad Gateway\r\nContent-Length: 0\r\n\r\n")
+		return
+	}
+	fromBackend := bufio.NewReader(backend)
+	response := "HTTP/1.1 200 Connection established\r\n\r\n"
+	if p.prefetch {
+		line, err := fromBackend.ReadString('\n')
+		if err != nil {
+			backend.Close()
+			return
+		}
+		response += line
+	}
+	conn.Write([]byte(response))
+	relay(conn, backend, fromBackend)
+}
+
+// relay copies between a client and backend until either closes.
+func relay(client net.Conn, backend net.Conn, fromBackend io.Reader) {
+	go func() {
+		io.Copy(backend, client)
+		backend.Close()
+	}()
+	io.Copy(client, fromBackend)
+	client.Close()
+}
+
+// checkTunnel checks that conn reaches a greeter.
+func checkTunnel(t *testing.T, conn net.Conn) {
+	t.Helper()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	lines := bufio.NewReader(conn)
+	if line, err := lines.ReadString('\n'); err != nil || line != "hello\n" {
+		t.Fatalf("greeting = %q, %v", line, err)
+	}
+	if _, err := conn.Write([]byte("ping\n")); err != nil {
+		t.Fatal(err)
+	}
+	if line, err := lines.ReadString('\n'); err != nil || line != "ping\n" {
+		t.Fatalf("echo = %q, %v", line, err)
+	}
+}
+
+func mustParseURL(t *testing.T, rawURL string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestSOCKS5Dialer(t *testing.T) {
+	proxy := &fakeProxy{backend: startGreeter(t)}
+	proxyAddress := startSOCKS5Proxy(t, proxy)
+	dialer := NewSOCKS5Dialer(mustParseURL(t, "socks5://"+proxyAddress), nil)
+
+	for _, address := range []string{"atserver.test:6464", "192.0.2.1:64", "[2001:db8::1]:443"} {
+		t.Run(address, func(t *testing.T) {
+			conn, err := dialer.DialContext(context.Background(), "tcp", address)
+			if err != nil {
+				t.Fatalf("DialContext: %v", err)
+			}
+			defer conn.Close()
+			checkTunnel(t, conn)
+
+			targets := proxy.Targets()
+			if targets[len(targets)-1] != address {
+				t.Errorf("proxy was asked for %s, want %s", targets[len(targets)-1], address)
+			}
+		})
+	}
+}
+
+func TestSOCKS5DialerAuthentication(t *testing.T) {
+	proxy := &fakeProxy{backend: startGreeter(t), username: "alice", password: "secret"}
+	proxyAddress := startSOCKS5Proxy(t, proxy)
+
+	tests := []struct {
+		name     string
+		userinfo string
+		wantErr  string
+	}{
+		{"valid credentials", "alice:secret@", ""},
+		{"wrong password", "alice:wrong@", "authentication failed"},
+		{"no credentials", "", "no acceptable authentication method"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dialer := NewSOCKS5Dialer(mustParseURL(t, "socks5://"+tt.userinfo+proxyAddress), nil)
+			conn, err := dialer.DialContext(context.Background(), "tcp", "atserver.test:6464")
+			if tt.wantErr != "" {
+				if err == nil {
+					conn.Close()
+					t.Fatal("DialContext succeeded")
+				}
+				if !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("DialContext error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DialContext: %v", err)
+			}
+			defer conn.Close()
+			checkTunnel(t, conn)
+		})
+	}
+}
+
+func TestSOCKS5DialerReplyStatus(t *testing.T) {
+	proxy := &fakeProxy{backend: startGreeter(t), status: 0x05}
+	dialer := NewSOCKS5Dialer(mustParseURL(t, "socks5://"+startSOCKS5Proxy(t, proxy)), nil)
+
+	_, err := dialer.DialContext(context.Background(), "tcp", "atserver.test:6464")
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("DialContext error = %v, want connection refused", err)
+	}
+}
+
+func TestHTTPConnectDialer(t *testing.T) {
+	tests := []struct {
+		name     string
+		proxy    *fakeProxy
+		userinfo string
+		wantErr  string
+	}{
+		{"no authentication", &fakeProxy{}, "", ""},
+		{"valid credentials", &fakeProxy{username: "alice", password: "secret"}, "alice:secret@", ""},
+		{"wrong password", &fakeProxy{username: "alice", password: "secret"}, "alice:wrong@", "407 Proxy Authentication Required"},
+		{"forbidden", &fakeProxy{status: http.StatusForbidden}, "", "403 Forbidden"},
+		{"bad gateway", &fakeProxy{status: http.StatusBadGateway}, "", "502 Bad Gateway"},
+	}
+	backend := startGreeter(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := tt.proxy
+			proxy.backend = backend
+			dialer := NewHTTPConnectDialer(mustParseURL(t, "http://"+tt.userinfo+startHTTPProxy(t, proxy)), nil)
+
+			conn, err := dialer.DialContext(context.Background(), "tcp", "atserver.test:6464")
+			if tt.wantErr != "" {
+				if err == nil {
+					conn.Close()
+					t.Fatal("DialContext succeeded")
+				}
+				if !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("DialContext error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DialContext: %v", err)
+			}
+			defer conn.Close()
+			checkTunnel(t, conn)
+			if targets := proxy.Targets(); len(targets) != 1 || targets[0] != "atserver.test:6464" {
+				t.Errorf("proxy was asked for %v", targets)
+			}
+		})
+	}
+}
+
+func TestHTTPConnectDialerKeepsBytesSentAfterResponse(t *testing.T) {
+	proxy := &fakeProxy{backend: startGreeter(t), prefetch: true}
+	dialer := NewHTTPConnectDialer(mustParseURL(t, "http://"+startHTTPProxy(t, proxy)), nil)
+
+	conn, err := dialer.DialContext(context.Background(), "tcp", "atserver.test:6464")
+	if err != nil {
+		t.Fatalf("DialContext: %v", err)
+	}
+	defer conn.Close()
+	if _, ok := conn.(*bufferedConn); !ok {
+		t.Errorf("DialContext returned a %T, want *bufferedConn", conn)
+	}
+	checkTunnel(t, conn)
+}
+
+func TestHTTPSProxyTrustsConnectionRootCAs(t *testing.T) {
+	server := startServer(t)
+	proxyCertificate := newCertificate(t)
+	proxy := &fakeProxy{backend: server.String(), certificate: &proxyCertificate}
+	t.Setenv("HTTPS_PROXY", "https://"+startHTTPProxy(t, proxy))
+	t.Setenv("NO_PROXY", "")
+
+	// The proxy and the server have different CAs; this connection trusts only the server's
+	atconn := NewAtConnection("atserver.test", server.port, context.Background(), false, WithRootCAs(server.config.RootCAs))
+	err := atconn.Connect()
+	var unknownAuthority x509.UnknownAuthorityError
+	if !errors.As(err, &unknownAuthority) {
+		atconn.Disconnect()
+		t.Fatalf("Connect through an untrusted proxy: error = %v, want x509.UnknownAuthorityError", err)
+	}
+
+	trusted := server.config.RootCAs.Clone()
+	trusted.AddCert(proxyCertificate.Leaf)
+	atconn = NewAtConnection("atserver.test", server.port, context.Background(), false, WithRootCAs(trusted))
+	if err := atconn.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer atconn.Disconnect()
+	if targets := proxy.Targets(); targets[len(targets)-1] != atconn.String() {
+		t.Errorf("proxy was asked for %v, want %s", targets, atconn.String())
+	}
+}
+
+func TestDefaultDialerReadsEnvironmentOnEachDial(t *testing.T) {
+	backend := startGreeter(t)
+	first, second := &fakeProxy{backend: backend}, &fakeProxy{backend: backend}
+	firstAddress, secondAddress := startHTTPProxy(t, first), startSOCKS5Proxy(t, second)
+	t.Setenv("NO_PROXY", "")
+	t.Setenv("ALL_PROXY", "")
+
+	dialer := DefaultDialer()
+	for _, proxy := range []string{"http://" + firstAddress, "socks5://" + secondAddress} {
+		t.Setenv("HTTPS_PROXY", proxy)
+		conn, err := dialer.DialContext(context.Background(), "tcp", "atserver.test:6464")
+		if err != nil {
+			t.Fatalf("DialContext through %s: %v", proxy, err)
+		}
+		checkTunnel(t, conn)
+		conn.Close()
+	}
+	if len(first.Targets()) != 1 || len(second.Targets()) != 1 {
+		t.Errorf("first proxy was asked for %v, second for %v; want one address each", first.Targets(), second.Targets())
+	}
+}
+
+func TestProxyFromEnvironment(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"unset", nil, ""},
+		{"HTTPS_PROXY", map[string]string{"HTTPS_PROXY": "http://proxy:3128"}, "http://proxy:3128"},
+		{"https_proxy", map[string]string{"https_proxy": "https://proxy"}, "https://proxy"},
+		{"ALL_PROXY", map[string]string{"ALL_PROXY": "socks5://proxy:1080"}, "socks5://proxy:1080"},
+		{"all_proxy", map[string]string{"all_proxy": "socks5h://proxy"}, "socks5h://proxy"},
+		{"HTTPS_PROXY before ALL_PROXY", map[string]string{"HTTPS_PROXY": "http://first", "ALL_PROXY": "socks5://second"}, "http://first"},
+		{"no scheme", map[string]string{"HTTPS_PROXY": "proxy:3128"}, "http://proxy:3128"},
+		{"NO_PROXY", map[string]string{"HTTPS_PROXY": "http://proxy", "NO_PROXY": "atserver.test"}, ""},
+		{"no_proxy", map[string]string{"ALL_PROXY": "http://proxy", "no_proxy": ".test"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"HTTPS_PROXY", "https_proxy", "ALL_PROXY", "all_proxy", "NO_PROXY", "no_proxy"} {
+				t.Setenv(name, tt.env[name])
+			}
+			proxyURL, err := proxyFromEnvironment("atserver.test:6464")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := ""
+			if proxyURL != nil {
+				got = proxyURL.String()
+			}
+			if got != tt.want {
+				t.Errorf("proxyFromEnvironment = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUseProxy(t *testing.T) {
+	tests := []struct {
+		host    string
+		noProxy string
+		want    bool
+	}{
+		{"atserver.test", "", true},
+		{"localhost", "", false},
+		{"127.0.0.1", "", false},
+		{"::1", "", false},
+		{"atserver.test", "*", false},
+		{"atserver.test", "atserver.test", false},
+		{"ATSERVER.TEST", "atserver.test", false},
+		{"atserver.test", "ATSERVER.test", false},
+		{"root.atserver.test", "atserver.test", false},
+		{"root.atserver.test", ".atserver.test", false},
+		{"root.atserver.test", "*.atserver.test", false},
+		{"notatserver.test", "atserver.test", true},
+		{"atserver.test", "other.test", true},
+		{"atserver.test", "other.test, atserver.test", false},
+		{"atserver.test", "atserver.test:6464", false},
+		{"atserver.test", " , ,", true},
+		{"192.0.2.1", "192.0.2.1", false},
+		{"192.0.2.10", "192.0.2.1", true},
+	}
+	for _, tt := range tests {
+		if got := useProxy(tt.host, tt.noProxy); got != tt.want {
+			t.Errorf("useProxy(%q, %q) = %v, want %v", tt.host, tt.noProxy, got, tt.want)
+		}
+	}
+}
+
+func TestProxyDialerSchemes(t *testing.T) {
+	tests := []struct {
+		proxy string
+		want  string
+	}{
+		{"socks5://proxy", "*connections.SOCKS5Dialer proxy:1080"},
+		{"SOCKS5H://proxy:9050", "*connections.SOCKS5Dialer proxy:9050"},
+		{"http://proxy", "*connections.HTTPConnectDialer proxy:80"},
+		{"https://proxy", "*connections.HTTPConnectDialer proxy:443"},
+	}
+	for _, tt := range tests {
+		dialer, err := ProxyDialer(mustParseURL(t, tt.proxy), nil)
+		if err != nil {
+			t.Errorf("ProxyDialer(%s): %v", tt.proxy, err)
+			continue
+		}
+		var proxyAddress string
+		switch d := dialer.(type) {
+		case *SOCKS5Dialer:
+			proxyAddress = d.ProxyAddress
+		case *HTTPConnectDialer:
+			proxyAddress = d.ProxyAddress
+		}
+		if got := fmt.Sprintf("%T %s", dialer, proxyAddress); got != tt.want {
+			t.Errorf("ProxyDialer(%s) = %s, want %s", tt.proxy, got, tt.want)
+		}
+	}
+
+	if _, err := ProxyDialer(mustParseURL(t, "ftp://proxy"), nil); err == nil {
+		t.Error("ProxyDialer accepted an ftp:// proxy")
+	}
+}
@@ -0,0 +1,92 @@
+package connections
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/atsign-foundation/at_go/at_client/exceptions"
+)
+
+// FrameReader splits the stream sent by an atServer or root server into responses.
+//
+// Every response is a single line ("data:...", "error:...", "notification: ..." or, from a
+// root server, "host:port") terminated by "\n" or "\r\n". Servers also send prompts that are
+// not newline terminated: "@" before authentication and "@alice@" after it. A prompt is
+// written after each response, so it arrives at the start of the following line, where
+// ReadFrame strips it.
+type FrameReader struct {
+	reader *bufio.Reader
+
+	// MaxFrameSize limits the length of a single response. Zero means no limit.
+	MaxFrameSize int
+}
+
+func NewFrameReader(r io.Reader) *FrameReader {
+	return &FrameReader{
+		reader: bufio.NewReader(r),
+	}
+}
+
+// ReadPrompt reads the prompt a server sends when a connection is opened.
+func (fr *FrameReader) ReadPrompt() error {
+	b, err := fr.reader.ReadByte()
+	if err != nil {
+		return err
+	}
+	if b != '@' {
+		fr.reader.UnreadByte()
+		return exceptions.NewAtResponseHandlingException(fmt.Sprintf("Expected prompt '@' but received %q", b))
+	}
+	return nil
+}
+
+// ReadFrame returns the next non-empty response with leading prompts and the line terminator
+// removed. A response longer than MaxFrameSize causes an AtBufferOverFlowException.
+func (fr *FrameReader) ReadFrame() (string, error) {
+	for {
+		line, err := fr.readLine()
+		if err != nil {
+			return "", err
+		}
+		frame := stripPrompts(line)
+		if frame != "" {
+			return frame, nil
+		}
+	}
+}
+
+func (fr *FrameReader) readLine() (string, error) {
+	var line bytes.Buffer
+	for {
+		fragment, err := fr.reader.ReadSlice('\n')
+		line.Write(fragment)
+		if fr.MaxFrameSize > 0 && line.Len() > fr.MaxFrameSize {
+			return "", exceptions.NewAtBufferOverFlowException(fmt.Sprintf("Response exceeds the maximum size of %d bytes", fr.MaxFrameSize))
+		}
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return "", err
+		}
+	}
+	return strings.TrimRight(line.String(), "\r\n"), nil
+}
+
+// stripPrompts removes any "@" or "@atsign@" prompts from the start of line.
+func stripPrompts(line string) string {
+	for strings.HasPrefix(line, "@") {
+		rest := line[1:]
+		end := strings.IndexAny(rest, "@: \t")
+		if end > 0 && rest[end] == '@' {
+			line = rest[end+1:]
+		} else {
+			line = rest
+		}
+	}
+	return line
+}
@@ -0,0 +1,101 @@
+package connections_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/atsign-foundation/at_go/at_client/connections"
+	"github.com/atsign-foundation/at_go/at_client/exceptions"
+)
+
+// chunkReader returns at most size bytes per Read, like a server whose writes are split.
+type chunkReader struct {
+	data string
+	size int
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, io.EOF
+	}
+	n := copy(p[:min(len(p), r.size)], r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+// readFrames returns every frame in stream and the error that ended the stream.
+func readFrames(r io.Reader, maxFrameSize int) ([]string, error) {
+	reader := connections.NewFrameReader(r)
+	reader.MaxFrameSize = maxFrameSize
+	frames := []string{}
+	for {
+		frame, err := reader.ReadFrame()
+		if err != nil {
+			return frames, err
+		}
+		frames = append(frames, frame)
+	}
+}
+
+func FuzzFrameReader(f *testing.F) {
+	f.Add("data:ok\n@", uint8(1), uint16(0))
+	f.Add("@data:ok\n@alice@data:second\n@alice@", uint8(3), uint16(0))
+	f.Add("@alice@notification: {\"id\":\"1\"}\n@alice@data:ok\r\n@alice@", uint8(5), uint16(0))
+	f.Add("@alice@notification: {\"id\":\"1\"}\n@alice@notification: {\"id\":\"2\"}\n@alice@error:AT0015-key not found\n", uint8(7), uint16(0))
+	f.Add("@\n@alice@\n\r\ndata:after blank lines\n", uint8(2), uint16(0))
+	f.Add("data:"+strings.Repeat("x", 5000)+"\ndata:small\n", uint8(255), uint16(64))
+	f.Add("data:exactly\n", uint8(4), uint16(13))
+	f.Add("data:truncated", uint8(4), uint16(0))
+
+	f.Fuzz(func(t *testing.T, stream string, chunk uint8, maxFrameSize uint16) {
+		frames, err := readFrames(strings.NewReader(stream), int(maxFrameSize))
+		var overflow *exceptions.AtBufferOverFlowException
+		if !errors.Is(err, io.EOF) && !errors.As(err, &overflow) {
+			t.Fatalf("stream ended with %v", err)
+		}
+		for _, frame := range frames {
+			if frame == "" || strings.HasPrefix(frame, "@") || strings.ContainsAny(frame, "\n") {
+				t.Fatalf("frame %q still has a prompt or line terminator", frame)
+			}
+			if maxFrameSize > 0 && len(frame) > int(maxFrameSize) {
+				t.Fatalf("frame of %d bytes exceeds MaxFrameSize %d", len(frame), maxFrameSize)
+			}
+		}
+
+		// A response split over several reads, even inside a prompt, is framed the same
+		chunked, chunkedErr := readFrames(&chunkReader{data: stream, size: 1 + int(chunk)}, int(maxFrameSize))
+		if strings.Join(chunked, "\n") != strings.Join(frames, "\n") || len(chunked) != len(frames) {
+			t.Fatalf("read in chunks of %d got %q, want %q", 1+int(chunk), chunked, frames)
+		}
+		if errors.As(chunkedErr, &overflow) != errors.As(err, &overflow) {
+			t.Fatalf("read in chunks of %d ended with %v, want %v", 1+int(chunk), chunkedErr, err)
+		}
+
+		// Responses written as a server writes them, with a prompt after each and notifications
+		// in between, come back unchanged
+		responses := []string{}
+		var written strings.Builder
+		written.WriteString("@")
+		for i, line := range strings.Split(stream, "\n") {
+			line = strings.TrimLeft(strings.TrimRight(line, "\r"), "@")
+			if line == "" {
+				continue
+			}
+			response := "data:" + line
+			if i%3 == 1 {
+				response = "notification: " + line
+			}
+			responses = append(responses, response)
+			written.WriteString(response + "\n@alice@")
+		}
+		got, err := readFrames(&chunkReader{data: written.String(), size: 1 + int(chunk)}, 0)
+		if !errors.Is(err, io.EOF) {
+			t.Fatalf("server stream ended with %v", err)
+		}
+		if len(got) != len(responses) || strings.Join(got, "\n") != strings.Join(responses, "\n") {
+			t.Fatalf("got %q, want %q", got, responses)
+		}
+	})
+}
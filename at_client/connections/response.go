@@ -33,8 +33,11 @@ func (r *Response) SetRawErrorResponse(s string) *Response {
 	r.rawErrorResponse = s
 	r.rawDataResponse = ""
 
-	errorCodeSegment := strings.TrimSpace(s[:strings.Index(s, ":")])
-	separatedByHyphen := strings.Split(errorCodeSegment, "-")
+	errorCodeSegment := s
+	if colonIndex := strings.Index(s, ":"); colonIndex > -1 {
+		errorCodeSegment = s[:colonIndex]
+	}
+	separatedByHyphen := strings.Split(strings.TrimSpace(errorCodeSegment), "-")
 	r.errorCode = strings.TrimSpace(separatedByHyphen[0])
 
 	r.errorText = strings.TrimSpace(strings.Replace(s, errorCodeSegment+":", "", 1))
@@ -93,6 +96,14 @@ func (r *Response) GetException() error {
 		return exceptions.NewAtTimeoutException(r.errorText)
 	case "AT0024":
 		return exceptions.NewAtServerIsPausedException(r.errorText)
+	case "AT0025":
+		// e.g. "enrollment 1234 is denied", after "Apkam authentication failed :"
+		fields := strings.Fields(r.errorText)
+		enrollmentStatus := ""
+		if len(fields) >= 2 && fields[len(fields)-2] == "is" {
+			enrollmentStatus = fields[len(fields)-1]
+		}
+		return exceptions.NewAtApkamAuthenticationException(r.errorText, enrollmentStatus)
 	case "AT0401":
 		return exceptions.NewAtUnauthenticatedException(r.errorText)
 	default:
@@ -0,0 +1,212 @@
+package connections
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/atsign-foundation/at_go/at_client/common"
+	"github.com/atsign-foundation/at_go/at_client/exceptions"
+)
+
+const (
+	DefaultRootResolverTTL         = time.Hour
+	DefaultRootResolverNegativeTTL = 5 * time.Minute
+)
+
+// RootResolver is the SecondaryAddressFinder that looks up the addresses of atSigns' secondaries on a root server and caches
+// them, so that clients created one after another do not each ask the root server. atSigns
+// the root server has no secondary for are cached for NegativeTTL.
+//
+// Change the exported fields before the resolver is first used.
+type RootResolver struct {
+	RootAddress Address
+
+	// TTL is how long an address is cached for, and NegativeTTL how long an atSign is
+	// remembered as not found.
+	TTL         time.Duration
+	NegativeTTL time.Duration
+	// CacheFile, when set, is a JSON file the cache is loaded from and saved to, so that it
+	// outlives the process.
+	CacheFile string
+
+	verbose bool
+	options []ConnectionOption
+
+	mu      sync.Mutex
+	entries map[string]rootCacheEntry
+	loaded  bool
+}
+
+type rootCacheEntry struct {
+	Address  string    `json:"address,omitempty"`
+	NotFound bool      `json:"notFound,omitempty"`
+	Expires  time.Time `json:"expires"`
+}
+
+// NewRootResolver returns a resolver, with an empty cache, for the root server at rootAddress.
+// options are applied to the connections made to the root server.
+func NewRootResolver(rootAddress Address, verbose bool, options ...ConnectionOption) *RootResolver {
+	return &RootResolver{
+		RootAddress: rootAddress,
+		TTL:         DefaultRootResolverTTL,
+		NegativeTTL: DefaultRootResolverNegativeTTL,
+		verbose:     verbose,
+		options:     options,
+		entries:     map[string]rootCacheEntry{},
+	}
+}
+
+var (
+	sharedRootResolversMu sync.Mutex
+	sharedRootResolvers   = map[string]*RootResolver{}
+)
+
+// SharedRootResolver returns the process-wide resolver for the root server at rootAddress,
+// which connects with the default connection options. Clients that need other options, e.g.
+// a proxy or a private CA, should share a resolver created with NewRootResolver instead.
+func SharedRootResolver(rootAddress Address, verbose bool) *RootResolver {
+	sharedRootResolversMu.Lock()
+	defer sharedRootResolversMu.Unlock()
+	resolver, ok := sharedRootResolvers[rootAddress.String()]
+	if !ok {
+		resolver = NewRootResolver(rootAddress, verbose)
+		sharedRootResolvers[rootAddress.String()] = resolver
+	}
+	return resolver
+}
+
+// FindSecondary returns the address of atSign's secondary, asking the root server only if it
+// is not cached. It returns an AtSecondaryNotFoundException if the root server has no secondary for
+// atSign.
+func (r *RootResolver) FindSecondary(ctx context.Context, atSign common.AtSign) (*Address, error) {
+	if entry, ok := r.cached(atSign); ok {
+		if r.verbose {
+			fmt.Printf("\tResolved %s from cache\n", atSign.AtSignStr)
+		}
+		if entry.NotFound {
+			return nil, exceptions.NewAtSecondaryNotFoundException("No secondary found for " + atSign.AtSignStr)
+		}
+		return AddressFromString(entry.Address)
+	}
+
+	rootConnection := NewAtRootConnection(r.RootAddress, r.verbose, r.options...)
+	address, err := rootConnection.FindSecondaryContext(ctx, atSign)
+	rootConnection.AtConnection.Disconnect()
+
+	var notFound *exceptions.AtSecondaryNotFoundException
+	switch {
+	case errors.As(err, &notFound):
+		r.store(atSign, rootCacheEntry{NotFound: true, Expires: time.Now().Add(r.NegativeTTL)})
+	case err == nil:
+		r.store(atSign, rootCacheEntry{Address: address.String(), Expires: time.Now().Add(r.TTL)})
+	}
+	return address, err
+}
+
+// Invalidate forgets the cached address of atSign's secondary, e.g. because connecting to it
+// failed, so that the next FindSecondary asks the root server again.
+func (r *RootResolver) Invalidate(atSign common.AtSign) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.load()
+	if _, ok := r.entries[atSign.AtSignStr]; ok {
+		delete(r.entries, atSign.AtSignStr)
+		r.save()
+	}
+}
+
+func (r *RootResolver) cached(atSign common.AtSign) (rootCacheEntry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.load()
+	entry, ok := r.entries[atSign.AtSignStr]
+	if !ok || time.Now().After(entry.Expires) {
+		return rootCacheEntry{}, false
+	}
+	return entry, true
+}
+
+func (r *RootResolver) store(atSign common.AtSign, entry rootCacheEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.load()
+	r.entries[atSign.AtSignStr] = entry
+	r.save()
+}
+
+// load reads CacheFile, once, adding the entries that have not expired. It is called with mu
+// held. A missing or unreadable file leaves the cache as it is.
+func (r *RootResolver) load() {
+	if r.entries == nil {
+		r.entries = map[string]rootCacheEntry{}
+	}
+	if r.loaded || r.CacheFile == "" {
+		return
+	}
+	r.loaded = true
+
+	data, err := os.ReadFile(r.CacheFile)
+	if err != nil {
+		return
+	}
+	entries := map[string]rootCacheEntry{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		if r.verbose {
+			fmt.Printf("\tIgnoring root cache file %s - %s\n", r.CacheFile, err)
+		}
+		return
+	}
+	now := time.Now()
+	for atSign, entry := range entries {
+		if _, ok := r.entries[atSign]; !ok && now.Before(entry.Expires) {
+			r.entries[atSign] = entry
+		}
+	}
+}
+
+// save writes the entries that have not expired to CacheFile. It is called with mu held. The
+// cache is only an optimisation, so failures are ignored.
+func (r *RootResolver) save() {
+	if r.CacheFile == "" {
+		return
+	}
+	now := time.Now()
+	entries := map[string]rootCacheEntry{}
+	for atSign, entry := range r.entries {
+		if now.Before(entry.Expires) {
+			entries[atSign] = entry
+		}
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return
+	}
+
+	// Write to a temporary file and rename it, so that other processes never read half a file
+	if err := os.MkdirAll(filepath.Dir(r.CacheFile), 0700); err != nil {
+		return
+	}
+	temp, err := os.CreateTemp(filepath.Dir(r.CacheFile), filepath.Base(r.CacheFile)+".*")
+	if err != nil {
+		return
+	}
+	_, err = temp.Write(data)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), r.CacheFile)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		if r.verbose {
+			fmt.Printf("\tFailed to save root cache file %s - %s\n", r.CacheFile, err)
+		}
+	}
+}
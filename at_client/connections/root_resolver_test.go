@@ -0,0 +1,186 @@
+package connections_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/atsign-foundation/at_go/at_client/attest"
+	"github.com/atsign-foundation/at_go/at_client/common"
+	"github.com/atsign-foundation/at_go/at_client/connections"
+	"github.com/atsign-foundation/at_go/at_client/exceptions"
+)
+
+// newRootEnvironment starts an attest environment with atSigns onboarded, closed when the test ends.
+func newRootEnvironment(t *testing.T, atSigns ...string) *attest.Environment {
+	t.Helper()
+	env, err := attest.NewEnvironment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { env.Close() })
+	for _, atSign := range atSigns {
+		if _, err := env.AddAtSign(*common.NewAtSign(atSign)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return env
+}
+
+func newRootResolver(env *attest.Environment) *connections.RootResolver {
+	return connections.NewRootResolver(env.RootAddress(), false, connections.WithTLSConfig(env.ClientTLSConfig()))
+}
+
+// checkFindSecondary checks that resolver finds atSign's secondary at want.
+func checkFindSecondary(t *testing.T, resolver *connections.RootResolver, atSign common.AtSign, want connections.Address) {
+	t.Helper()
+	address, err := resolver.FindSecondary(context.Background(), atSign)
+	if err != nil {
+		t.Fatalf("FindSecondary(%s): %v", atSign.AtSignStr, err)
+	}
+	if address.String() != want.String() {
+		t.Errorf("FindSecondary(%s) = %s, want %s", atSign.AtSignStr, address.String(), want.String())
+	}
+}
+
+// unusedAddress returns a local address nothing is listening on.
+func unusedAddress(t *testing.T) connections.Address {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener.Close()
+	address, err := connections.AddressFromString(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *address
+}
+
+func TestRootResolverCachesAddress(t *testing.T) {
+	env := newRootEnvironment(t, "@alice")
+	alice := common.NewAtSign("@alice")
+	resolver := newRootResolver(env)
+
+	checkFindSecondary(t, resolver, *alice, env.AtServer("@alice").Address())
+	checkFindSecondary(t, resolver, *alice, env.AtServer("@alice").Address())
+	if lookups := env.Root.Lookups(*alice); lookups != 1 {
+		t.Errorf("root server was asked %d times, want once", lookups)
+	}
+}
+
+func TestRootResolverTTL(t *testing.T) {
+	env := newRootEnvironment(t, "@alice")
+	alice := common.NewAtSign("@alice")
+	resolver := newRootResolver(env)
+	resolver.TTL = 50 * time.Millisecond
+
+	checkFindSecondary(t, resolver, *alice, env.AtServer("@alice").Address())
+	moved := unusedAddress(t)
+	env.Root.Register(*alice, moved)
+
+	// Until the TTL passes the old address is still used
+	checkFindSecondary(t, resolver, *alice, env.AtServer("@alice").Address())
+	time.Sleep(100 * time.Millisecond)
+	checkFindSecondary(t, resolver, *alice, moved)
+	if lookups := env.Root.Lookups(*alice); lookups != 2 {
+		t.Errorf("root server was asked %d times, want twice", lookups)
+	}
+}
+
+func TestRootResolverNegativeCache(t *testing.T) {
+	env := newRootEnvironment(t)
+	bob := common.NewAtSign("@bob")
+	resolver := newRootResolver(env)
+	resolver.NegativeTTL = 50 * time.Millisecond
+
+	for i := 0; i < 2; i++ {
+		_, err := resolver.FindSecondary(context.Background(), *bob)
+		var notFound *exceptions.AtSecondaryNotFoundException
+		if !errors.As(err, &notFound) {
+			t.Fatalf("FindSecondary(@bob) error = %v, want AtSecondaryNotFoundException", err)
+		}
+	}
+	if lookups := env.Root.Lookups(*bob); lookups != 1 {
+		t.Errorf("root server was asked %d times, want once", lookups)
+	}
+
+	if _, err := env.AddAtSign(*bob); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	checkFindSecondary(t, resolver, *bob, env.AtServer("@bob").Address())
+}
+
+func TestRootResolverInvalidate(t *testing.T) {
+	env := newRootEnvironment(t, "@alice")
+	alice := common.NewAtSign("@alice")
+	resolver := newRootResolver(env)
+
+	checkFindSecondary(t, resolver, *alice, env.AtServer("@alice").Address())
+	moved := unusedAddress(t)
+	env.Root.Register(*alice, moved)
+
+	resolver.Invalidate(*alice)
+	checkFindSecondary(t, resolver, *alice, moved)
+	if lookups := env.Root.Lookups(*alice); lookups != 2 {
+		t.Errorf("root server was asked %d times, want twice", lookups)
+	}
+}
+
+func TestRootResolverCacheFile(t *testing.T) {
+	env := newRootEnvironment(t, "@alice")
+	alice := common.NewAtSign("@alice")
+	cacheFile := filepath.Join(t.TempDir(), "cache", "root.json")
+
+	first := newRootResolver(env)
+	first.CacheFile = cacheFile
+	checkFindSecondary(t, first, *alice, env.AtServer("@alice").Address())
+
+	// Another process with the same cache file does not ask the root server
+	second := newRootResolver(env)
+	second.CacheFile = cacheFile
+	checkFindSecondary(t, second, *alice, env.AtServer("@alice").Address())
+	if lookups := env.Root.Lookups(*alice); lookups != 1 {
+		t.Errorf("root server was asked %d times, want once", lookups)
+	}
+
+	// Invalidating removes the entry from the file too
+	second.Invalidate(*alice)
+	third := newRootResolver(env)
+	third.CacheFile = cacheFile
+	checkFindSecondary(t, third, *alice, env.AtServer("@alice").Address())
+	if lookups := env.Root.Lookups(*alice); lookups != 2 {
+		t.Errorf("root server was asked %d times after Invalidate, want twice", lookups)
+	}
+}
+
+func TestRootResolverIgnoresUnusableCacheFile(t *testing.T) {
+	env := newRootEnvironment(t, "@alice")
+	alice := common.NewAtSign("@alice")
+	expired := `{"@alice":{"address":"127.0.0.1:1","expires":"2000-01-01T00:00:00Z"}}`
+
+	tests := []struct {
+		name     string
+		contents string
+	}{
+		{"expired entry", expired},
+		{"corrupt", `{"@alice":`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cacheFile := filepath.Join(t.TempDir(), "root.json")
+			if err := os.WriteFile(cacheFile, []byte(tt.contents), 0600); err != nil {
+				t.Fatal(err)
+			}
+			resolver := newRootResolver(env)
+			resolver.CacheFile = cacheFile
+			checkFindSecondary(t, resolver, *alice, env.AtServer("@alice").Address())
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package connections
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/atsign-foundation/at_go/at_client/common"
+	"github.com/atsign-foundation/at_go/at_client/exceptions"
+)
+
+// SecondaryAddressFinder finds the address of an atSign's secondary, e.g. by asking a root
+// server (RootResolver) or looking it up in a fixed map (StaticSecondaryAddressFinder).
+// FindSecondary returns an AtSecondaryNotFoundException if it has no address for atSign.
+type SecondaryAddressFinder interface {
+	FindSecondary(ctx context.Context, atSign common.AtSign) (*Address, error)
+}
+
+// SecondaryAddressInvalidator is implemented by SecondaryAddressFinders that cache addresses.
+// Invalidate is called after connecting to atSign's secondary failed, in case it has moved.
+type SecondaryAddressInvalidator interface {
+	Invalidate(atSign common.AtSign)
+}
+
+// StaticSecondaryAddressFinder finds secondaries in a map from atSign to address, e.g. one
+// read from a configuration file for self-hosted atServers.
+type StaticSecondaryAddressFinder struct {
+	mu        sync.RWMutex
+	addresses map[string]Address
+}
+
+// NewStaticSecondaryAddressFinder returns a finder for the atSigns in addresses, which may be
+// written with or without their leading @.
+func NewStaticSecondaryAddressFinder(addresses map[string]Address) *StaticSecondaryAddressFinder {
+	finder := &StaticSecondaryAddressFinder{addresses: map[string]Address{}}
+	for atSign, address := range addresses {
+		finder.Add(*common.NewAtSign(atSign), address)
+	}
+	return finder
+}
+
+// Add sets the address of atSign's secondary.
+func (f *StaticSecondaryAddressFinder) Add(atSign common.AtSign, address Address) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.addresses[atSign.AtSignStr] = address
+}
+
+func (f *StaticSecondaryAddressFinder) FindSecondary(ctx context.Context, atSign common.AtSign) (*Address, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	address, ok := f.addresses[atSign.AtSignStr]
+	if !ok {
+		return nil, exceptions.NewAtSecondaryNotFoundException("No secondary found for " + atSign.AtSignStr)
+	}
+	return NewAddress(address.host, address.port), nil
+}
+
+// ChainedSecondaryAddressFinder asks each of its finders in turn until one finds the atSign,
+// e.g. a static map of self-hosted atServers before the root server.
+type ChainedSecondaryAddressFinder struct {
+	Finders []SecondaryAddressFinder
+}
+
+func NewChainedSecondaryAddressFinder(finders ...SecondaryAddressFinder) *ChainedSecondaryAddressFinder {
+	return &ChainedSecondaryAddressFinder{Finders: finders}
+}
+
+// FindSecondary returns the first address found, moving on to the next finder only when a
+// finder returns AtSecondaryNotFoundException. Any other error, e.g. an unreachable root
+// server, is returned at once rather than reported as the atSign not existing.
+func (f *ChainedSecondaryAddressFinder) FindSecondary(ctx context.Context, atSign common.AtSign) (*Address, error) {
+	for _, finder := range f.Finders {
+		address, err := finder.FindSecondary(ctx, atSign)
+		var notFound *exceptions.AtSecondaryNotFoundException
+		if err == nil || !errors.As(err, &notFound) {
+			return address, err
+		}
+	}
+	return nil, exceptions.NewAtSecondaryNotFoundException("No secondary found for " + atSign.AtSignStr)
+}
+
+// Invalidate invalidates atSign in each finder that caches addresses.
+func (f *ChainedSecondaryAddressFinder) Invalidate(atSign common.AtSign) {
+	for _, finder := range f.Finders {
+		if invalidator, ok := finder.(SecondaryAddressInvalidator); ok {
+			invalidator.Invalidate(atSign)
+		}
+	}
+}
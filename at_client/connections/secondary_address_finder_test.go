@@ -0,0 +1,110 @@
+package connections_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/atsign-foundation/at_go/at_client/common"
+	"github.com/atsign-foundation/at_go/at_client/connections"
+	"github.com/atsign-foundation/at_go/at_client/exceptions"
+)
+
+// fakeFinder returns address or err, and records the atSigns it is asked for and invalidates.
+type fakeFinder struct {
+	address     *connections.Address
+	err         error
+	asked       []string
+	invalidated []string
+}
+
+func (f *fakeFinder) FindSecondary(ctx context.Context, atSign common.AtSign) (*connections.Address, error) {
+	f.asked = append(f.asked, atSign.AtSignStr)
+	return f.address, f.err
+}
+
+func (f *fakeFinder) Invalidate(atSign common.AtSign) {
+	f.invalidated = append(f.invalidated, atSign.AtSignStr)
+}
+
+func notFoundFinder() *fakeFinder {
+	return &fakeFinder{err: exceptions.NewAtSecondaryNotFoundException("No secondary found")}
+}
+
+func TestStaticSecondaryAddressFinder(t *testing.T) {
+	finder := connections.NewStaticSecondaryAddressFinder(map[string]connections.Address{
+		"@alice": *connections.NewAddress("alice.example", 6464),
+		"bob":    *connections.NewAddress("bob.example", 6464),
+	})
+	finder.Add(*common.NewAtSign("@carol"), *connections.NewAddress("carol.example", 6464))
+
+	for atSign, want := range map[string]string{"@alice": "alice.example:6464", "@bob": "bob.example:6464", "@carol": "carol.example:6464"} {
+		address, err := finder.FindSecondary(context.Background(), *common.NewAtSign(atSign))
+		if err != nil {
+			t.Errorf("FindSecondary(%s): %v", atSign, err)
+		} else if address.String() != want {
+			t.Errorf("FindSecondary(%s) = %s, want %s", atSign, address.String(), want)
+		}
+	}
+
+	_, err := finder.FindSecondary(context.Background(), *common.NewAtSign("@dave"))
+	var notFound *exceptions.AtSecondaryNotFoundException
+	if !errors.As(err, &notFound) {
+		t.Errorf("FindSecondary(@dave) error = %v, want AtSecondaryNotFoundException", err)
+	}
+}
+
+func TestChainedSecondaryAddressFinderFallsThroughNotFound(t *testing.T) {
+	first := notFoundFinder()
+	second := &fakeFinder{address: connections.NewAddress("alice.example", 6464)}
+	third := &fakeFinder{address: connections.NewAddress("other.example", 6464)}
+	chain := connections.NewChainedSecondaryAddressFinder(first, second, third)
+
+	address, err := chain.FindSecondary(context.Background(), *common.NewAtSign("@alice"))
+	if err != nil {
+		t.Fatalf("FindSecondary: %v", err)
+	}
+	if address.String() != "alice.example:6464" {
+		t.Errorf("FindSecondary = %s, want the second finder's address", address.String())
+	}
+	if len(first.asked) != 1 || len(second.asked) != 1 || len(third.asked) != 0 {
+		t.Errorf("finders were asked %v, %v and %v, want the first two once", first.asked, second.asked, third.asked)
+	}
+}
+
+func TestChainedSecondaryAddressFinderNotFound(t *testing.T) {
+	chain := connections.NewChainedSecondaryAddressFinder(notFoundFinder(), notFoundFinder())
+
+	_, err := chain.FindSecondary(context.Background(), *common.NewAtSign("@alice"))
+	var notFound *exceptions.AtSecondaryNotFoundException
+	if !errors.As(err, &notFound) {
+		t.Errorf("FindSecondary error = %v, want AtSecondaryNotFoundException", err)
+	}
+}
+
+func TestChainedSecondaryAddressFinderStopsOnHardError(t *testing.T) {
+	unreachable := errors.New("root server unreachable")
+	first := notFoundFinder()
+	second := &fakeFinder{err: unreachable}
+	third := &fakeFinder{address: connections.NewAddress("alice.example", 6464)}
+	chain := connections.NewChainedSecondaryAddressFinder(first, second, third)
+
+	_, err := chain.FindSecondary(context.Background(), *common.NewAtSign("@alice"))
+	if !errors.Is(err, unreachable) {
+		t.Errorf("FindSecondary error = %v, want %v", err, unreachable)
+	}
+	if len(third.asked) != 0 {
+		t.Error("the chain asked the next finder after a hard error")
+	}
+}
+
+func TestChainedSecondaryAddressFinderInvalidate(t *testing.T) {
+	caching := notFoundFinder()
+	static := connections.NewStaticSecondaryAddressFinder(nil)
+	chain := connections.NewChainedSecondaryAddressFinder(static, caching)
+
+	chain.Invalidate(*common.NewAtSign("@alice"))
+	if len(caching.invalidated) != 1 || caching.invalidated[0] != "@alice" {
+		t.Errorf("invalidated %v, want [@alice]", caching.invalidated)
+	}
+}
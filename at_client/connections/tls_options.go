@@ -0,0 +1,133 @@
+package connections
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"os"
+)
+
+// WithRootCAs trusts the certificate authorities in pool, e.g. a private CA for staging
+// atServers, instead of the system's.
+func WithRootCAs(pool *x509.CertPool) ConnectionOption {
+	return func(atconn *AtConnection) {
+		atconn.tlsConfig().RootCAs = pool
+	}
+}
+
+// WithTLSVersions limits the TLS versions negotiated, e.g. tls.VersionTLS13. Zero leaves a
+// bound at its default; the default minimum is TLS 1.2.
+func WithTLSVersions(minVersion, maxVersion uint16) ConnectionOption {
+	return func(atconn *AtConnection) {
+		if minVersion != 0 {
+			atconn.tlsConfig().MinVersion = minVersion
+		}
+		if maxVersion != 0 {
+			atconn.tlsConfig().MaxVersion = maxVersion
+		}
+	}
+}
+
+// WithServerName verifies the server's certificate, and sends SNI, for serverName instead of
+// the host dialled.
+func WithServerName(serverName string) ConnectionOption {
+	return func(atconn *AtConnection) {
+		atconn.tlsConfig().ServerName = serverName
+	}
+}
+
+// WithClientCertificates presents certificates to servers that ask for one, for mutual TLS.
+func WithClientCertificates(certificates ...tls.Certificate) ConnectionOption {
+	return func(atconn *AtConnection) {
+		atconn.tlsConfig().Certificates = append(atconn.tlsConfig().Certificates, certificates...)
+	}
+}
+
+// WithPinnedSPKIHashes only accepts servers whose verified certificate chain contains a
+// certificate whose public key has one of hashes, as returned by SPKIHash. Pinning applies as
+// well as the usual verification. With WithInsecureSkipVerify there is no verified chain, so
+// the server's own certificate must match a pin.
+func WithPinnedSPKIHashes(hashes ...string) ConnectionOption {
+	return func(atconn *AtConnection) {
+		atconn.pinnedSPKIHashes = append(atconn.pinnedSPKIHashes, hashes...)
+	}
+}
+
+// WithInsecureSkipVerify accepts any server certificate. Only use it for local testing.
+func WithInsecureSkipVerify() ConnectionOption {
+	return func(atconn *AtConnection) {
+		atconn.tlsConfig().InsecureSkipVerify = true
+	}
+}
+
+// SPKIHash returns the base64 SHA-256 hash of certificate's SubjectPublicKeyInfo, for use
+// with WithPinnedSPKIHashes.
+func SPKIHash(certificate *x509.Certificate) string {
+	hash := sha256.Sum256(certificate.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// LoadCertPool returns a pool of the PEM certificates in files, for use with WithRootCAs.
+func LoadCertPool(files ...string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("No certificates found in %s", file)
+		}
+	}
+	return pool, nil
+}
+
+// tlsConfig returns the configuration options add to, creating it if need be.
+func (atconn *AtConnection) tlsConfig() *tls.Config {
+	if atconn.config == nil {
+		atconn.config = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return atconn.config
+}
+
+// dialConfig returns the configuration to dial with, checking the pinned SPKI hashes, if any,
+// once the handshake has verified the server as configured.
+func (atconn *AtConnection) dialConfig() *tls.Config {
+	config := atconn.tlsConfig().Clone()
+	if len(atconn.pinnedSPKIHashes) == 0 {
+		return config
+	}
+
+	pins := atconn.pinnedSPKIHashes
+	insecure := config.InsecureSkipVerify
+	verifyConnection := config.VerifyConnection
+	config.VerifyConnection = func(state tls.ConnectionState) error {
+		if verifyConnection != nil {
+			if err := verifyConnection(state); err != nil {
+				return err
+			}
+		}
+		// PeerCertificates is whatever the server sent, so only its leaf can be trusted to be
+		// the server's, and only once the server has proved it holds the leaf's private key
+		var certificates []*x509.Certificate
+		if insecure {
+			certificates = state.PeerCertificates[:min(1, len(state.PeerCertificates))]
+		}
+		for _, chain := range state.VerifiedChains {
+			certificates = append(certificates, chain...)
+		}
+		for _, certificate := range certificates {
+			hash := SPKIHash(certificate)
+			for _, pin := range pins {
+				if subtle.ConstantTimeCompare([]byte(hash), []byte(pin)) == 1 {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("No certificate presented by %s matches a pinned SPKI hash", atconn.String())
+	}
+	return config
+}
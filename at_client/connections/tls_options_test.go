@@ -0,0 +1,203 @@
+package connections_test
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/atsign-foundation/at_go/at_client/attest"
+	"github.com/atsign-foundation/at_go/at_client/connections"
+)
+
+// tlsServer accepts TLS connections, sends each a prompt and records its handshake.
+type tlsServer struct {
+	port int
+
+	mu     sync.Mutex
+	states []tls.ConnectionState
+}
+
+// startTLSServer starts a tlsServer with config, closed when the test ends.
+func startTLSServer(t *testing.T, config *tls.Config) *tlsServer {
+	t.Helper()
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &tlsServer{port: listener.Addr().(*net.TCPAddr).Port}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer conn.Close()
+				tlsConn := conn.(*tls.Conn)
+				if err := tlsConn.Handshake(); err != nil {
+					return
+				}
+				server.mu.Lock()
+				server.states = append(server.states, tlsConn.ConnectionState())
+				server.mu.Unlock()
+				if _, err := conn.Write([]byte("@")); err != nil {
+					return
+				}
+				io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+	t.Cleanup(func() {
+		listener.Close()
+		wg.Wait()
+	})
+	return server
+}
+
+// connect connects to the server with options, returning the error from Connect.
+func (s *tlsServer) connect(t *testing.T, options ...connections.ConnectionOption) error {
+	t.Helper()
+	atconn := connections.NewAtConnection("127.0.0.1", s.port, context.Background(), false, options...)
+	err := atconn.Connect()
+	atconn.Disconnect()
+	return err
+}
+
+// lastState returns the handshake of the last connection the server accepted.
+func (s *tlsServer) lastState(t *testing.T) tls.ConnectionState {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.states) == 0 {
+		t.Fatal("the server completed no handshake")
+	}
+	return s.states[len(s.states)-1]
+}
+
+func newCertificateChain(t *testing.T) *attest.Certificate {
+	t.Helper()
+	chain, err := attest.NewCertificateChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chain
+}
+
+func TestWithPinnedSPKIHashes(t *testing.T) {
+	chain := newCertificateChain(t)
+	other := newCertificateChain(t)
+	server := startTLSServer(t, chain.ServerTLSConfig())
+	leaf, intermediate, root := chain.Chain[0], chain.Chain[1], chain.Chain[2]
+
+	tests := []struct {
+		name    string
+		pins    []string
+		wantErr bool
+	}{
+		{"leaf", []string{connections.SPKIHash(leaf)}, false},
+		{"intermediate", []string{connections.SPKIHash(intermediate)}, false},
+		{"root", []string{connections.SPKIHash(root)}, false},
+		{"one of several", []string{connections.SPKIHash(other.Chain[0]), connections.SPKIHash(intermediate)}, false},
+		{"no match", []string{connections.SPKIHash(other.Chain[0]), connections.SPKIHash(other.Chain[1])}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := server.connect(t, connections.WithTLSConfig(chain.ClientTLSConfig()), connections.WithPinnedSPKIHashes(tt.pins...))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Connect error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithPinnedSPKIHashesFailsVerificationFirst(t *testing.T) {
+	chain := newCertificateChain(t)
+	server := startTLSServer(t, chain.ServerTLSConfig())
+
+	// The pin matches, but the server's certificate is not trusted
+	err := server.connect(t, connections.WithTLSConfig(newCertificateChain(t).ClientTLSConfig()),
+		connections.WithPinnedSPKIHashes(connections.SPKIHash(chain.Chain[0])))
+	if err == nil {
+		t.Error("Connect trusted an unverified certificate because it matched a pin")
+	}
+}
+
+func TestWithPinnedSPKIHashesInsecureSkipVerifyChecksLeafOnly(t *testing.T) {
+	chain := newCertificateChain(t)
+	server := startTLSServer(t, chain.ServerTLSConfig())
+
+	tests := []struct {
+		name    string
+		pins    []string
+		wantErr bool
+	}{
+		{"no pins", nil, false},
+		{"leaf", []string{connections.SPKIHash(chain.Chain[0])}, false},
+		// Without verification the server could have sent any intermediate
+		{"intermediate", []string{connections.SPKIHash(chain.Chain[1])}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := server.connect(t, connections.WithInsecureSkipVerify(), connections.WithPinnedSPKIHashes(tt.pins...))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Connect error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithClientCertificates(t *testing.T) {
+	chain := newCertificateChain(t)
+	clientCertificate := newCertificateChain(t)
+	config := chain.ServerTLSConfig()
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	config.ClientCAs = clientCertificate.CertPool
+	server := startTLSServer(t, config)
+
+	if err := server.connect(t, connections.WithTLSConfig(chain.ClientTLSConfig())); err == nil {
+		t.Error("Connect succeeded without a client certificate")
+	}
+
+	err := server.connect(t, connections.WithTLSConfig(chain.ClientTLSConfig()),
+		connections.WithClientCertificates(clientCertificate.TLSCertificate))
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	presented := server.lastState(t).PeerCertificates
+	if len(presented) == 0 || !presented[0].Equal(clientCertificate.Chain[0]) {
+		t.Error("the server did not receive the client certificate")
+	}
+}
+
+func TestWithTLSVersions(t *testing.T) {
+	chain := newCertificateChain(t)
+	config := chain.ServerTLSConfig()
+	config.MaxVersion = tls.VersionTLS12
+	tls12Server := startTLSServer(t, config)
+	server := startTLSServer(t, chain.ServerTLSConfig())
+
+	if err := tls12Server.connect(t, connections.WithTLSConfig(chain.ClientTLSConfig())); err != nil {
+		t.Fatalf("Connect to a TLS 1.2 server: %v", err)
+	}
+	err := tls12Server.connect(t, connections.WithTLSConfig(chain.ClientTLSConfig()), connections.WithTLSVersions(tls.VersionTLS13, 0))
+	if err == nil {
+		t.Error("Connect negotiated TLS 1.2 with a TLS 1.3 minimum")
+	}
+
+	err = server.connect(t, connections.WithTLSConfig(chain.ClientTLSConfig()), connections.WithTLSVersions(0, tls.VersionTLS12))
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if version := server.lastState(t).Version; version != tls.VersionTLS12 {
+		t.Errorf("negotiated version %x, want TLS 1.2", version)
+	}
+}
@@ -188,6 +188,26 @@ func NewAtDecryptionException(message string) *AtDecryptionException {
 	return &AtDecryptionException{NewAtException(message)}
 }
 
+type AtSignatureVerificationException struct {
+	*AtException
+}
+
+func NewAtSignatureVerificationException(message string) *AtSignatureVerificationException {
+	return &AtSignatureVerificationException{NewAtException(message)}
+}
+
+// AtApkamAuthenticationException is returned when an atServer refuses PKAM authentication with
+// an enrollment that is not approved. EnrollmentStatus is the enrollment's status, e.g.
+// "pending" or "denied", if the atServer gave it.
+type AtApkamAuthenticationException struct {
+	*AtException
+	EnrollmentStatus string
+}
+
+func NewAtApkamAuthenticationException(message string, enrollmentStatus string) *AtApkamAuthenticationException {
+	return &AtApkamAuthenticationException{NewAtException(message), enrollmentStatus}
+}
+
 type AtRegistrarException struct {
 	*AtException
 }
@@ -195,3 +215,15 @@ type AtRegistrarException struct {
 func NewAtRegistrarException(message string) *AtRegistrarException {
 	return &AtRegistrarException{NewAtException(message)}
 }
+
+// AtSharedKeyRotationException is returned by RotateSharedKey when some values could not be
+// re-encrypted with the new shared key. NotRewritten names the keys of those values, which are
+// left encrypted with the old key.
+type AtSharedKeyRotationException struct {
+	*AtException
+	NotRewritten []string
+}
+
+func NewAtSharedKeyRotationException(message string, notRewritten []string) *AtSharedKeyRotationException {
+	return &AtSharedKeyRotationException{NewAtException(message), notRewritten}
+}
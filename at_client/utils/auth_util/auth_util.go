@@ -22,7 +22,7 @@ func NewAuthUtil() *AuthUtil {
 	return &AuthUtil{}
 }
 
-func AuthenticateWithCram(conn connections.AtConnection, atSign common.AtSign, cramSecret string) *exceptions.AtException {
+func AuthenticateWithCram(conn *connections.AtConnection, atSign common.AtSign, cramSecret string) *exceptions.AtException {
 	fromCommand := verb_builder.NewFromVerbBuilder().SetSharedBy(atSign.AtSignStr).Build()
 	fromResponse, err := conn.ExecuteCommand(fromCommand, true)
 	if err != nil {
@@ -40,12 +40,12 @@ func AuthenticateWithCram(conn connections.AtConnection, atSign common.AtSign, c
 		return exceptions.NewAtException(err.Error())
 	}
 	if !strings.HasPrefix(cramResponse.GetRawDataResponse(), "data:success") {
-		return exceptions.NewAtUnauthenticatedException("CRAM command failed: " + fromResponse.GetRawDataResponse()).AtException
+		return exceptions.NewAtUnauthenticatedException("CRAM command failed: " + cramResponse.GetRawDataResponse()).AtException
 	}
 	return nil
 }
 
-func AuthenticateWithPkam(conn connections.AtConnection, atSign common.AtSign, keys map[string]string) error {
+func AuthenticateWithPkam(conn *connections.AtConnection, atSign common.AtSign, keys map[string]string) error {
 	fromCommand := verb_builder.NewFromVerbBuilder().SetSharedBy(atSign.AtSignStr).Build()
 	fromResponse, err := conn.ExecuteCommand(fromCommand, true)
 	if err != nil {
@@ -58,14 +58,21 @@ func AuthenticateWithPkam(conn connections.AtConnection, atSign common.AtSign, k
 		return exceptions.NewAtException(err.Error())
 	}
 
-	pkamCommand := verb_builder.NewPKAMVerbBuilder().SetDigest(signature).Build()
+	pkamCommand := verb_builder.NewPKAMVerbBuilder().SetDigest(signature).SetEnrollmentId(keys[key_utils.EnrollmentIdName]).Build()
 	pkamResponse, err := conn.ExecuteCommand(pkamCommand, true)
 	if err != nil {
 		return exceptions.NewAtException(err.Error())
 	}
 
 	if !strings.HasPrefix(pkamResponse.GetRawDataResponse(), "data:success") {
-		return exceptions.NewAtUnauthenticatedException("PKAM command failed: " + fromResponse.GetRawDataResponse()).AtException
+		message := "PKAM command failed: " + pkamResponse.GetRawDataResponse()
+		parsed, err := connections.ParseRawResponse(pkamResponse.GetRawDataResponse())
+		if err == nil && parsed.GetErrorCode() == "AT0025" {
+			if apkamErr, ok := parsed.GetException().(*exceptions.AtApkamAuthenticationException); ok {
+				return exceptions.NewAtApkamAuthenticationException(message, apkamErr.EnrollmentStatus)
+			}
+		}
+		return exceptions.NewAtUnauthenticatedException(message).AtException
 	}
 	return nil
 }
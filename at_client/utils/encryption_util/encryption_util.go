@@ -5,23 +5,36 @@ import (
 	"crypto"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/md5"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 )
 
 type EncryptionUtil struct{}
 
+// Modes recorded in Metadata.EncAlgo for encrypted values. Values with no mode recorded are
+// AES-256-CTR, with the IV in Metadata.IVNonce or, for older values, an all-zero IV.
+const (
+	EncryptionModeAesCtr = "aes-256-ctr"
+	EncryptionModeAesGcm = "aes-256-gcm"
+)
+
 func NewEncryptionUtil() *EncryptionUtil {
 	return &EncryptionUtil{}
 }
 
+// AesEncryptFromBase64 encrypts clearText with AES-256-CTR under iv, which the caller must
+// keep to decrypt it: a new random IV for values (see GenerateIVBase64), or an all-zero IV
+// where the format has nowhere to record one, as in atKeys files.
 func (e *EncryptionUtil) AesEncryptFromBase64(clearText, keyBase64 string, iv []byte) (string, error) {
 
 	key, err := base64.StdEncoding.DecodeString(keyBase64)
@@ -35,10 +48,10 @@ func (e *EncryptionUtil) AesEncryptFromBase64(clearText, keyBase64 string, iv []
 	}
 
 	if iv == nil {
-		iv = make([]byte, aes.BlockSize)
-		if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-			return "", err
-		}
+		return "", fmt.Errorf("aes-ctr: No iv given")
+	}
+	if len(iv) != aes.BlockSize {
+		return "", fmt.Errorf("aes-ctr: Invalid iv size %d", len(iv))
 	}
 
 	stream := cipher.NewCTR(block, iv)
@@ -54,6 +67,8 @@ func (e *EncryptionUtil) AesEncryptFromBase64(clearText, keyBase64 string, iv []
 	return base64.StdEncoding.EncodeToString(cipherText), nil
 }
 
+// AesDecryptFromBase64 decrypts AES-256-CTR encryptedText under iv. Values stored without an
+// ivNonce were encrypted under an all-zero IV, which the caller must then pass explicitly.
 func (e *EncryptionUtil) AesDecryptFromBase64(encryptedText, selfEncryptionKeyBase64 string, iv []byte) (string, error) {
 	cipherText, err := base64.StdEncoding.DecodeString(encryptedText)
 	if err != nil {
@@ -71,7 +86,10 @@ func (e *EncryptionUtil) AesDecryptFromBase64(encryptedText, selfEncryptionKeyBa
 	}
 
 	if iv == nil {
-		iv = make([]byte, aes.BlockSize)
+		return "", fmt.Errorf("aes-ctr: No iv given")
+	}
+	if len(iv) != aes.BlockSize {
+		return "", fmt.Errorf("aes-ctr: Invalid iv size %d", len(iv))
 	}
 
 	stream := cipher.NewCTR(block, iv)
@@ -87,6 +105,68 @@ func (e *EncryptionUtil) AesDecryptFromBase64(encryptedText, selfEncryptionKeyBa
 	return string(unpaddedText), nil
 }
 
+// AesGcmEncryptFromBase64 encrypts clearText with AES-256-GCM under a new random nonce, and
+// returns the base64 ciphertext, which ends with the authentication tag, and the base64 nonce.
+func (e *EncryptionUtil) AesGcmEncryptFromBase64(clearText, keyBase64 string) (string, string, error) {
+	aead, err := newGCM(keyBase64)
+	if err != nil {
+		return "", "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", "", err
+	}
+
+	cipherText := aead.Seal(nil, nonce, []byte(clearText), nil)
+	return base64.StdEncoding.EncodeToString(cipherText), base64.StdEncoding.EncodeToString(nonce), nil
+}
+
+// AesGcmDecryptFromBase64 decrypts a value encrypted by AesGcmEncryptFromBase64, failing if the
+// ciphertext or nonce has been tampered with.
+func (e *EncryptionUtil) AesGcmDecryptFromBase64(encryptedText, keyBase64, nonceBase64 string) (string, error) {
+	cipherText, err := base64.StdEncoding.DecodeString(encryptedText)
+	if err != nil {
+		return "", err
+	}
+
+	nonce, err := base64.StdEncoding.DecodeString(nonceBase64)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := newGCM(keyBase64)
+	if err != nil {
+		return "", err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return "", fmt.Errorf("aes-gcm: Invalid nonce size %d", len(nonce))
+	}
+
+	plainText, err := aead.Open(nil, nonce, cipherText, nil)
+	if err != nil {
+		return "", errors.New("aes-gcm: Message authentication failed")
+	}
+
+	return string(plainText), nil
+}
+
+func newGCM(keyBase64 string) (cipher.AEAD, error) {
+	key, err := base64.StdEncoding.DecodeString(keyBase64)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("aes-gcm: Key must be 32 bytes, not %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
 // pkcs7strip remove pkcs7 padding
 func pkcs7Unpad(data []byte, blockSize int) ([]byte, error) {
 	length := len(data)
@@ -122,7 +202,10 @@ func (e *EncryptionUtil) GenerateRSAKeyPair() ([]byte, []byte, error) {
 	}
 
 	privateKeyBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
-	publicKeyBytes := x509.MarshalPKCS1PublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -139,13 +222,18 @@ func (e *EncryptionUtil) GenerateAESKeyBase64() (string, error) {
 	return base64.StdEncoding.EncodeToString(key), nil
 }
 
-func (e *EncryptionUtil) RsaDecryptFromBase64(cipherText string, privateKeyBytes []byte) (string, error) {
-	block, _ := pem.Decode(privateKeyBytes)
-	if block == nil {
-		return "", fmt.Errorf("failed to decode private key")
+// GenerateIVBase64 returns a random IV for AES-CTR, to be recorded in the value's ivNonce.
+func (e *EncryptionUtil) GenerateIVBase64() (string, error) {
+	iv := make([]byte, aes.BlockSize)
+	_, err := rand.Read(iv)
+	if err != nil {
+		return "", err
 	}
+	return base64.StdEncoding.EncodeToString(iv), nil
+}
 
-	privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+func (e *EncryptionUtil) RsaDecryptFromBase64(cipherText string, privateKeyBytes []byte) (string, error) {
+	privateKey, err := e.PrivateKeyFromBase64(string(privateKeyBytes))
 	if err != nil {
 		return "", err
 	}
@@ -155,7 +243,7 @@ func (e *EncryptionUtil) RsaDecryptFromBase64(cipherText string, privateKeyBytes
 		return "", err
 	}
 
-	decryptedBytes, err := rsa.DecryptPKCS1v15(rand.Reader, privateKey.(*rsa.PrivateKey), cipherBytes)
+	decryptedBytes, err := rsa.DecryptPKCS1v15(rand.Reader, privateKey, cipherBytes)
 	if err != nil {
 		return "", err
 	}
@@ -164,12 +252,7 @@ func (e *EncryptionUtil) RsaDecryptFromBase64(cipherText string, privateKeyBytes
 }
 
 func (e *EncryptionUtil) RsaEncryptToBase64(clearText string, publicKeyBytes []byte) (string, error) {
-	block, _ := pem.Decode(publicKeyBytes)
-	if block == nil {
-		return "", fmt.Errorf("failed to decode public key")
-	}
-
-	publicKey, err := x509.ParsePKCS1PublicKey(block.Bytes)
+	publicKey, err := e.PublicKeyFromBase64(string(publicKeyBytes))
 	if err != nil {
 		return "", err
 	}
@@ -197,35 +280,76 @@ func (e *EncryptionUtil) SignSHA256RSA(inputData string, privateKeyBytes []byte)
 	return base64.StdEncoding.EncodeToString(signature), nil
 }
 
-func (e *EncryptionUtil) PrivateKeyFromBase64(s string) (*rsa.PrivateKey, error) {
-	keyBytes, err := base64.StdEncoding.DecodeString(s)
+// VerifySHA256RSA checks that signatureBase64 is a signature of inputData, made by SignSHA256RSA
+// with the private key of publicKeyBytes.
+func (e *EncryptionUtil) VerifySHA256RSA(inputData, signatureBase64 string, publicKeyBytes []byte) error {
+	publicKey, err := e.PublicKeyFromBase64(string(publicKeyBytes))
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	privateKey, err := x509.ParsePKCS8PrivateKey(keyBytes)
+	signature, err := base64.StdEncoding.DecodeString(signatureBase64)
+	if err != nil {
+		return err
+	}
+
+	hashed := sha256.Sum256([]byte(inputData))
+	return rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hashed[:], signature)
+}
+
+// PublicKeyChecksum returns the checksum sent as pubKeyCS with a shared key encrypted with
+// publicKeyBase64: the hex MD5 of the key as published.
+func (e *EncryptionUtil) PublicKeyChecksum(publicKeyBase64 string) string {
+	checksum := md5.Sum([]byte(publicKeyBase64))
+	return hex.EncodeToString(checksum[:])
+}
+
+// PrivateKeyFromBase64 accepts either the base64 DER form stored in atKeys files or a PEM block.
+func (e *EncryptionUtil) PrivateKeyFromBase64(s string) (*rsa.PrivateKey, error) {
+	keyBytes, err := decodeKeyBytes(s)
 	if err != nil {
 		return nil, err
 	}
 
-	return privateKey.(*rsa.PrivateKey), nil
+	if privateKey, err := x509.ParsePKCS8PrivateKey(keyBytes); err == nil {
+		if rsaPrivateKey, ok := privateKey.(*rsa.PrivateKey); ok {
+			return rsaPrivateKey, nil
+		}
+		return nil, fmt.Errorf("private key is not an RSA key")
+	}
+
+	return x509.ParsePKCS1PrivateKey(keyBytes)
 }
 
+// PublicKeyFromBase64 accepts either the base64 DER form published as public:publickey or a PEM block.
 func (e *EncryptionUtil) PublicKeyFromBase64(s string) (*rsa.PublicKey, error) {
-	keyBytes, err := base64.StdEncoding.DecodeString(s)
+	keyBytes, err := decodeKeyBytes(s)
 	if err != nil {
 		return nil, err
 	}
 
-	block, _ := pem.Decode(keyBytes)
-	if block == nil {
-		return nil, fmt.Errorf("failed to decode public key")
+	if publicKey, err := x509.ParsePKIXPublicKey(keyBytes); err == nil {
+		if rsaPublicKey, ok := publicKey.(*rsa.PublicKey); ok {
+			return rsaPublicKey, nil
+		}
+		return nil, fmt.Errorf("public key is not an RSA key")
 	}
 
-	publicKey, err := x509.ParsePKCS1PublicKey(block.Bytes)
+	return x509.ParsePKCS1PublicKey(keyBytes)
+}
+
+func decodeKeyBytes(s string) ([]byte, error) {
+	if block, _ := pem.Decode([]byte(s)); block != nil {
+		return block.Bytes, nil
+	}
+
+	keyBytes, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
 	if err != nil {
 		return nil, err
 	}
 
-	return publicKey, nil
+	if block, _ := pem.Decode(keyBytes); block != nil {
+		return block.Bytes, nil
+	}
+	return keyBytes, nil
 }
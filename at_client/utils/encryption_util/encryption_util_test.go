@@ -0,0 +1,158 @@
+package encryption_util_test
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/atsign-foundation/at_go/at_client/utils/encryption_util"
+)
+
+// testKey is the AES-256 key 0x00, 0x01, ... 0x1f.
+const testKey = "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8="
+
+func TestAesGcmRoundTrip(t *testing.T) {
+	e := encryption_util.NewEncryptionUtil()
+	for _, value := range []string{"", "hello", "a value longer than one sixteen byte AES block"} {
+		ciphertext, nonce, err := e.AesGcmEncryptFromBase64(value, testKey)
+		if err != nil {
+			t.Fatalf("AesGcmEncryptFromBase64(%q): %v", value, err)
+		}
+		got, err := e.AesGcmDecryptFromBase64(ciphertext, testKey, nonce)
+		if err != nil {
+			t.Fatalf("AesGcmDecryptFromBase64: %v", err)
+		}
+		if got != value {
+			t.Errorf("round trip of %q = %q", value, got)
+		}
+	}
+
+	first, firstNonce, _ := e.AesGcmEncryptFromBase64("hello", testKey)
+	second, secondNonce, _ := e.AesGcmEncryptFromBase64("hello", testKey)
+	if firstNonce == secondNonce || first == second {
+		t.Error("two encryptions of the same value reused the nonce")
+	}
+}
+
+func TestAesGcmDetectsFlippedCiphertextByte(t *testing.T) {
+	e := encryption_util.NewEncryptionUtil()
+	ciphertext, nonce, err := e.AesGcmEncryptFromBase64("hello", testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(ciphertext)
+
+	for i := range raw {
+		tampered := append([]byte(nil), raw...)
+		tampered[i] ^= 0x01
+		if _, err := e.AesGcmDecryptFromBase64(base64.StdEncoding.EncodeToString(tampered), testKey, nonce); err == nil {
+			t.Errorf("decryption succeeded with byte %d flipped", i)
+		}
+	}
+}
+
+func TestAesGcmRejectsWrongNonce(t *testing.T) {
+	e := encryption_util.NewEncryptionUtil()
+	ciphertext, _, err := e.AesGcmEncryptFromBase64("hello", testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherNonce, err := e.AesGcmEncryptFromBase64("hello", testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		nonce string
+	}{
+		{"another value's nonce", otherNonce},
+		{"short nonce", base64.StdEncoding.EncodeToString(make([]byte, 8))},
+		{"no nonce", ""},
+	}
+	for _, tt := range tests {
+		if _, err := e.AesGcmDecryptFromBase64(ciphertext, testKey, tt.nonce); err == nil {
+			t.Errorf("%s: decryption succeeded", tt.name)
+		}
+	}
+}
+
+func TestAesCtrDecryptsLegacyValue(t *testing.T) {
+	// "legacy value" encrypted under testKey and an all-zero IV, as values were written
+	// before ivNonce was recorded
+	const legacy = "nvVn10kwv6bIn+8P2SpzhA=="
+
+	got, err := encryption_util.NewEncryptionUtil().AesDecryptFromBase64(legacy, testKey, make([]byte, 16))
+	if err != nil {
+		t.Fatalf("AesDecryptFromBase64: %v", err)
+	}
+	if got != "legacy value" {
+		t.Errorf("AesDecryptFromBase64 = %q, want %q", got, "legacy value")
+	}
+}
+
+func TestAesCtrRoundTrip(t *testing.T) {
+	e := encryption_util.NewEncryptionUtil()
+	ivBase64, err := e.GenerateIVBase64()
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv, _ := base64.StdEncoding.DecodeString(ivBase64)
+
+	ciphertext, err := e.AesEncryptFromBase64("hello", testKey, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := e.AesDecryptFromBase64(ciphertext, testKey, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "hello" {
+		t.Errorf("round trip = %q, want hello", got)
+	}
+}
+
+func TestAesCtrRequiresIV(t *testing.T) {
+	e := encryption_util.NewEncryptionUtil()
+	if _, err := e.AesEncryptFromBase64("hello", testKey, nil); err == nil {
+		t.Error("AesEncryptFromBase64 succeeded without an IV")
+	}
+	if _, err := e.AesDecryptFromBase64("nvVn10kwv6bIn+8P2SpzhA==", testKey, nil); err == nil {
+		t.Error("AesDecryptFromBase64 succeeded without an IV")
+	}
+	if _, err := e.AesEncryptFromBase64("hello", testKey, make([]byte, 8)); err == nil {
+		t.Error("AesEncryptFromBase64 succeeded with an 8 byte IV")
+	}
+}
+
+func TestVerifySHA256RSA(t *testing.T) {
+	e := encryption_util.NewEncryptionUtil()
+	privateKey, publicKey := generateRSAKeyPair(t)
+	_, otherPublicKey := generateRSAKeyPair(t)
+	signature, err := e.SignSHA256RSA("value", privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.VerifySHA256RSA("value", signature, publicKey); err != nil {
+		t.Errorf("valid signature: %v", err)
+	}
+	if err := e.VerifySHA256RSA("tampered", signature, publicKey); err == nil {
+		t.Error("signature verified for a tampered value")
+	}
+	if err := e.VerifySHA256RSA("value", signature, otherPublicKey); err == nil {
+		t.Error("signature verified with another key")
+	}
+	if err := e.VerifySHA256RSA("value", "", publicKey); err == nil {
+		t.Error("empty signature verified")
+	}
+}
+
+// generateRSAKeyPair returns a private and public key in the base64 form kept in atKeys.
+func generateRSAKeyPair(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+	privateKey, publicKey, err := encryption_util.NewEncryptionUtil().GenerateRSAKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []byte(base64.StdEncoding.EncodeToString(privateKey)), []byte(base64.StdEncoding.EncodeToString(publicKey))
+}
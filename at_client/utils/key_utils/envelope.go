@@ -0,0 +1,249 @@
+package key_utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// A passphrase protected atKeys file is a JSON envelope around the contents of a plain atKeys
+// file:
+//
+//	{
+//	    "format": "atKeysEnvelope",
+//	    "version": 1,
+//	    "kdf": "scrypt",
+//	    "kdfParams": {"N": 32768, "p": 1, "r": 8},
+//	    "salt": "<base64>",
+//	    "cipher": "aes-256-gcm",
+//	    "nonce": "<base64>",
+//	    "ciphertext": "<base64>"
+//	}
+//
+// The AES key is derived from the passphrase and salt with the named KDF. Every field except
+// nonce and ciphertext is authenticated as additional data, so the header cannot be altered.
+
+const (
+	EnvelopeFormat  = "atKeysEnvelope"
+	EnvelopeVersion = 1
+
+	KDFScrypt   = "scrypt"
+	KDFArgon2id = "argon2id"
+
+	envelopeCipher = "aes-256-gcm"
+	envelopeKeyLen = 32
+
+	// The KDF parameters are read before the envelope is authenticated, so they are bounded to
+	// stop a corrupted or crafted file from exhausting memory or CPU.
+	maxKDFMemory      = 1 << 30
+	maxKDFIterations  = 16
+	maxKDFParallelism = 16
+)
+
+// defaultKDFParams are the parameters new envelopes are written with. Argon2id memory is in KiB.
+var defaultKDFParams = map[string]map[string]int{
+	KDFScrypt:   {"N": 1 << 15, "r": 8, "p": 1},
+	KDFArgon2id: {"t": 3, "m": 64 * 1024, "p": 4},
+}
+
+type envelope struct {
+	Format     string         `json:"format"`
+	Version    int            `json:"version"`
+	KDF        string         `json:"kdf"`
+	KDFParams  map[string]int `json:"kdfParams"`
+	Salt       string         `json:"salt"`
+	Cipher     string         `json:"cipher"`
+	Nonce      string         `json:"nonce,omitempty"`
+	Ciphertext string         `json:"ciphertext,omitempty"`
+}
+
+// IsPassphraseProtected reports whether data is an atKeys envelope rather than a plain atKeys file.
+func IsPassphraseProtected(data []byte) bool {
+	var header struct {
+		Format string `json:"format"`
+	}
+	return json.Unmarshal(data, &header) == nil && header.Format == EnvelopeFormat
+}
+
+// EncryptAtKeys wraps the contents of an atKeys file in an envelope encrypted with a key
+// derived from passphrase using kdf, KDFScrypt or KDFArgon2id.
+func EncryptAtKeys(atKeysJSON []byte, passphrase []byte, kdf string) ([]byte, error) {
+	params, ok := defaultKDFParams[kdf]
+	if !ok {
+		return nil, fmt.Errorf("unsupported kdf %s", kdf)
+	}
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("passphrase must not be empty")
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	e := envelope{
+		Format:    EnvelopeFormat,
+		Version:   EnvelopeVersion,
+		KDF:       kdf,
+		KDFParams: params,
+		Salt:      base64.StdEncoding.EncodeToString(salt),
+		Cipher:    envelopeCipher,
+	}
+	aead, err := e.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	additionalData, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	e.Nonce = base64.StdEncoding.EncodeToString(nonce)
+	e.Ciphertext = base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, atKeysJSON, additionalData))
+
+	return json.MarshalIndent(e, "", "    ")
+}
+
+// DecryptAtKeys returns the contents of the atKeys file wrapped in envelopeData.
+func DecryptAtKeys(envelopeData []byte, passphrase []byte) ([]byte, error) {
+	var e envelope
+	if err := json.Unmarshal(envelopeData, &e); err != nil {
+		return nil, err
+	}
+	if e.Format != EnvelopeFormat {
+		return nil, fmt.Errorf("not a passphrase protected atKeys file")
+	}
+	if e.Version != EnvelopeVersion {
+		return nil, fmt.Errorf("unsupported envelope version %d", e.Version)
+	}
+	if e.Cipher != envelopeCipher {
+		return nil, fmt.Errorf("unsupported cipher %s", e.Cipher)
+	}
+
+	nonce, err := base64.StdEncoding.DecodeString(e.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nonce - %v", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(e.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext - %v", err)
+	}
+
+	aead, err := e.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce size %d", len(nonce))
+	}
+
+	header := e
+	header.Nonce, header.Ciphertext = "", ""
+	additionalData, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	atKeysJSON, err := aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("wrong passphrase or corrupted file")
+	}
+	return atKeysJSON, nil
+}
+
+// LoadKeysFile reads the atKeys file at path, which may be plain or passphrase protected. The
+// passphrase is only needed, and only used, for a passphrase protected file.
+func LoadKeysFile(path string, passphrase []byte) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if IsPassphraseProtected(data) {
+		if len(passphrase) == 0 {
+			return nil, fmt.Errorf("loadKeys: %s is passphrase protected", path)
+		}
+		if data, err = DecryptAtKeys(data, passphrase); err != nil {
+			return nil, fmt.Errorf("loadKeys: %s - %v", path, err)
+		}
+	}
+	return ParseAtKeys(data)
+}
+
+// SaveKeysFile writes keys to an atKeys file at path. The file is passphrase protected, with a
+// key derived using kdf, unless passphrase is empty.
+func SaveKeysFile(path string, keys map[string]string, passphrase []byte, kdf string) error {
+	data, err := MarshalAtKeys(keys)
+	if err != nil {
+		return err
+	}
+	if len(passphrase) > 0 {
+		if data, err = EncryptAtKeys(data, passphrase, kdf); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// aead derives the envelope key from passphrase with the envelope's KDF.
+func (e *envelope) aead(passphrase []byte) (cipher.AEAD, error) {
+	salt, err := base64.StdEncoding.DecodeString(e.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt - %v", err)
+	}
+
+	if err := e.checkKDFParams(); err != nil {
+		return nil, err
+	}
+
+	var key []byte
+	switch e.KDF {
+	case KDFScrypt:
+		key, err = scrypt.Key(passphrase, salt, e.KDFParams["N"], e.KDFParams["r"], e.KDFParams["p"], envelopeKeyLen)
+		if err != nil {
+			return nil, err
+		}
+	case KDFArgon2id:
+		key = argon2.IDKey(passphrase, salt, uint32(e.KDFParams["t"]), uint32(e.KDFParams["m"]), uint8(e.KDFParams["p"]), envelopeKeyLen)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// checkKDFParams rejects KDF parameters that the KDF would panic on, or that would use more
+// than maxKDFMemory, maxKDFIterations or maxKDFParallelism.
+func (e *envelope) checkKDFParams() error {
+	switch e.KDF {
+	case KDFScrypt:
+		n, r, p := e.KDFParams["N"], e.KDFParams["r"], e.KDFParams["p"]
+		if n < 2 || n&(n-1) != 0 || r < 1 || p < 1 || p > maxKDFParallelism || n > maxKDFMemory/128/r {
+			return fmt.Errorf("invalid scrypt parameters N=%d r=%d p=%d", n, r, p)
+		}
+	case KDFArgon2id:
+		t, m, p := e.KDFParams["t"], e.KDFParams["m"], e.KDFParams["p"]
+		if t < 1 || t > maxKDFIterations || p < 1 || p > maxKDFParallelism || m < 8*p || m > maxKDFMemory/1024 {
+			return fmt.Errorf("invalid argon2id parameters t=%d m=%d p=%d", t, m, p)
+		}
+	default:
+		return fmt.Errorf("unsupported kdf %s", e.KDF)
+	}
+	return nil
+}
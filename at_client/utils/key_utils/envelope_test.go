@@ -0,0 +1,183 @@
+package key_utils_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/atsign-foundation/at_go/at_client/utils/key_utils"
+)
+
+var kdfs = []string{key_utils.KDFScrypt, key_utils.KDFArgon2id}
+
+// encryptAtKeys returns an envelope around atKeysJSON made with kdf.
+func encryptAtKeys(t *testing.T, atKeysJSON []byte, kdf string) []byte {
+	t.Helper()
+	envelopeData, err := key_utils.EncryptAtKeys(atKeysJSON, []byte("correct horse"), kdf)
+	if err != nil {
+		t.Fatalf("EncryptAtKeys(%s): %v", kdf, err)
+	}
+	return envelopeData
+}
+
+// editEnvelope returns envelopeData with edit applied to its decoded JSON.
+func editEnvelope(t *testing.T, envelopeData []byte, edit func(fields map[string]any)) []byte {
+	t.Helper()
+	var fields map[string]any
+	if err := json.Unmarshal(envelopeData, &fields); err != nil {
+		t.Fatal(err)
+	}
+	edit(fields)
+	edited, err := json.Marshal(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return edited
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	atKeysJSON := []byte(`{"selfEncryptionKey":"key"}`)
+	for _, kdf := range kdfs {
+		t.Run(kdf, func(t *testing.T) {
+			envelopeData := encryptAtKeys(t, atKeysJSON, kdf)
+			if !key_utils.IsPassphraseProtected(envelopeData) {
+				t.Error("IsPassphraseProtected is false for an envelope")
+			}
+			if strings.Contains(string(envelopeData), "selfEncryptionKey") {
+				t.Error("envelope contains the atKeys in the clear")
+			}
+
+			got, err := key_utils.DecryptAtKeys(envelopeData, []byte("correct horse"))
+			if err != nil {
+				t.Fatalf("DecryptAtKeys: %v", err)
+			}
+			if string(got) != string(atKeysJSON) {
+				t.Errorf("DecryptAtKeys = %s, want %s", got, atKeysJSON)
+			}
+		})
+	}
+	if key_utils.IsPassphraseProtected(atKeysJSON) {
+		t.Error("IsPassphraseProtected is true for a plain atKeys file")
+	}
+}
+
+func TestEnvelopeWrongPassphrase(t *testing.T) {
+	for _, kdf := range kdfs {
+		envelopeData := encryptAtKeys(t, []byte(`{}`), kdf)
+		if _, err := key_utils.DecryptAtKeys(envelopeData, []byte("wrong horse")); err == nil {
+			t.Errorf("%s: DecryptAtKeys succeeded with the wrong passphrase", kdf)
+		}
+	}
+}
+
+func TestEnvelopeDetectsTampering(t *testing.T) {
+	envelopeData := encryptAtKeys(t, []byte(`{"selfEncryptionKey":"key"}`), key_utils.KDFScrypt)
+
+	tests := []struct {
+		name string
+		edit func(fields map[string]any)
+	}{
+		{"kdf parameters", func(fields map[string]any) {
+			fields["kdfParams"].(map[string]any)["N"] = 1 << 14
+		}},
+		{"salt", func(fields map[string]any) {
+			fields["salt"] = base64.StdEncoding.EncodeToString(make([]byte, 16))
+		}},
+		{"added header field", func(fields map[string]any) {
+			fields["kdfParams"].(map[string]any)["x"] = 1
+		}},
+		{"ciphertext", func(fields map[string]any) {
+			ciphertext, _ := base64.StdEncoding.DecodeString(fields["ciphertext"].(string))
+			ciphertext[0] ^= 0x01
+			fields["ciphertext"] = base64.StdEncoding.EncodeToString(ciphertext)
+		}},
+		{"nonce", func(fields map[string]any) {
+			nonce, _ := base64.StdEncoding.DecodeString(fields["nonce"].(string))
+			nonce[0] ^= 0x01
+			fields["nonce"] = base64.StdEncoding.EncodeToString(nonce)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tampered := editEnvelope(t, envelopeData, tt.edit)
+			if _, err := key_utils.DecryptAtKeys(tampered, []byte("correct horse")); err == nil {
+				t.Error("DecryptAtKeys succeeded")
+			}
+		})
+	}
+}
+
+func TestEnvelopeRejectsKDFParamsBeforeDerivation(t *testing.T) {
+	scryptEnvelope := encryptAtKeys(t, []byte(`{}`), key_utils.KDFScrypt)
+	argon2idEnvelope := encryptAtKeys(t, []byte(`{}`), key_utils.KDFArgon2id)
+
+	// Deriving a key with any of these would panic or take gigabytes of memory
+	tests := []struct {
+		name     string
+		envelope []byte
+		kdf      string
+		params   map[string]int
+		wantErr  string
+	}{
+		{"scrypt N too large", scryptEnvelope, "scrypt", map[string]int{"N": 1 << 30, "r": 8, "p": 1}, "invalid scrypt parameters"},
+		{"scrypt N not a power of two", scryptEnvelope, "scrypt", map[string]int{"N": 1000, "r": 8, "p": 1}, "invalid scrypt parameters"},
+		{"scrypt r zero", scryptEnvelope, "scrypt", map[string]int{"N": 1 << 15, "r": 0, "p": 1}, "invalid scrypt parameters"},
+		{"scrypt p too large", scryptEnvelope, "scrypt", map[string]int{"N": 1 << 15, "r": 8, "p": 1 << 20}, "invalid scrypt parameters"},
+		{"scrypt missing", scryptEnvelope, "scrypt", map[string]int{}, "invalid scrypt parameters"},
+		{"argon2id memory too large", argon2idEnvelope, "argon2id", map[string]int{"t": 3, "m": 1 << 30, "p": 4}, "invalid argon2id parameters"},
+		{"argon2id too many iterations", argon2idEnvelope, "argon2id", map[string]int{"t": 1 << 20, "m": 64 * 1024, "p": 4}, "invalid argon2id parameters"},
+		{"argon2id p zero", argon2idEnvelope, "argon2id", map[string]int{"t": 3, "m": 64 * 1024, "p": 0}, "invalid argon2id parameters"},
+		{"argon2id p above 255", argon2idEnvelope, "argon2id", map[string]int{"t": 3, "m": 64 * 1024, "p": 256}, "invalid argon2id parameters"},
+		{"unknown kdf", scryptEnvelope, "pbkdf2", map[string]int{"i": 1}, "unsupported kdf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edited := editEnvelope(t, tt.envelope, func(fields map[string]any) {
+				fields["kdf"] = tt.kdf
+				fields["kdfParams"] = tt.params
+			})
+			_, err := key_utils.DecryptAtKeys(edited, []byte("correct horse"))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("DecryptAtKeys error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeysFileRoundTrip(t *testing.T) {
+	keys := generateKeys(t)
+	directory := t.TempDir()
+
+	tests := []struct {
+		name       string
+		passphrase string
+		kdf        string
+	}{
+		{"plain", "", ""},
+		{"scrypt", "correct horse", key_utils.KDFScrypt},
+		{"argon2id", "correct horse", key_utils.KDFArgon2id},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(directory, tt.name, "@alice_key.atKeys")
+			if err := key_utils.SaveKeysFile(path, keys, []byte(tt.passphrase), tt.kdf); err != nil {
+				t.Fatalf("SaveKeysFile: %v", err)
+			}
+			loaded, err := key_utils.LoadKeysFile(path, []byte(tt.passphrase))
+			if err != nil {
+				t.Fatalf("LoadKeysFile: %v", err)
+			}
+			if !reflect.DeepEqual(loaded, keys) {
+				t.Errorf("LoadKeysFile = %v, want %v", loaded, keys)
+			}
+			if tt.passphrase != "" {
+				if _, err := key_utils.LoadKeysFile(path, nil); err == nil {
+					t.Error("LoadKeysFile succeeded without the passphrase")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package key_utils
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// KeyStore loads and saves the keys of atSigns, in the form returned by LoadKeys.
+type KeyStore interface {
+	Load(atSign string) (map[string]string, error)
+	Save(atSign string, keys map[string]string) error
+	// Exists reports whether Load would find keys for atSign.
+	Exists(atSign string) bool
+}
+
+// FileKeyStore keeps atKeys files, named @alice_key.atKeys, in a directory.
+type FileKeyStore struct {
+	Directory string
+
+	// FallbackDirectories are searched, in order, by Load when Directory has no file for an atSign.
+	FallbackDirectories []string
+}
+
+func NewFileKeyStore(directory string, fallbackDirectories ...string) *FileKeyStore {
+	return &FileKeyStore{
+		Directory:           directory,
+		FallbackDirectories: fallbackDirectories,
+	}
+}
+
+// DefaultFileKeyStore keeps atKeys files in ~/.atsign/keys and also loads them from ./keys.
+func DefaultFileKeyStore() *FileKeyStore {
+	return NewFileKeyStore(expectedKeysFilesLocation, legacyKeysFilesLocation)
+}
+
+// File returns the path Save writes atSign's keys to.
+func (s *FileKeyStore) File(atSign string) string {
+	return filepath.Join(s.Directory, formatAtSign(atSign)+keysFileSuffix)
+}
+
+func (s *FileKeyStore) Exists(atSign string) bool {
+	_, err := s.find(atSign)
+	return err == nil
+}
+
+func (s *FileKeyStore) Load(atSign string) (map[string]string, error) {
+	file, err := s.find(atSign)
+	if err != nil {
+		return nil, err
+	}
+	return LoadKeysFile(file, nil)
+}
+
+func (s *FileKeyStore) Save(atSign string, keys map[string]string) error {
+	return SaveKeysFile(s.File(atSign), keys, nil, "")
+}
+
+func (s *FileKeyStore) String() string {
+	return s.Directory
+}
+
+// find returns the path of atSign's atKeys file in Directory or one of FallbackDirectories.
+func (s *FileKeyStore) find(atSign string) (string, error) {
+	directories := append([]string{s.Directory}, s.FallbackDirectories...)
+	for _, directory := range directories {
+		file := filepath.Join(directory, formatAtSign(atSign)+keysFileSuffix)
+		if _, err := os.Stat(file); err == nil {
+			return file, nil
+		}
+	}
+	return "", fmt.Errorf("loadKeys: No file called %s%s in %s\n"+
+		"\tKeys files are expected to be in ~/.atsign/keys/ (canonical location) or ./keys/ (legacy location)",
+		formatAtSign(atSign), keysFileSuffix, strings.Join(directories, " or "))
+}
+
+// MemoryKeyStore keeps keys in memory, e.g. after reading an atKeys file from a secret manager.
+type MemoryKeyStore struct {
+	mu   sync.Mutex
+	keys map[string]map[string]string
+}
+
+func NewMemoryKeyStore() *MemoryKeyStore {
+	return &MemoryKeyStore{
+		keys: map[string]map[string]string{},
+	}
+}
+
+// AddAtKeys stores the keys in the contents of an atKeys file.
+func (s *MemoryKeyStore) AddAtKeys(atSign string, atKeysJSON []byte) error {
+	keys, err := ParseAtKeys(atKeysJSON)
+	if err != nil {
+		return err
+	}
+	return s.Save(atSign, keys)
+}
+
+func (s *MemoryKeyStore) Exists(atSign string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.keys[formatAtSign(atSign)]
+	return ok
+}
+
+func (s *MemoryKeyStore) Load(atSign string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys, ok := s.keys[formatAtSign(atSign)]
+	if !ok {
+		return nil, fmt.Errorf("loadKeys: No keys for %s in memory", formatAtSign(atSign))
+	}
+	return copyKeys(keys), nil
+}
+
+func (s *MemoryKeyStore) Save(atSign string, keys map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[formatAtSign(atSign)] = copyKeys(keys)
+	return nil
+}
+
+const DefaultEnvKeyStorePrefix = "ATSIGN_KEYS_"
+
+// EnvKeyStore reads atKeys from environment variables named Prefix followed by the atSign in
+// upper case, with anything other than letters and digits replaced by "_" (ATSIGN_KEYS_ALICE
+// for @alice). A variable holds the contents of an atKeys file, either as is or base64
+// encoded. Environment variables cannot be saved.
+type EnvKeyStore struct {
+	Prefix string
+}
+
+func NewEnvKeyStore(prefix string) *EnvKeyStore {
+	return &EnvKeyStore{
+		Prefix: prefix,
+	}
+}
+
+// Variable returns the name of the environment variable holding atSign's keys.
+func (s *EnvKeyStore) Variable(atSign string) string {
+	name := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, strings.TrimPrefix(formatAtSign(atSign), "@"))
+	return s.Prefix + name
+}
+
+func (s *EnvKeyStore) Exists(atSign string) bool {
+	_, ok := os.LookupEnv(s.Variable(atSign))
+	return ok
+}
+
+func (s *EnvKeyStore) Load(atSign string) (map[string]string, error) {
+	value, ok := os.LookupEnv(s.Variable(atSign))
+	if !ok {
+		return nil, fmt.Errorf("loadKeys: Environment variable %s is not set", s.Variable(atSign))
+	}
+
+	data := []byte(strings.TrimSpace(value))
+	if !strings.HasPrefix(string(data), "{") {
+		decoded, err := base64.StdEncoding.DecodeString(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("loadKeys: Environment variable %s is neither atKeys JSON nor base64 - %v", s.Variable(atSign), err)
+		}
+		data = decoded
+	}
+	return ParseAtKeys(data)
+}
+
+func (s *EnvKeyStore) Save(atSign string, keys map[string]string) error {
+	return fmt.Errorf("saveKeys: Cannot save keys to environment variable %s", s.Variable(atSign))
+}
+
+func formatAtSign(atSign string) string {
+	atSign = strings.TrimSpace(atSign)
+	if !strings.HasPrefix(atSign, "@") {
+		atSign = "@" + atSign
+	}
+	return atSign
+}
+
+func copyKeys(keys map[string]string) map[string]string {
+	copied := make(map[string]string, len(keys))
+	for name, value := range keys {
+		copied[name] = value
+	}
+	return copied
+}
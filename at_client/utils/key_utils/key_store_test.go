@@ -0,0 +1,214 @@
+package key_utils_test
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/atsign-foundation/at_go/at_client/utils/key_utils"
+)
+
+func generateKeys(t *testing.T) map[string]string {
+	t.Helper()
+	keys, err := key_utils.NewKeysUtil().GenerateKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+// checkRoundTrip saves keys for @alice to store and checks that they load unchanged.
+func checkRoundTrip(t *testing.T, store key_utils.KeyStore) {
+	t.Helper()
+	keys := generateKeys(t)
+
+	if store.Exists("@alice") {
+		t.Fatal("Exists(@alice) before Save")
+	}
+	if err := store.Save("@alice", keys); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if !store.Exists("@alice") || !store.Exists("alice") {
+		t.Error("Exists(@alice) is false after Save")
+	}
+	if store.Exists("@bob") {
+		t.Error("Exists(@bob) is true")
+	}
+
+	loaded, err := store.Load("@alice")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, keys) {
+		t.Errorf("Load = %v, want %v", loaded, keys)
+	}
+	if _, err := store.Load("@bob"); err == nil {
+		t.Error("Load(@bob) succeeded")
+	}
+}
+
+func TestFileKeyStoreRoundTrip(t *testing.T) {
+	directory := filepath.Join(t.TempDir(), "keys")
+	store := key_utils.NewFileKeyStore(directory)
+	checkRoundTrip(t, store)
+
+	info, err := os.Stat(store.File("@alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("atKeys file mode = %v, want 0600", info.Mode().Perm())
+	}
+	if filepath.Base(store.File("@alice")) != "@alice_key.atKeys" {
+		t.Errorf("File(@alice) = %s", store.File("@alice"))
+	}
+}
+
+func TestFileKeyStoreFallbackDirectories(t *testing.T) {
+	directory, fallback := t.TempDir(), t.TempDir()
+	keys := generateKeys(t)
+	if err := key_utils.NewFileKeyStore(fallback).Save("@alice", keys); err != nil {
+		t.Fatal(err)
+	}
+
+	store := key_utils.NewFileKeyStore(directory, fallback)
+	loaded, err := store.Load("@alice")
+	if err != nil {
+		t.Fatalf("Load from fallback directory: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, keys) {
+		t.Error("keys loaded from the fallback directory differ")
+	}
+
+	if err := store.Save("@alice", keys); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(directory, "@alice_key.atKeys")); err != nil {
+		t.Errorf("Save did not write to Directory: %v", err)
+	}
+}
+
+func TestDefaultFileKeyStoreFallsBackToWorkingDirectory(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := key_utils.DefaultFileKeyStore()
+	want := []string{filepath.Join(wd, "keys")}
+	if !reflect.DeepEqual(store.FallbackDirectories, want) {
+		t.Errorf("FallbackDirectories = %v, want %v", store.FallbackDirectories, want)
+	}
+	if !strings.HasSuffix(store.Directory, filepath.Join(".atsign", "keys")) {
+		t.Errorf("Directory = %s, want ~/.atsign/keys", store.Directory)
+	}
+}
+
+func TestMemoryKeyStoreRoundTrip(t *testing.T) {
+	checkRoundTrip(t, key_utils.NewMemoryKeyStore())
+}
+
+func TestMemoryKeyStoreKeepsCopies(t *testing.T) {
+	store := key_utils.NewMemoryKeyStore()
+	keys := generateKeys(t)
+	if err := store.Save("@alice", keys); err != nil {
+		t.Fatal(err)
+	}
+	keys[key_utils.SelfEncryptionKeyName] = "changed after Save"
+
+	loaded, err := store.Load("@alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded[key_utils.SelfEncryptionKeyName] == "changed after Save" {
+		t.Error("Save kept the caller's map")
+	}
+	loaded[key_utils.SelfEncryptionKeyName] = "changed after Load"
+	if again, _ := store.Load("@alice"); again[key_utils.SelfEncryptionKeyName] == "changed after Load" {
+		t.Error("Load returned the stored map")
+	}
+}
+
+func TestMemoryKeyStoreAddAtKeys(t *testing.T) {
+	keys := generateKeys(t)
+	atKeysJSON, err := key_utils.MarshalAtKeys(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := key_utils.NewMemoryKeyStore()
+	if err := store.AddAtKeys("@alice", atKeysJSON); err != nil {
+		t.Fatalf("AddAtKeys: %v", err)
+	}
+	loaded, err := store.Load("@alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, keys) {
+		t.Errorf("Load = %v, want %v", loaded, keys)
+	}
+}
+
+func TestEnvKeyStore(t *testing.T) {
+	keys := generateKeys(t)
+	atKeysJSON, err := key_utils.MarshalAtKeys(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := key_utils.NewEnvKeyStore(key_utils.DefaultEnvKeyStorePrefix)
+
+	if got := store.Variable("@alice"); got != "ATSIGN_KEYS_ALICE" {
+		t.Errorf("Variable(@alice) = %s", got)
+	}
+	if got := store.Variable("@jane.doe-1"); got != "ATSIGN_KEYS_JANE_DOE_1" {
+		t.Errorf("Variable(@jane.doe-1) = %s", got)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"json", string(atKeysJSON)},
+		{"base64", base64.StdEncoding.EncodeToString(atKeysJSON)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("ATSIGN_KEYS_ALICE", test.value)
+			if !store.Exists("@alice") {
+				t.Error("Exists(@alice) is false")
+			}
+			loaded, err := store.Load("@alice")
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if !reflect.DeepEqual(loaded, keys) {
+				t.Errorf("Load = %v, want %v", loaded, keys)
+			}
+		})
+	}
+
+	t.Setenv("ATSIGN_KEYS_ALICE", "not atKeys")
+	if _, err := store.Load("@alice"); err == nil {
+		t.Error("Load succeeded with a value that is neither JSON nor base64")
+	}
+	if store.Exists("@bob") {
+		t.Error("Exists(@bob) is true")
+	}
+	if err := store.Save("@alice", keys); err == nil {
+		t.Error("Save to the environment succeeded")
+	}
+}
+
+func TestPassphraseFileKeyStoreRoundTrip(t *testing.T) {
+	directory := t.TempDir()
+	checkRoundTrip(t, key_utils.NewPassphraseFileKeyStore(directory, []byte("correct horse")))
+
+	if _, err := key_utils.NewPassphraseFileKeyStore(directory, []byte("wrong horse")).Load("@alice"); err == nil {
+		t.Error("Load succeeded with the wrong passphrase")
+	}
+	if _, err := key_utils.NewFileKeyStore(directory).Load("@alice"); err == nil || !strings.Contains(err.Error(), "passphrase protected") {
+		t.Errorf("FileKeyStore.Load of a passphrase protected file: error = %v", err)
+	}
+}
@@ -2,8 +2,8 @@ package key_utils
 
 import (
 	"crypto/aes"
+	"encoding/base64"
 	"encoding/json"
-	"fmt"
 	"os"
 	"path/filepath"
 
@@ -21,7 +21,7 @@ var (
 		if err != nil {
 			return ""
 		} else {
-			return filepath.Join(wd, "\\..\\..\\keys")
+			return filepath.Join(wd, "keys")
 		}
 	}()
 	keysFileSuffix = "_key.atKeys"
@@ -33,74 +33,114 @@ const (
 	EncryptionPublicKeyName  = "aesEncryptPublicKey"
 	EncryptionPrivateKeyName = "aesEncryptPrivateKey"
 	SelfEncryptionKeyName    = "selfEncryptionKey"
+
+	// Only present in the keys of an APKAM enrollment, where the PKAM key pair is the
+	// enrollment's APKAM key pair.
+	ApkamSymmetricKeyName = "apkamSymmetricKey"
+	EnrollmentIdName      = "enrollmentId"
 )
 
+// encryptedKeyNames are stored in atKeys files encrypted with the self encryption key.
+var encryptedKeyNames = []string{
+	PkamPublicKeyName,
+	PkamPrivateKeyName,
+	EncryptionPublicKeyName,
+	EncryptionPrivateKeyName,
+}
+
+// plainKeyNames are stored in atKeys files without encryption.
+var plainKeyNames = []string{
+	SelfEncryptionKeyName,
+	ApkamSymmetricKeyName,
+	EnrollmentIdName,
+}
+
 func NewKeysUtil() *KeysUtil {
 	return &KeysUtil{}
 }
 
-func (ku *KeysUtil) saveKeys(atSign string, keys map[string]string) error {
-	expectedKeysDirectory := filepath.Dir(expectedKeysFilesLocation)
-	if err := os.MkdirAll(expectedKeysDirectory, os.ModePerm); err != nil {
-		return err
+// GenerateKeys returns a new set of keys for an atSign: PKAM and encryption RSA key pairs and a
+// self encryption AES key, in the form returned by LoadKeys.
+func (ku *KeysUtil) GenerateKeys() (map[string]string, error) {
+	encryptionUtil := encryption_util.NewEncryptionUtil()
+
+	pkamPrivateKey, pkamPublicKey, err := encryptionUtil.GenerateRSAKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	encryptionPrivateKey, encryptionPublicKey, err := encryptionUtil.GenerateRSAKeyPair()
+	if err != nil {
+		return nil, err
 	}
+	selfEncryptionKey, err := encryptionUtil.GenerateAESKeyBase64()
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		PkamPublicKeyName:        base64.StdEncoding.EncodeToString(pkamPublicKey),
+		PkamPrivateKeyName:       base64.StdEncoding.EncodeToString(pkamPrivateKey),
+		EncryptionPublicKeyName:  base64.StdEncoding.EncodeToString(encryptionPublicKey),
+		EncryptionPrivateKeyName: base64.StdEncoding.EncodeToString(encryptionPrivateKey),
+		SelfEncryptionKeyName:    selfEncryptionKey,
+	}, nil
+}
+
+// KeysFile returns the canonical location of atSign's atKeys file, ~/.atsign/keys/@alice_key.atKeys.
+func (ku *KeysUtil) KeysFile(atSign string) string {
+	return ku.getKeysFile(atSign, expectedKeysFilesLocation)
+}
+
+// KeysFileExists reports whether atSign already has an atKeys file in the canonical location.
+func (ku *KeysUtil) KeysFileExists(atSign string) bool {
+	_, err := os.Stat(ku.KeysFile(atSign))
+	return err == nil
+}
+
+// SaveKeys writes keys to atSign's atKeys file in ~/.atsign/keys, replacing any existing file.
+func (ku *KeysUtil) SaveKeys(atSign string, keys map[string]string) error {
+	return DefaultFileKeyStore().Save(atSign, keys)
+}
+
+// LoadKeys reads atSign's atKeys file from ~/.atsign/keys or, failing that, ./keys.
+func (ku *KeysUtil) LoadKeys(atSign string) (map[string]string, error) {
+	return DefaultFileKeyStore().Load(atSign)
+}
 
+// MarshalAtKeys returns keys in the JSON form of an atKeys file, with the PKAM and encryption
+// key pairs encrypted with the self encryption key.
+func MarshalAtKeys(keys map[string]string) ([]byte, error) {
 	encryptionUtil := encryption_util.NewEncryptionUtil()
 	iv := make([]byte, aes.BlockSize) // zero iv
 
-	filePath := ku.getKeysFile(atSign, expectedKeysFilesLocation)
 	selfEncryptionKey := keys[SelfEncryptionKeyName]
-
 	encryptedKeys := make(map[string]string)
 
-	keysToEncrypt := []string{
-		PkamPublicKeyName,
-		PkamPrivateKeyName,
-		EncryptionPublicKeyName,
-		EncryptionPrivateKeyName,
-	}
-
-	for _, keyName := range keysToEncrypt {
+	for _, keyName := range encryptedKeyNames {
+		if _, ok := keys[keyName]; !ok {
+			continue
+		}
 		if encryptedKey, err := encryptionUtil.AesEncryptFromBase64(keys[keyName], selfEncryptionKey, iv); err == nil {
 			encryptedKeys[keyName] = encryptedKey
 		} else {
-			return err
+			return nil, err
 		}
 	}
 
-	encryptedKeys[SelfEncryptionKeyName] = selfEncryptionKey
-
-	jsonData, err := json.MarshalIndent(encryptedKeys, "", "    ")
-	if err != nil {
-		return err
-	}
-
-	if err := os.WriteFile(filePath, jsonData, os.ModePerm); err != nil {
-		return err
+	for _, keyName := range plainKeyNames {
+		if value, ok := keys[keyName]; ok {
+			encryptedKeys[keyName] = value
+		}
 	}
 
-	return nil
+	return json.MarshalIndent(encryptedKeys, "", "    ")
 }
 
-func (ku *KeysUtil) LoadKeys(atSign string) (map[string]string, error) {
+// ParseAtKeys decrypts the JSON of an atKeys file into the form returned by LoadKeys.
+func ParseAtKeys(jsonData []byte) (map[string]string, error) {
 	encryptionUtil := encryption_util.NewEncryptionUtil()
 	iv := make([]byte, aes.BlockSize) // zero iv
 
-	file := ku.getKeysFile(atSign, expectedKeysFilesLocation)
-	if _, err := os.Stat(file); os.IsNotExist(err) {
-		file := ku.getKeysFile(atSign, legacyKeysFilesLocation)
-		if _, err := os.Stat(file); os.IsNotExist(err) {
-			return nil, fmt.Errorf("loadKeys: No file called %s%s at %s or %s\n"+
-				"\tKeys files are expected to be in ~/.atsign/keys/ (canonical location) or ./keys/ (legacy location)",
-				atSign, keysFileSuffix, expectedKeysFilesLocation, legacyKeysFilesLocation)
-		}
-	}
-
-	jsonData, err := os.ReadFile(file)
-	if err != nil {
-		return nil, err
-	}
-
 	var encryptedKeys map[string]string
 	if err := json.Unmarshal(jsonData, &encryptedKeys); err != nil {
 		return nil, err
@@ -109,14 +149,10 @@ func (ku *KeysUtil) LoadKeys(atSign string) (map[string]string, error) {
 	selfEncryptionKey := encryptedKeys[SelfEncryptionKeyName]
 	keys := make(map[string]string)
 
-	keysToDecrypt := []string{
-		PkamPublicKeyName,
-		PkamPrivateKeyName,
-		EncryptionPublicKeyName,
-		EncryptionPrivateKeyName,
-	}
-
-	for _, keyName := range keysToDecrypt {
+	for _, keyName := range encryptedKeyNames {
+		if _, ok := encryptedKeys[keyName]; !ok {
+			continue
+		}
 		if decryptedKey, err := encryptionUtil.AesDecryptFromBase64(encryptedKeys[keyName], selfEncryptionKey, iv); err == nil {
 			keys[keyName] = decryptedKey
 		} else {
@@ -124,6 +160,12 @@ func (ku *KeysUtil) LoadKeys(atSign string) (map[string]string, error) {
 		}
 	}
 
+	for _, keyName := range plainKeyNames {
+		if value, ok := encryptedKeys[keyName]; ok {
+			keys[keyName] = value
+		}
+	}
+
 	return keys, nil
 }
 
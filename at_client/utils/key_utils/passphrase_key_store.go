@@ -0,0 +1,39 @@
+package key_utils
+
+import (
+	"fmt"
+)
+
+// PassphraseFileKeyStore keeps atKeys files in a directory like FileKeyStore, but wraps each
+// one in an envelope encrypted with a key derived from a passphrase. Plain atKeys files in the
+// directory are still loaded.
+type PassphraseFileKeyStore struct {
+	FileKeyStore
+	passphrase []byte
+
+	// KDF derives the key of envelopes written by Save, KDFScrypt (the default) or KDFArgon2id.
+	KDF string
+}
+
+func NewPassphraseFileKeyStore(directory string, passphrase []byte) *PassphraseFileKeyStore {
+	return &PassphraseFileKeyStore{
+		FileKeyStore: FileKeyStore{Directory: directory},
+		passphrase:   passphrase,
+		KDF:          KDFScrypt,
+	}
+}
+
+func (s *PassphraseFileKeyStore) Load(atSign string) (map[string]string, error) {
+	file, err := s.find(atSign)
+	if err != nil {
+		return nil, err
+	}
+	return LoadKeysFile(file, s.passphrase)
+}
+
+func (s *PassphraseFileKeyStore) Save(atSign string, keys map[string]string) error {
+	if len(s.passphrase) == 0 {
+		return fmt.Errorf("saveKeys: No passphrase to protect %s with", s.File(atSign))
+	}
+	return SaveKeysFile(s.File(atSign), keys, s.passphrase, s.KDF)
+}
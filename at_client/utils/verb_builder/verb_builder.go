@@ -1,6 +1,7 @@
 package verb_builder
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/atsign-foundation/at_go/at_client/common"
@@ -27,8 +28,14 @@ func (builder *FromVerbBuilder) Build() string {
 	return fmt.Sprintf("from:%s", builder.sharedBy)
 }
 
+const (
+	SigningAlgoRSA2048 = "rsa2048"
+	HashingAlgoSHA256  = "sha256"
+)
+
 type PKAMVerbBuilder struct {
-	digest string
+	digest       string
+	enrollmentId string
 }
 
 func NewPKAMVerbBuilder() *PKAMVerbBuilder {
@@ -40,7 +47,17 @@ func (builder *PKAMVerbBuilder) SetDigest(digest string) *PKAMVerbBuilder {
 	return builder
 }
 
+// SetEnrollmentId authenticates with the APKAM key of an approved enrollment instead of the
+// atSign's PKAM key.
+func (builder *PKAMVerbBuilder) SetEnrollmentId(enrollmentId string) *PKAMVerbBuilder {
+	builder.enrollmentId = enrollmentId
+	return builder
+}
+
 func (builder *PKAMVerbBuilder) Build() string {
+	if builder.enrollmentId != "" {
+		return fmt.Sprintf("pkam:signingAlgo:%s:hashingAlgo:%s:enrollmentId:%s:%s", SigningAlgoRSA2048, HashingAlgoSHA256, builder.enrollmentId, builder.digest)
+	}
 	return fmt.Sprintf("pkam:%s", builder.digest)
 }
 
@@ -121,6 +138,8 @@ type UpdateVerbBuilder struct {
 	sharedKeyEnc  string
 	pubKeyCS      string
 	encoding      string
+	encAlgo       string
+	ivNonce       string
 	value         string
 }
 
@@ -208,6 +227,16 @@ func (builder *UpdateVerbBuilder) SetEncoding(encoding string) *UpdateVerbBuilde
 	return builder
 }
 
+func (builder *UpdateVerbBuilder) SetEncAlgo(encAlgo string) *UpdateVerbBuilder {
+	builder.encAlgo = encAlgo
+	return builder
+}
+
+func (builder *UpdateVerbBuilder) SetIVNonce(ivNonce string) *UpdateVerbBuilder {
+	builder.ivNonce = ivNonce
+	return builder
+}
+
 func (builder *UpdateVerbBuilder) SetValue(value string) *UpdateVerbBuilder {
 	builder.value = value
 	return builder
@@ -227,13 +256,15 @@ func (builder *UpdateVerbBuilder) SetMetadata(metadata *common.Metadata) *Update
 	builder.SetSharedKeyEnc(metadata.SharedKeyEnc)
 	builder.SetPubKeyCS(metadata.PubKeyCS)
 	builder.SetEncoding(metadata.Encoding)
+	builder.SetEncAlgo(metadata.EncAlgo)
+	builder.SetIVNonce(metadata.IVNonce)
 	return builder
 }
 
 func (builder *UpdateVerbBuilder) WithAtKey(key common.AtKey, value string) *UpdateVerbBuilder {
-	builder.SetKeyName(key.GetName())
+	builder.SetKeyName(key.GetFullyQualifiedKeyName())
 	builder.SetSharedBy(key.GetSharedBy().AtSignStr)
-	if key.GetSharedWith().AtSignStr != "" {
+	if key.GetSharedWith() != nil && key.GetSharedWith().AtSignStr != "" {
 		builder.SetSharedWith(key.GetSharedWith().AtSignStr)
 	}
 	builder.SetIsCached(key.GetMetadata().IsCached)
@@ -246,27 +277,6 @@ func (builder *UpdateVerbBuilder) WithAtKey(key common.AtKey, value string) *Upd
 
 func (builder *UpdateVerbBuilder) Build() string {
 	command := "update"
-	command += fmt.Sprintf(":%s", builder.key)
-
-	if builder.sharedBy != "" {
-		command += fmt.Sprintf(":sharedBy:%s", builder.sharedBy)
-	}
-
-	if builder.sharedWith != "" {
-		command += fmt.Sprintf(":sharedWith:%s", builder.sharedWith)
-	}
-
-	if builder.isHidden {
-		command += ":isHidden:true"
-	}
-
-	if builder.isPublic {
-		command += ":isPublic:true"
-	}
-
-	if builder.isCached {
-		command += ":isCached:true"
-	}
 
 	if builder.ttl > 0 {
 		command += fmt.Sprintf(":ttl:%d", builder.ttl)
@@ -312,7 +322,714 @@ func (builder *UpdateVerbBuilder) Build() string {
 		command += fmt.Sprintf(":encoding:%s", builder.encoding)
 	}
 
-	command += fmt.Sprintf(":%s", builder.value)
+	if builder.encAlgo != "" {
+		command += fmt.Sprintf(":encAlgo:%s", builder.encAlgo)
+	}
+
+	if builder.ivNonce != "" {
+		command += fmt.Sprintf(":ivNonce:%s", builder.ivNonce)
+	}
+
+	command += ":"
+	if builder.isPublic {
+		command += "public:"
+	} else if builder.sharedWith != "" {
+		command += builder.sharedWith + ":"
+	}
+
+	command += builder.key + builder.sharedBy
+	command += " " + builder.value
+
+	return command
+}
+
+const (
+	LookupTypeNone     = ""
+	LookupTypeMetadata = "meta"
+	LookupTypeAll      = "all"
+)
+
+type LlookupVerbBuilder struct {
+	key        string
+	sharedBy   string
+	sharedWith string
+	isPublic   bool
+	isCached   bool
+	lookupType string
+}
+
+func NewLlookupVerbBuilder() *LlookupVerbBuilder {
+	return &LlookupVerbBuilder{}
+}
+
+func (builder *LlookupVerbBuilder) SetKeyName(key string) *LlookupVerbBuilder {
+	builder.key = key
+	return builder
+}
+
+func (builder *LlookupVerbBuilder) SetSharedBy(sharedBy string) *LlookupVerbBuilder {
+	builder.sharedBy = sharedBy
+	return builder
+}
+
+func (builder *LlookupVerbBuilder) SetSharedWith(sharedWith string) *LlookupVerbBuilder {
+	builder.sharedWith = sharedWith
+	return builder
+}
+
+func (builder *LlookupVerbBuilder) SetIsPublic(isPublic bool) *LlookupVerbBuilder {
+	builder.isPublic = isPublic
+	return builder
+}
+
+func (builder *LlookupVerbBuilder) SetIsCached(isCached bool) *LlookupVerbBuilder {
+	builder.isCached = isCached
+	return builder
+}
+
+func (builder *LlookupVerbBuilder) SetLookupType(lookupType string) *LlookupVerbBuilder {
+	builder.lookupType = lookupType
+	return builder
+}
+
+func (builder *LlookupVerbBuilder) WithAtKey(key common.AtKey, lookupType string) *LlookupVerbBuilder {
+	builder.SetKeyName(key.GetFullyQualifiedKeyName())
+	builder.SetSharedBy(key.GetSharedBy().AtSignStr)
+	if key.GetSharedWith() != nil && key.GetSharedWith().AtSignStr != "" {
+		builder.SetSharedWith(key.GetSharedWith().AtSignStr)
+	}
+	builder.SetIsPublic(key.GetMetadata().IsPublic)
+	builder.SetIsCached(key.GetMetadata().IsCached)
+	builder.SetLookupType(lookupType)
+	return builder
+}
+
+func (builder *LlookupVerbBuilder) Build() string {
+	command := "llookup"
+
+	if builder.lookupType != LookupTypeNone {
+		command += ":" + builder.lookupType
+	}
+
+	command += ":"
+	if builder.isCached {
+		command += "cached:"
+	}
+
+	if builder.isPublic {
+		command += "public:"
+	} else if builder.sharedWith != "" {
+		command += builder.sharedWith + ":"
+	}
+
+	command += builder.key + builder.sharedBy
+
+	return command
+}
+
+type LookupVerbBuilder struct {
+	key        string
+	sharedBy   string
+	lookupType string
+}
+
+func NewLookupVerbBuilder() *LookupVerbBuilder {
+	return &LookupVerbBuilder{}
+}
+
+func (builder *LookupVerbBuilder) SetKeyName(key string) *LookupVerbBuilder {
+	builder.key = key
+	return builder
+}
+
+func (builder *LookupVerbBuilder) SetSharedBy(sharedBy string) *LookupVerbBuilder {
+	builder.sharedBy = sharedBy
+	return builder
+}
+
+func (builder *LookupVerbBuilder) SetLookupType(lookupType string) *LookupVerbBuilder {
+	builder.lookupType = lookupType
+	return builder
+}
+
+func (builder *LookupVerbBuilder) WithAtKey(key common.AtKey, lookupType string) *LookupVerbBuilder {
+	builder.SetKeyName(key.GetFullyQualifiedKeyName())
+	builder.SetSharedBy(key.GetSharedBy().AtSignStr)
+	builder.SetLookupType(lookupType)
+	return builder
+}
+
+func (builder *LookupVerbBuilder) Build() string {
+	command := "lookup"
+
+	if builder.lookupType != LookupTypeNone {
+		command += ":" + builder.lookupType
+	}
+
+	return command + ":" + builder.key + builder.sharedBy
+}
+
+type PlookupVerbBuilder struct {
+	key        string
+	sharedBy   string
+	lookupType string
+}
+
+func NewPlookupVerbBuilder() *PlookupVerbBuilder {
+	return &PlookupVerbBuilder{}
+}
+
+func (builder *PlookupVerbBuilder) SetKeyName(key string) *PlookupVerbBuilder {
+	builder.key = key
+	return builder
+}
+
+func (builder *PlookupVerbBuilder) SetSharedBy(sharedBy string) *PlookupVerbBuilder {
+	builder.sharedBy = sharedBy
+	return builder
+}
+
+func (builder *PlookupVerbBuilder) SetLookupType(lookupType string) *PlookupVerbBuilder {
+	builder.lookupType = lookupType
+	return builder
+}
+
+func (builder *PlookupVerbBuilder) WithAtKey(key common.AtKey, lookupType string) *PlookupVerbBuilder {
+	builder.SetKeyName(key.GetFullyQualifiedKeyName())
+	builder.SetSharedBy(key.GetSharedBy().AtSignStr)
+	builder.SetLookupType(lookupType)
+	return builder
+}
+
+func (builder *PlookupVerbBuilder) Build() string {
+	command := "plookup"
+
+	if builder.lookupType != LookupTypeNone {
+		command += ":" + builder.lookupType
+	}
+
+	return command + ":" + builder.key + builder.sharedBy
+}
+
+type DeleteVerbBuilder struct {
+	key        string
+	sharedBy   string
+	sharedWith string
+	isPublic   bool
+	isCached   bool
+}
+
+func NewDeleteVerbBuilder() *DeleteVerbBuilder {
+	return &DeleteVerbBuilder{}
+}
+
+func (builder *DeleteVerbBuilder) SetKeyName(key string) *DeleteVerbBuilder {
+	builder.key = key
+	return builder
+}
+
+func (builder *DeleteVerbBuilder) SetSharedBy(sharedBy string) *DeleteVerbBuilder {
+	builder.sharedBy = sharedBy
+	return builder
+}
+
+func (builder *DeleteVerbBuilder) SetSharedWith(sharedWith string) *DeleteVerbBuilder {
+	builder.sharedWith = sharedWith
+	return builder
+}
+
+func (builder *DeleteVerbBuilder) SetIsPublic(isPublic bool) *DeleteVerbBuilder {
+	builder.isPublic = isPublic
+	return builder
+}
+
+func (builder *DeleteVerbBuilder) SetIsCached(isCached bool) *DeleteVerbBuilder {
+	builder.isCached = isCached
+	return builder
+}
+
+func (builder *DeleteVerbBuilder) WithAtKey(key common.AtKey) *DeleteVerbBuilder {
+	builder.SetKeyName(key.GetFullyQualifiedKeyName())
+	builder.SetSharedBy(key.GetSharedBy().AtSignStr)
+	if key.GetSharedWith() != nil && key.GetSharedWith().AtSignStr != "" {
+		builder.SetSharedWith(key.GetSharedWith().AtSignStr)
+	}
+	builder.SetIsPublic(key.GetMetadata().IsPublic)
+	builder.SetIsCached(key.GetMetadata().IsCached)
+	return builder
+}
+
+func (builder *DeleteVerbBuilder) Build() string {
+	command := "delete:"
+
+	if builder.isCached {
+		command += "cached:"
+	}
+
+	if builder.isPublic {
+		command += "public:"
+	} else if builder.sharedWith != "" {
+		command += builder.sharedWith + ":"
+	}
+
+	command += builder.key + builder.sharedBy
+
+	return command
+}
+
+const (
+	NotifyOperationUpdate = "update"
+	NotifyOperationDelete = "delete"
+
+	NotifyMessageTypeKey  = "key"
+	NotifyMessageTypeText = "text"
+
+	NotifyPriorityLow    = "low"
+	NotifyPriorityMedium = "medium"
+	NotifyPriorityHigh   = "high"
+
+	NotifyStrategyAll    = "all"
+	NotifyStrategyLatest = "latest"
+)
+
+type NotifyVerbBuilder struct {
+	id           string
+	operation    string
+	messageType  string
+	priority     string
+	strategy     string
+	latestN      int
+	notifier     string
+	ttln         int
+	key          string
+	sharedBy     string
+	sharedWith   string
+	isPublic     bool
+	ttl          int
+	ttb          int
+	ttr          int
+	ccd          bool
+	isEncrypted  bool
+	sharedKeyEnc string
+	pubKeyCS     string
+	encoding     string
+	encAlgo      string
+	ivNonce      string
+	value        string
+}
+
+func NewNotifyVerbBuilder() *NotifyVerbBuilder {
+	return &NotifyVerbBuilder{}
+}
+
+func (builder *NotifyVerbBuilder) SetId(id string) *NotifyVerbBuilder {
+	builder.id = id
+	return builder
+}
+
+func (builder *NotifyVerbBuilder) SetOperation(operation string) *NotifyVerbBuilder {
+	builder.operation = operation
+	return builder
+}
+
+func (builder *NotifyVerbBuilder) SetMessageType(messageType string) *NotifyVerbBuilder {
+	builder.messageType = messageType
+	return builder
+}
+
+func (builder *NotifyVerbBuilder) SetPriority(priority string) *NotifyVerbBuilder {
+	builder.priority = priority
+	return builder
+}
+
+func (builder *NotifyVerbBuilder) SetStrategy(strategy string) *NotifyVerbBuilder {
+	builder.strategy = strategy
+	return builder
+}
+
+func (builder *NotifyVerbBuilder) SetLatestN(latestN int) *NotifyVerbBuilder {
+	builder.latestN = latestN
+	return builder
+}
+
+func (builder *NotifyVerbBuilder) SetNotifier(notifier string) *NotifyVerbBuilder {
+	builder.notifier = notifier
+	return builder
+}
+
+func (builder *NotifyVerbBuilder) SetTTLn(ttln int) *NotifyVerbBuilder {
+	builder.ttln = ttln
+	return builder
+}
+
+func (builder *NotifyVerbBuilder) SetKeyName(key string) *NotifyVerbBuilder {
+	builder.key = key
+	return builder
+}
+
+func (builder *NotifyVerbBuilder) SetSharedBy(sharedBy string) *NotifyVerbBuilder {
+	builder.sharedBy = sharedBy
+	return builder
+}
+
+func (builder *NotifyVerbBuilder) SetSharedWith(sharedWith string) *NotifyVerbBuilder {
+	builder.sharedWith = sharedWith
+	return builder
+}
+
+func (builder *NotifyVerbBuilder) SetIsPublic(isPublic bool) *NotifyVerbBuilder {
+	builder.isPublic = isPublic
+	return builder
+}
+
+func (builder *NotifyVerbBuilder) SetTTL(ttl int) *NotifyVerbBuilder {
+	builder.ttl = ttl
+	return builder
+}
+
+func (builder *NotifyVerbBuilder) SetTTB(ttb int) *NotifyVerbBuilder {
+	builder.ttb = ttb
+	return builder
+}
+
+func (builder *NotifyVerbBuilder) SetTTR(ttr int) *NotifyVerbBuilder {
+	builder.ttr = ttr
+	return builder
+}
+
+func (builder *NotifyVerbBuilder) SetCCD(ccd bool) *NotifyVerbBuilder {
+	builder.ccd = ccd
+	return builder
+}
+
+func (builder *NotifyVerbBuilder) SetIsEncrypted(isEncrypted bool) *NotifyVerbBuilder {
+	builder.isEncrypted = isEncrypted
+	return builder
+}
+
+func (builder *NotifyVerbBuilder) SetSharedKeyEnc(sharedKeyEnc string) *NotifyVerbBuilder {
+	builder.sharedKeyEnc = sharedKeyEnc
+	return builder
+}
+
+func (builder *NotifyVerbBuilder) SetPubKeyCS(pubKeyCS string) *NotifyVerbBuilder {
+	builder.pubKeyCS = pubKeyCS
+	return builder
+}
+
+func (builder *NotifyVerbBuilder) SetEncoding(encoding string) *NotifyVerbBuilder {
+	builder.encoding = encoding
+	return builder
+}
+
+func (builder *NotifyVerbBuilder) SetEncAlgo(encAlgo string) *NotifyVerbBuilder {
+	builder.encAlgo = encAlgo
+	return builder
+}
+
+func (builder *NotifyVerbBuilder) SetIVNonce(ivNonce string) *NotifyVerbBuilder {
+	builder.ivNonce = ivNonce
+	return builder
+}
+
+func (builder *NotifyVerbBuilder) SetValue(value string) *NotifyVerbBuilder {
+	builder.value = value
+	return builder
+}
+
+func (builder *NotifyVerbBuilder) SetMetadata(metadata *common.Metadata) *NotifyVerbBuilder {
+	builder.SetTTL(metadata.TTL)
+	builder.SetTTB(metadata.TTB)
+	builder.SetTTR(metadata.TTR)
+	builder.SetCCD(metadata.CCD)
+	builder.SetIsEncrypted(metadata.IsEncrypted)
+	builder.SetSharedKeyEnc(metadata.SharedKeyEnc)
+	builder.SetPubKeyCS(metadata.PubKeyCS)
+	builder.SetEncoding(metadata.Encoding)
+	builder.SetEncAlgo(metadata.EncAlgo)
+	builder.SetIVNonce(metadata.IVNonce)
+	return builder
+}
+
+func (builder *NotifyVerbBuilder) WithAtKey(key common.AtKey, value string) *NotifyVerbBuilder {
+	builder.SetKeyName(key.GetFullyQualifiedKeyName())
+	builder.SetSharedBy(key.GetSharedBy().AtSignStr)
+	if key.GetSharedWith() != nil && key.GetSharedWith().AtSignStr != "" {
+		builder.SetSharedWith(key.GetSharedWith().AtSignStr)
+	}
+	builder.SetIsPublic(key.GetMetadata().IsPublic)
+	builder.SetMetadata(key.GetMetadata())
+	builder.SetValue(value)
+	return builder
+}
+
+func (builder *NotifyVerbBuilder) Build() string {
+	command := "notify"
+
+	if builder.id != "" {
+		command += ":id:" + builder.id
+	}
+
+	if builder.operation != "" {
+		command += ":" + builder.operation
+	}
+
+	if builder.messageType != "" {
+		command += ":messageType:" + builder.messageType
+	}
+
+	if builder.priority != "" {
+		command += ":priority:" + builder.priority
+	}
+
+	if builder.strategy != "" {
+		command += ":strategy:" + builder.strategy
+	}
+
+	if builder.latestN > 0 {
+		command += fmt.Sprintf(":latestN:%d", builder.latestN)
+	}
+
+	if builder.notifier != "" {
+		command += ":notifier:" + builder.notifier
+	}
+
+	if builder.ttln > 0 {
+		command += fmt.Sprintf(":ttln:%d", builder.ttln)
+	}
+
+	if builder.ttl > 0 {
+		command += fmt.Sprintf(":ttl:%d", builder.ttl)
+	}
+
+	if builder.ttb > 0 {
+		command += fmt.Sprintf(":ttb:%d", builder.ttb)
+	}
+
+	if builder.ttr > 0 {
+		command += fmt.Sprintf(":ttr:%d", builder.ttr)
+	}
+
+	if builder.ccd {
+		command += ":ccd:true"
+	}
+
+	if builder.isEncrypted {
+		command += ":isEncrypted:true"
+	}
+
+	if builder.sharedKeyEnc != "" {
+		command += ":sharedKeyEnc:" + builder.sharedKeyEnc
+	}
+
+	if builder.pubKeyCS != "" {
+		command += ":pubKeyCS:" + builder.pubKeyCS
+	}
+
+	if builder.encoding != "" {
+		command += ":encoding:" + builder.encoding
+	}
+
+	if builder.encAlgo != "" {
+		command += ":encAlgo:" + builder.encAlgo
+	}
+
+	if builder.ivNonce != "" {
+		command += ":ivNonce:" + builder.ivNonce
+	}
+
+	command += ":"
+	if builder.isPublic {
+		command += "public:"
+	} else if builder.sharedWith != "" {
+		command += builder.sharedWith + ":"
+	}
+
+	command += builder.key + builder.sharedBy
+
+	if builder.value != "" {
+		command += ":" + builder.value
+	}
+
+	return command
+}
+
+type NotifyStatusVerbBuilder struct {
+	id string
+}
+
+func NewNotifyStatusVerbBuilder() *NotifyStatusVerbBuilder {
+	return &NotifyStatusVerbBuilder{}
+}
+
+func (builder *NotifyStatusVerbBuilder) SetId(id string) *NotifyStatusVerbBuilder {
+	builder.id = id
+	return builder
+}
+
+func (builder *NotifyStatusVerbBuilder) Build() string {
+	return "notify:status:" + builder.id
+}
+
+type MonitorVerbBuilder struct {
+	regex                string
+	lastNotificationTime int64
+}
+
+func NewMonitorVerbBuilder() *MonitorVerbBuilder {
+	return &MonitorVerbBuilder{}
+}
+
+func (builder *MonitorVerbBuilder) SetRegex(regex string) *MonitorVerbBuilder {
+	builder.regex = regex
+	return builder
+}
+
+func (builder *MonitorVerbBuilder) SetLastNotificationTime(lastNotificationTime int64) *MonitorVerbBuilder {
+	builder.lastNotificationTime = lastNotificationTime
+	return builder
+}
+
+func (builder *MonitorVerbBuilder) Build() string {
+	command := "monitor"
+
+	if builder.lastNotificationTime > 0 {
+		command += fmt.Sprintf(":%d", builder.lastNotificationTime)
+	}
+
+	if builder.regex != "" {
+		command += " " + builder.regex
+	}
 
 	return command
 }
+
+const (
+	EnrollOperationRequest = "request"
+	EnrollOperationApprove = "approve"
+	EnrollOperationDeny    = "deny"
+	EnrollOperationRevoke  = "revoke"
+	EnrollOperationList    = "list"
+
+	EnrollmentStatusPending  = "pending"
+	EnrollmentStatusApproved = "approved"
+	EnrollmentStatusDenied   = "denied"
+	EnrollmentStatusRevoked  = "revoked"
+)
+
+// enrollParams is the JSON sent with every enroll operation; unset fields are left out.
+type enrollParams struct {
+	EnrollmentId                         string            `json:"enrollmentId,omitempty"`
+	AppName                              string            `json:"appName,omitempty"`
+	DeviceName                           string            `json:"deviceName,omitempty"`
+	Namespaces                           map[string]string `json:"namespaces,omitempty"`
+	OTP                                  string            `json:"otp,omitempty"`
+	ApkamPublicKey                       string            `json:"apkamPublicKey,omitempty"`
+	EncryptedAPKAMSymmetricKey           string            `json:"encryptedAPKAMSymmetricKey,omitempty"`
+	EncryptedDefaultEncryptionPrivateKey string            `json:"encryptedDefaultEncryptionPrivateKey,omitempty"`
+	EncryptedDefaultSelfEncryptionKey    string            `json:"encryptedDefaultSelfEncryptionKey,omitempty"`
+	EnrollmentStatusFilter               []string          `json:"enrollmentStatusFilter,omitempty"`
+}
+
+type EnrollVerbBuilder struct {
+	operation string
+	params    enrollParams
+}
+
+func NewEnrollVerbBuilder() *EnrollVerbBuilder {
+	return &EnrollVerbBuilder{operation: EnrollOperationRequest}
+}
+
+func (builder *EnrollVerbBuilder) SetOperation(operation string) *EnrollVerbBuilder {
+	builder.operation = operation
+	return builder
+}
+
+func (builder *EnrollVerbBuilder) SetEnrollmentId(enrollmentId string) *EnrollVerbBuilder {
+	builder.params.EnrollmentId = enrollmentId
+	return builder
+}
+
+func (builder *EnrollVerbBuilder) SetAppName(appName string) *EnrollVerbBuilder {
+	builder.params.AppName = appName
+	return builder
+}
+
+func (builder *EnrollVerbBuilder) SetDeviceName(deviceName string) *EnrollVerbBuilder {
+	builder.params.DeviceName = deviceName
+	return builder
+}
+
+// SetNamespaces sets the access requested for each namespace, "r" or "rw".
+func (builder *EnrollVerbBuilder) SetNamespaces(namespaces map[string]string) *EnrollVerbBuilder {
+	builder.params.Namespaces = namespaces
+	return builder
+}
+
+func (builder *EnrollVerbBuilder) SetOTP(otp string) *EnrollVerbBuilder {
+	builder.params.OTP = otp
+	return builder
+}
+
+func (builder *EnrollVerbBuilder) SetApkamPublicKey(apkamPublicKey string) *EnrollVerbBuilder {
+	builder.params.ApkamPublicKey = apkamPublicKey
+	return builder
+}
+
+func (builder *EnrollVerbBuilder) SetEncryptedAPKAMSymmetricKey(encryptedAPKAMSymmetricKey string) *EnrollVerbBuilder {
+	builder.params.EncryptedAPKAMSymmetricKey = encryptedAPKAMSymmetricKey
+	return builder
+}
+
+func (builder *EnrollVerbBuilder) SetEncryptedDefaultEncryptionPrivateKey(encryptedDefaultEncryptionPrivateKey string) *EnrollVerbBuilder {
+	builder.params.EncryptedDefaultEncryptionPrivateKey = encryptedDefaultEncryptionPrivateKey
+	return builder
+}
+
+func (builder *EnrollVerbBuilder) SetEncryptedDefaultSelfEncryptionKey(encryptedDefaultSelfEncryptionKey string) *EnrollVerbBuilder {
+	builder.params.EncryptedDefaultSelfEncryptionKey = encryptedDefaultSelfEncryptionKey
+	return builder
+}
+
+// SetStatusFilter limits enroll:list to enrollments with one of statuses.
+func (builder *EnrollVerbBuilder) SetStatusFilter(statuses []string) *EnrollVerbBuilder {
+	builder.params.EnrollmentStatusFilter = statuses
+	return builder
+}
+
+func (builder *EnrollVerbBuilder) Build() string {
+	command := "enroll:" + builder.operation
+
+	params, err := json.Marshal(builder.params)
+	if err != nil || string(params) == "{}" {
+		return command
+	}
+	return command + ":" + string(params)
+}
+
+type OTPVerbBuilder struct{}
+
+func NewOTPVerbBuilder() *OTPVerbBuilder {
+	return &OTPVerbBuilder{}
+}
+
+func (builder *OTPVerbBuilder) Build() string {
+	return "otp:get"
+}
+
+type KeysVerbBuilder struct {
+	keyName string
+}
+
+func NewKeysVerbBuilder() *KeysVerbBuilder {
+	return &KeysVerbBuilder{}
+}
+
+func (builder *KeysVerbBuilder) SetKeyName(keyName string) *KeysVerbBuilder {
+	builder.keyName = keyName
+	return builder
+}
+
+func (builder *KeysVerbBuilder) Build() string {
+	return "keys:get:keyName:" + builder.keyName
+}
@@ -0,0 +1,83 @@
+package verb_builder_test
+
+import (
+	"testing"
+
+	"github.com/atsign-foundation/at_go/at_client/common"
+	"github.com/atsign-foundation/at_go/at_client/utils/verb_builder"
+)
+
+func TestUpdateVerbBuilderWithAtKey(t *testing.T) {
+	alice, bob := common.NewAtSign("@alice"), common.NewAtSign("@bob")
+
+	withMetadata := common.NewSharedKey("message", alice, bob)
+	withMetadata.Metadata = common.Metadata{
+		TTL:           60000,
+		IsEncrypted:   true,
+		DataSignature: "c2lnbmF0dXJl",
+		IVNonce:       "aXZOb25jZQ==",
+		// The atServer sets these itself; update has no parameter for them
+		IsHidden: true,
+		IsCached: true,
+	}
+
+	tests := []struct {
+		name string
+		key  common.AtKey
+		want string
+	}{
+		{"self", common.NewSelfKey("phone", alice, nil),
+			"update:isBinary:false:isEncrypted:false:phone@alice value"},
+		{"public", common.NewPublicKey("location", alice),
+			"update:isBinary:false:isEncrypted:false:public:location@alice value"},
+		{"shared", common.NewSharedKey("message", alice, bob),
+			"update:isBinary:false:isEncrypted:false:@bob:message@alice value"},
+		{"metadata", withMetadata,
+			"update:ttl:60000:isBinary:false:isEncrypted:true:dataSignature:c2lnbmF0dXJl:ivNonce:aXZOb25jZQ==:@bob:message@alice value"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := verb_builder.NewUpdateVerbBuilder().WithAtKey(test.key, "value").Build()
+			if got != test.want {
+				t.Errorf("Build() = %q\nwant %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUpdateVerbBuilderNamespace(t *testing.T) {
+	alice := common.NewAtSign("@alice")
+	key := common.NewSelfKey("phone", alice, nil)
+	key.SetNamespace("wavi")
+
+	want := "update:isBinary:false:isEncrypted:false:phone.wavi@alice value"
+	if got := verb_builder.NewUpdateVerbBuilder().WithAtKey(key, "value").Build(); got != want {
+		t.Errorf("Build() = %q, want %q", got, want)
+	}
+}
+
+func TestDeleteVerbBuilderWithAtKey(t *testing.T) {
+	alice, bob := common.NewAtSign("@alice"), common.NewAtSign("@bob")
+
+	tests := []struct {
+		name string
+		key  common.AtKey
+		want string
+	}{
+		{"self", common.NewSelfKey("phone", alice, nil), "delete:phone@alice"},
+		{"self shared with itself", common.NewSelfKey("phone", alice, alice), "delete:@alice:phone@alice"},
+		{"public", common.NewPublicKey("location", alice), "delete:public:location@alice"},
+		{"shared", common.NewSharedKey("message", alice, bob), "delete:@bob:message@alice"},
+		{"cached public", common.NewPublicKey("location", alice).Cache(60, false), "delete:cached:public:location@alice"},
+		{"cached shared", common.NewSharedKey("message", alice, bob).Cache(60, false), "delete:cached:@bob:message@alice"},
+		{"private hidden", common.NewPrivateHiddenKey("_secret", alice), "delete:_secret@alice"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := verb_builder.NewDeleteVerbBuilder().WithAtKey(test.key).Build()
+			if got != test.want {
+				t.Errorf("Build() = %q\nwant %q", got, test.want)
+			}
+		})
+	}
+}
@@ -24,6 +24,7 @@ func main() {
 	atClient, err := atclient.NewAtClient(*common.NewAtSign(*atsign), *address, true)
 	if err != nil {
 		fmt.Println(err.Error())
+		os.Exit(1)
 	}
 
 	response, err := atClient.SecondaryConnection.AtConnection.ExecuteCommand("llookup:public:publickey@"+*atsign, true)
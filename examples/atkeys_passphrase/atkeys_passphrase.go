@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/atsign-foundation/at_go/at_client/utils/key_utils"
+)
+
+// Converts an atKeys file to or from the passphrase protected envelope format. The passphrase
+// is taken from -p, the ATKEYS_PASSPHRASE environment variable or the first line of stdin.
+func main() {
+	mode := flag.String("m", "", "protect | unprotect")
+	input := flag.String("i", "", "atKeys file to convert")
+	output := flag.String("o", "", "file to write, defaults to replacing the input file")
+	passphrase := flag.String("p", "", "passphrase (prefer ATKEYS_PASSPHRASE or stdin)")
+	kdf := flag.String("kdf", key_utils.KDFScrypt, "key derivation function when protecting: scrypt | argon2id")
+
+	flag.Parse()
+
+	if *input == "" || (*mode != "protect" && *mode != "unprotect") {
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+	if *output == "" {
+		*output = *input
+	}
+
+	secret := []byte(*passphrase)
+	if len(secret) == 0 {
+		secret = []byte(os.Getenv("ATKEYS_PASSPHRASE"))
+	}
+	if len(secret) == 0 {
+		fmt.Fprint(os.Stderr, "Passphrase: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			fail("read passphrase", err)
+		}
+		secret = []byte(strings.TrimRight(line, "\r\n"))
+	}
+
+	data, err := os.ReadFile(*input)
+	if err != nil {
+		fail("read "+*input, err)
+	}
+
+	switch *mode {
+	case "protect":
+		if key_utils.IsPassphraseProtected(data) {
+			fail("protect "+*input, fmt.Errorf("file is already passphrase protected"))
+		}
+		if _, err := key_utils.ParseAtKeys(data); err != nil {
+			fail("parse "+*input, err)
+		}
+		if data, err = key_utils.EncryptAtKeys(data, secret, *kdf); err != nil {
+			fail("protect "+*input, err)
+		}
+	case "unprotect":
+		if data, err = key_utils.DecryptAtKeys(data, secret); err != nil {
+			fail("unprotect "+*input, err)
+		}
+		if _, err := key_utils.ParseAtKeys(data); err != nil {
+			fail("parse decrypted "+*input, err)
+		}
+	}
+
+	if err := os.WriteFile(*output, data, 0600); err != nil {
+		fail("write "+*output, err)
+	}
+	fmt.Printf("Wrote %s\n", *output)
+}
+
+func fail(what string, err error) {
+	fmt.Printf("Failed to %s - %v\n", what, err)
+	os.Exit(1)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/atsign-foundation/at_go/at_client/atclient"
+	"github.com/atsign-foundation/at_go/at_client/common"
+	"github.com/atsign-foundation/at_go/at_client/connections"
+	"github.com/atsign-foundation/at_go/at_client/utils/key_utils"
+)
+
+func main() {
+	url := flag.String("u", "root.atsign.org:64", "root url of the server")
+	secondary := flag.String("s", "", "atServer host:port, skips the root server lookup")
+	atsign := flag.String("a", "", "atsign")
+	verbose := flag.String("v", "false", "Verbose == true|false")
+	mode := flag.String("m", "", "request | otp | list | approve | deny")
+	enrollmentId := flag.String("i", "", "enrollment id to approve or deny")
+	appName := flag.String("app", "", "app name of the enrolling device")
+	deviceName := flag.String("device", "", "name of the enrolling device")
+	namespaces := flag.String("ns", "", "namespace access of the enrolling device, e.g. wavi:rw,buzz:r")
+	otp := flag.String("otp", "", "one-time password generated with -m otp")
+	timeout := flag.Duration("t", 10*time.Minute, "how long to wait for the request to be approved")
+
+	flag.Parse()
+
+	if *atsign == "" || *mode == "" {
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	atSign := common.NewAtSign(*atsign)
+	verboseFlag := *verbose == "true"
+
+	address, err := connections.AddressFromString(*url)
+	if err != nil {
+		fail("parse root url "+*url, err)
+	}
+	options := []atclient.AtClientOption{}
+	if *secondary != "" {
+		secondaryAddress, err := connections.AddressFromString(*secondary)
+		if err != nil {
+			fail("parse atServer address "+*secondary, err)
+		}
+		options = append(options, atclient.WithSecondaryAddress(*secondaryAddress))
+	}
+
+	if *mode == "request" {
+		request := atclient.EnrollmentRequest{
+			AppName:    *appName,
+			DeviceName: *deviceName,
+			Namespaces: parseNamespaces(*namespaces),
+			OTP:        *otp,
+		}
+		pending, err := atclient.Enroll(*atSign, *address, request, verboseFlag, options...)
+		if err != nil {
+			fail("submit enrollment request", err)
+		}
+		fmt.Printf("Enrollment %s is %s, waiting for approval\n", pending.EnrollmentId, pending.Status)
+
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+		atClient, err := pending.Complete(ctx, 5*time.Second)
+		if err != nil {
+			fail("complete enrollment", err)
+		}
+		atClient.SecondaryConnection.AtConnection.Disconnect()
+		fmt.Printf("Enrolled, keys saved to %s\n", key_utils.NewKeysUtil().KeysFile(atSign.AtSignStr))
+		return
+	}
+
+	atClient, err := atclient.NewAtClient(*atSign, *address, verboseFlag, options...)
+	if err != nil {
+		fail("initialize AtClient", err)
+	}
+	defer atClient.SecondaryConnection.AtConnection.Disconnect()
+
+	switch *mode {
+	case "otp":
+		otp, err := atClient.GenerateOTP()
+		if err != nil {
+			fail("generate OTP", err)
+		}
+		fmt.Println(otp)
+	case "list":
+		enrollments, err := atClient.ListEnrollments()
+		if err != nil {
+			fail("list enrollments", err)
+		}
+		for _, enrollment := range enrollments {
+			fmt.Printf("%s  %-8s  app: %s  device: %s  namespaces: %v\n", enrollment.Id, enrollment.Status, enrollment.AppName, enrollment.DeviceName, enrollment.Namespaces)
+		}
+	case "approve":
+		if err := atClient.ApproveEnrollment(*enrollmentId); err != nil {
+			fail("approve enrollment "+*enrollmentId, err)
+		}
+		fmt.Println("Approved", *enrollmentId)
+	case "deny":
+		if err := atClient.DenyEnrollment(*enrollmentId); err != nil {
+			fail("deny enrollment "+*enrollmentId, err)
+		}
+		fmt.Println("Denied", *enrollmentId)
+	default:
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+}
+
+// parseNamespaces turns "wavi:rw,buzz:r" into a map of namespace to access.
+func parseNamespaces(namespaces string) map[string]string {
+	access := map[string]string{}
+	for _, namespace := range strings.Split(namespaces, ",") {
+		if name, permission, found := strings.Cut(strings.TrimSpace(namespace), ":"); found {
+			access[name] = permission
+		}
+	}
+	return access
+}
+
+func fail(what string, err error) {
+	fmt.Printf("Failed to %s - %v\n", what, err)
+	os.Exit(1)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/atsign-foundation/at_go/at_client/atclient"
+	"github.com/atsign-foundation/at_go/at_client/common"
+	"github.com/atsign-foundation/at_go/at_client/connections"
+	"github.com/atsign-foundation/at_go/at_client/utils/key_utils"
+)
+
+func main() {
+	url := flag.String("u", "root.atsign.org:64", "root url of the server")
+	secondary := flag.String("s", "", "atServer host:port, skips the root server lookup")
+	atsign := flag.String("a", "", "atsign to be activated")
+	cramSecret := flag.String("c", "", "CRAM secret of the atsign")
+	verbose := flag.String("v", "false", "Verbose == true|false")
+
+	flag.Parse()
+
+	if *atsign == "" || *cramSecret == "" {
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	atSign := common.NewAtSign(*atsign)
+	verboseFlag := *verbose == "true"
+
+	address, err := connections.AddressFromString(*url)
+	if err != nil {
+		fmt.Printf("Failed to parse root url %s - %v\n", *url, err)
+		os.Exit(1)
+	}
+	options := []atclient.AtClientOption{}
+	if *secondary != "" {
+		secondaryAddress, err := connections.AddressFromString(*secondary)
+		if err != nil {
+			fmt.Printf("Failed to parse atServer address %s - %v\n", *secondary, err)
+			os.Exit(1)
+		}
+		options = append(options, atclient.WithSecondaryAddress(*secondaryAddress))
+	}
+
+	atClient, err := atclient.Onboard(*atSign, *address, *cramSecret, verboseFlag, options...)
+	if err != nil {
+		fmt.Printf("Failed to onboard %s - %v\n", atSign.AtSignStr, err)
+		os.Exit(1)
+	}
+	atClient.SecondaryConnection.AtConnection.Disconnect()
+
+	fmt.Printf("Onboarded %s, keys saved to %s\n", atSign.AtSignStr, key_utils.NewKeysUtil().KeysFile(atSign.AtSignStr))
+}
@@ -13,9 +13,12 @@ import (
 
 func main() {
 	url := flag.String("u", "root.atsign.org:64", "root url of the server")
+	secondary := flag.String("s", "", "atServer host:port, skips the root server lookup")
 	atsign := flag.String("a", "", "atsign to be activated")
 	verbose := flag.String("v", "false", "Verbose == true|false")
 	regex := flag.String("r", "", "Scan Regex")
+	caFile := flag.String("ca", "", "PEM file of the CAs to trust instead of the system's")
+	insecure := flag.Bool("insecure", false, "accept any server certificate, for local testing only")
 
 	flag.Parse()
 
@@ -44,9 +47,28 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
-	atClient, err = atclient.NewAtClient(*atSign, *address, verboseFlag)
+	options := []atclient.AtClientOption{}
+	if *secondary != "" {
+		secondaryAddress, err := connections.AddressFromString(*secondary)
+		if err != nil {
+			panic(err)
+		}
+		options = append(options, atclient.WithSecondaryAddress(*secondaryAddress))
+	}
+	if *caFile != "" {
+		pool, err := connections.LoadCertPool(*caFile)
+		if err != nil {
+			panic(err)
+		}
+		options = append(options, atclient.WithConnectionOptions(connections.WithRootCAs(pool)))
+	}
+	if *insecure {
+		options = append(options, atclient.WithConnectionOptions(connections.WithInsecureSkipVerify()))
+	}
+	atClient, err = atclient.NewAtClient(*atSign, *address, verboseFlag, options...)
 	if err != nil {
 		fmt.Println(err.Error())
+		os.Exit(1)
 	}
 
 	var atKeys []common.AtKey
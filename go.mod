@@ -1,3 +1,7 @@
 module github.com/atsign-foundation/at_go
 
 go 1.21.1
+
+require golang.org/x/crypto v0.31.0
+
+require golang.org/x/sys v0.28.0 // indirect